@@ -9,7 +9,7 @@ import (
 
 // Run graph dependencies prints the dependency graph to stdout
 func Run(ctx context.Context, opts *options.TerragruntOptions) error {
-	stack, err := configstack.FindStackInSubfolders(ctx, opts)
+	stack, err := configstack.FindStackInSubfolders(ctx, opts, configstack.WithGraphLabels())
 	if err != nil {
 		return err
 	}
@@ -61,7 +61,10 @@ func RunAllOnStack(ctx context.Context, opts *options.TerragruntOptions, stack *
 
 	switch opts.TerraformCommand {
 	case terraform.CommandNameApply:
-		prompt = "Are you sure you want to run 'terragrunt apply' in each folder of the stack described above?"
+		// With plan-then-apply the confirmation is asked for once the plans are known.
+		if !opts.RunAllPlanThenApply {
+			prompt = "Are you sure you want to run 'terragrunt apply' in each folder of the stack described above?"
+		}
 	case terraform.CommandNameDestroy:
 		prompt = "WARNING: Are you sure you want to run `terragrunt destroy` in each folder of the stack described above? There is no undo!"
 	case terraform.CommandNameState:
@@ -289,7 +289,10 @@ func runTerragruntWithConfig(ctx context.Context, originalTerragruntOptions *opt
 		return err
 	}
 
-	if util.FirstArg(terragruntOptions.TerraformCliArgs) == terraform.CommandNameInit {
+	if len(terragruntOptions.CustomCommand) > 0 {
+		// a custom command runs instead of terraform, so there is no need to initialize terraform
+		terragruntOptions.Logger.Debugf("Running custom command %v instead of terraform", terragruntOptions.CustomCommand)
+	} else if util.FirstArg(terragruntOptions.TerraformCliArgs) == terraform.CommandNameInit {
 		if err := prepareInitCommand(ctx, terragruntOptions, terragruntConfig); err != nil {
 			return err
 		}
@@ -323,6 +326,12 @@ func runTerragruntWithConfig(ctx context.Context, originalTerragruntOptions *opt
 		return err
 	}
 
+	if len(terragruntOptions.CustomCommand) > 0 {
+		return runActionWithHooks(ctx, "custom command", terragruntOptions, terragruntConfig, func(ctx context.Context) error {
+			return shell.RunShellCommand(ctx, terragruntOptions, terragruntOptions.CustomCommand[0], terragruntOptions.CustomCommand[1:]...)
+		})
+	}
+
 	return runActionWithHooks(ctx, "terraform", terragruntOptions, terragruntConfig, func(ctx context.Context) error {
 		runTerraformError := RunTerraformWithRetry(ctx, terragruntOptions)
 
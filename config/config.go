@@ -66,6 +66,9 @@ const (
 	MetadataRetryMaxAttempts            = "retry_max_attempts"
 	MetadataRetrySleepIntervalSec       = "retry_sleep_interval_sec"
 	MetadataDependentModules            = "dependent_modules"
+	MetadataLabels                      = "labels"
+	MetadataFeatures                    = "features"
+	MetadataResourceTags                = "resource_tags"
 	MetadataInclude                     = "include"
 )
 
@@ -114,6 +117,9 @@ type TerragruntConfig struct {
 	RetryMaxAttempts            *int
 	RetrySleepIntervalSec       *int
 	Engine                      *EngineConfig
+	Labels                      map[string]string
+	Features                    map[string]bool
+	ResourceTags                map[string]int
 
 	// Fields used for internal tracking
 	// Indicates whether this is the result of a partial evaluation
@@ -207,6 +213,15 @@ type terragruntConfigFile struct {
 	RetryMaxAttempts      *int     `hcl:"retry_max_attempts,optional"`
 	RetrySleepIntervalSec *int     `hcl:"retry_sleep_interval_sec,optional"`
 
+	// Arbitrary key/value metadata attached to the module, used to select modules in run-all via a module selector.
+	Labels map[string]string `hcl:"labels,optional"`
+
+	// Experimental behaviors of the runner enabled or disabled for the module only, by name.
+	Features map[string]bool `hcl:"features,optional"`
+
+	// Shared finite resources used by the module while it runs, such as API quotas, with the amount of each it uses.
+	ResourceTags map[string]int `hcl:"resource_tags,optional"`
+
 	// This struct is used for validating and parsing the entire terragrunt config. Since locals and include are
 	// evaluated in a completely separate cycle, it should not be evaluated here. Otherwise, we can't support self
 	// referencing other elements in the same block.
@@ -1082,6 +1097,21 @@ func convertToTerragruntConfig(ctx *ParsingContext, configPath string, terragrun
 		terragruntConfig.SetFieldMetadata(MetadataRetryableErrors, defaultMetadata)
 	}
 
+	if terragruntConfigFromFile.Labels != nil {
+		terragruntConfig.Labels = terragruntConfigFromFile.Labels
+		terragruntConfig.SetFieldMetadata(MetadataLabels, defaultMetadata)
+	}
+
+	if terragruntConfigFromFile.Features != nil {
+		terragruntConfig.Features = terragruntConfigFromFile.Features
+		terragruntConfig.SetFieldMetadata(MetadataFeatures, defaultMetadata)
+	}
+
+	if terragruntConfigFromFile.ResourceTags != nil {
+		terragruntConfig.ResourceTags = terragruntConfigFromFile.ResourceTags
+		terragruntConfig.SetFieldMetadata(MetadataResourceTags, defaultMetadata)
+	}
+
 	if terragruntConfigFromFile.RetryMaxAttempts != nil {
 		terragruntConfig.RetryMaxAttempts = terragruntConfigFromFile.RetryMaxAttempts
 		terragruntConfig.SetFieldMetadata(MetadataRetryMaxAttempts, defaultMetadata)
@@ -108,6 +108,33 @@ func TerragruntConfigAsCty(config *TerragruntConfig) (cty.Value, error) {
 		output[MetadataRetryableErrors] = retryableCty
 	}
 
+	labelsCty, err := goTypeToCty(config.Labels)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	if labelsCty != cty.NilVal {
+		output[MetadataLabels] = labelsCty
+	}
+
+	featuresCty, err := goTypeToCty(config.Features)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	if featuresCty != cty.NilVal {
+		output[MetadataFeatures] = featuresCty
+	}
+
+	resourceTagsCty, err := goTypeToCty(config.ResourceTags)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	if resourceTagsCty != cty.NilVal {
+		output[MetadataResourceTags] = resourceTagsCty
+	}
+
 	iamAssumeRoleDurationCty, err := goTypeToCty(config.IamAssumeRoleDuration)
 	if err != nil {
 		return cty.NilVal, err
@@ -209,6 +236,18 @@ func TerragruntConfigAsCtyWithMetadata(config *TerragruntConfig) (cty.Value, err
 		return cty.NilVal, err
 	}
 
+	if err := wrapWithMetadata(config, config.Labels, MetadataLabels, &output); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := wrapWithMetadata(config, config.Features, MetadataFeatures, &output); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := wrapWithMetadata(config, config.ResourceTags, MetadataResourceTags, &output); err != nil {
+		return cty.NilVal, err
+	}
+
 	if err := wrapWithMetadata(config, config.IamAssumeRoleDuration, MetadataIamAssumeRoleDuration, &output); err != nil {
 		return cty.NilVal, err
 	}
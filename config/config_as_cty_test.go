@@ -90,6 +90,15 @@ func TestTerragruntConfigAsCtyDrift(t *testing.T) {
 		Locals: map[string]interface{}{
 			"quote": "the answer is 42",
 		},
+		Labels: map[string]string{
+			"team": "payments",
+		},
+		Features: map[string]bool{
+			"isolate_workdir": true,
+		},
+		ResourceTags: map[string]int{
+			"db_connections": 2,
+		},
 		DependentModulesPath: dependentModulesPath,
 		TerragruntDependencies: config.Dependencies{
 			config.Dependency{
@@ -249,6 +258,12 @@ func terragruntConfigStructFieldToMapKey(t *testing.T, fieldName string) (string
 		return "dependent_modules", true
 	case "Engine":
 		return "engine", true
+	case "Labels":
+		return "labels", true
+	case "Features":
+		return "features", true
+	case "ResourceTags":
+		return "resource_tags", true
 	default:
 		t.Fatalf("Unknown struct property: %s", fieldName)
 		// This should not execute
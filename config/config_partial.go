@@ -32,6 +32,9 @@ const (
 	TerragruntInputs
 	TerragruntVersionConstraints
 	RemoteStateBlock
+	TerragruntLabels
+	TerragruntFeatures
+	TerragruntResourceTags
 )
 
 // terragruntIncludeMultiple is a struct that can be used to only decode the include block with labels.
@@ -95,6 +98,24 @@ type terragruntRemoteState struct {
 	Remain      hcl.Body               `hcl:",remain"`
 }
 
+// terragruntLabels is a struct that can be used to only decode the labels attribute.
+type terragruntLabels struct {
+	Labels map[string]string `hcl:"labels,optional"`
+	Remain hcl.Body          `hcl:",remain"`
+}
+
+// terragruntFeatures is a struct that can be used to only decode the features attribute.
+type terragruntFeatures struct {
+	Features map[string]bool `hcl:"features,optional"`
+	Remain   hcl.Body        `hcl:",remain"`
+}
+
+// terragruntResourceTags is a struct that can be used to only decode the resource_tags attribute.
+type terragruntResourceTags struct {
+	ResourceTags map[string]int `hcl:"resource_tags,optional"`
+	Remain       hcl.Body       `hcl:",remain"`
+}
+
 // terragruntInputs is a struct that can be used to only decode the inputs block.
 type terragruntInputs struct {
 	Inputs *cty.Value `hcl:"inputs,attr"`
@@ -208,6 +229,9 @@ func TerragruntConfigFromPartialConfig(ctx *ParsingContext, file *hclparse.File,
 //   - TerragruntVersionConstraints: Parses the attributes related to constraining terragrunt and terraform versions in
 //     the config.
 //   - RemoteStateBlock: Parses the `remote_state` block in the config
+//   - TerragruntLabels: Parses the `labels` attribute in the config
+//   - TerragruntFeatures: Parses the `features` attribute in the config
+//   - TerragruntResourceTags: Parses the `resource_tags` attribute in the config
 //
 // Note that the following blocks are always decoded:
 // - locals
@@ -415,6 +439,42 @@ func PartialParseConfig(ctx *ParsingContext, file *hclparse.File, includeFromChi
 				output.RemoteState = remoteState
 			}
 
+		case TerragruntLabels:
+			decoded := terragruntLabels{}
+
+			err := file.Decode(&decoded, evalParsingContext)
+			if err != nil {
+				return nil, err
+			}
+
+			if decoded.Labels != nil {
+				output.Labels = decoded.Labels
+			}
+
+		case TerragruntFeatures:
+			decoded := terragruntFeatures{}
+
+			err := file.Decode(&decoded, evalParsingContext)
+			if err != nil {
+				return nil, err
+			}
+
+			if decoded.Features != nil {
+				output.Features = decoded.Features
+			}
+
+		case TerragruntResourceTags:
+			decoded := terragruntResourceTags{}
+
+			err := file.Decode(&decoded, evalParsingContext)
+			if err != nil {
+				return nil, err
+			}
+
+			if decoded.ResourceTags != nil {
+				output.ResourceTags = decoded.ResourceTags
+			}
+
 		default:
 			return nil, InvalidPartialBlockName{decode}
 		}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 
@@ -58,6 +59,9 @@ type Dependency struct {
 	MockOutputsMergeWithState         *bool              `hcl:"mock_outputs_merge_with_state,attr" cty:"mock_outputs_merge_with_state"`
 	MockOutputsMergeStrategyWithState *MergeStrategyType `hcl:"mock_outputs_merge_strategy_with_state" cty:"mock_outputs_merge_strategy_with_state"`
 
+	// OutputKeys restricts the outputs read from the dependency to the given keys. All the outputs are read when unset.
+	OutputKeys *[]string `hcl:"output_keys,attr" cty:"output_keys"`
+
 	// Used to store the rendered outputs for use when the config is imported or read with `read_terragrunt_config`
 	RenderedOutputs *cty.Value `cty:"outputs"`
 	Inputs          *cty.Value `cty:"inputs"`
@@ -69,6 +73,7 @@ type Dependency struct {
 //   - For MockOutputs, the two maps will be deeply merged together. This means that maps are recursively merged, while
 //     lists are concatenated together.
 //   - For MockOutputsAllowedTerraformCommands, the source will be concatenated to the target.
+//   - For OutputKeys, the source will override the target.
 //
 // Note that RenderedOutputs is ignored in the deep merge operation.
 func (dep *Dependency) DeepMerge(sourceDepConfig Dependency) error {
@@ -97,6 +102,10 @@ func (dep *Dependency) DeepMerge(sourceDepConfig Dependency) error {
 		}
 	}
 
+	if sourceDepConfig.OutputKeys != nil {
+		dep.OutputKeys = sourceDepConfig.OutputKeys
+	}
+
 	if sourceDepConfig.MockOutputsAllowedTerraformCommands != nil {
 		if dep.MockOutputsAllowedTerraformCommands == nil {
 			dep.MockOutputsAllowedTerraformCommands = sourceDepConfig.MockOutputsAllowedTerraformCommands
@@ -176,6 +185,12 @@ func (dep *Dependency) setRenderedOutputs(ctx *ParsingContext) error {
 // modules. We use sync.Map to ensure atomic updates during concurrent access.
 var jsonOutputCache = sync.Map{}
 
+// cachedOutputJSON is the output of a dependency stored in jsonOutputCache, with the time at which it was read.
+type cachedOutputJSON struct {
+	jsonBytes []byte
+	readAt    time.Time
+}
+
 // outputLocks is a map that maps config paths to mutex locks to ensure we only have a single instance of terragrunt
 // output running for a given dependent config. We use sync.Map to ensure atomic updates during concurrent access.
 var outputLocks = sync.Map{}
@@ -513,8 +528,8 @@ func getTerragruntOutputIfAppliedElseConfiguredDefault(ctx *ParsingContext, depe
 	// returning mocks is not allowed. So return a useful error message indicating that we expected outputs, but they
 	// did not exist.
 	err := TerragruntOutputTargetNoOutputs{
-		targetConfig:  targetConfig,
-		currentConfig: ctx.TerragruntOptions.TerragruntConfigPath,
+		TargetConfig:  targetConfig,
+		CurrentConfig: ctx.TerragruntOptions.TerragruntConfigPath,
 	}
 
 	return nil, err
@@ -547,10 +562,39 @@ func getTerragruntOutput(ctx *ParsingContext, dependencyConfig Dependency) (*cty
 		return nil, true, errors.New(DependencyConfigNotFound{Path: targetConfigPath})
 	}
 
+	if ctx.TerragruntOptions.DependencyOutputs != nil {
+		if outputs, found := ctx.TerragruntOptions.DependencyOutputs[filepath.Dir(targetConfigPath)]; found {
+			ctx.TerragruntOptions.Logger.Debugf("Using the injected outputs of dependency %s for config %s", targetConfigPath, ctx.TerragruntOptions.TerragruntConfigPath)
+
+			if dependencyConfig.OutputKeys != nil {
+				filteredOutputs := map[string]cty.Value{}
+
+				for key, value := range outputs {
+					if util.ListContainsElement(*dependencyConfig.OutputKeys, key) {
+						filteredOutputs[key] = value
+					}
+				}
+
+				outputs = filteredOutputs
+			}
+
+			outputVal := cty.EmptyObjectVal
+			if len(outputs) > 0 {
+				outputVal = cty.ObjectVal(outputs)
+			}
+
+			return &outputVal, len(outputs) == 0, nil
+		}
+
+		if !ctx.TerragruntOptions.DependencyOutputsFallback {
+			return nil, true, errors.New(DependencyOutputsNotInjectedError{Path: filepath.Dir(targetConfigPath)})
+		}
+	}
+
 	jsonBytes, err := getOutputJSONWithCaching(ctx, targetConfigPath)
 	if err != nil {
 		if !isRenderJSONCommand(ctx) && !isAwsS3NoSuchKey(err) {
-			return nil, true, err
+			return nil, true, errors.New(DependencyOutputReadError{Path: targetConfigPath, Err: err})
 		}
 
 		ctx.TerragruntOptions.Logger.Warnf("Failed to read outputs from %s referenced in %s as %s, fallback to mock outputs. Error: %v", targetConfigPath, ctx.TerragruntOptions.TerragruntConfigPath, dependencyConfig.Name, err)
@@ -563,7 +607,12 @@ func getTerragruntOutput(ctx *ParsingContext, dependencyConfig Dependency) (*cty
 
 	isEmpty := string(jsonBytes) == "{}"
 
-	outputMap, err := TerraformOutputJSONToCtyValueMap(targetConfigPath, jsonBytes)
+	var outputKeys []string
+	if dependencyConfig.OutputKeys != nil {
+		outputKeys = *dependencyConfig.OutputKeys
+	}
+
+	outputMap, err := terraformOutputJSONToCtyValueMap(targetConfigPath, jsonBytes, outputKeys)
 	if err != nil {
 		return nil, isEmpty, err
 	}
@@ -604,12 +653,22 @@ func getOutputJSONWithCaching(ctx *ParsingContext, targetConfig string) ([]byte,
 	// output" log for the dependency.
 	ctx.TerragruntOptions.Logger.Debugf("Getting output of dependency %s for config %s", targetConfig, ctx.TerragruntOptions.TerragruntConfigPath)
 
+	now := time.Now()
+	if ctx.TerragruntOptions.Clock != nil {
+		now = ctx.TerragruntOptions.Clock.Now()
+	}
+
 	// Look up if we have already run terragrunt output for this target config
-	rawJSONBytes, hasRun := jsonOutputCache.Load(targetConfig)
-	if hasRun {
-		// Cache hit, so return cached output
-		ctx.TerragruntOptions.Logger.Debugf("%s was run before. Using cached output.", targetConfig)
-		return rawJSONBytes.([]byte), nil
+	if rawCachedOutput, hasRun := jsonOutputCache.Load(targetConfig); hasRun {
+		cachedOutput := rawCachedOutput.(cachedOutputJSON)
+
+		// Cache hit, so return cached output unless it is older than the TTL
+		if ttl := ctx.TerragruntOptions.OutputCacheTTL; ttl <= 0 || now.Sub(cachedOutput.readAt) < ttl {
+			ctx.TerragruntOptions.Logger.Debugf("%s was run before. Using cached output.", targetConfig)
+			return cachedOutput.jsonBytes, nil
+		}
+
+		ctx.TerragruntOptions.Logger.Debugf("Cached output of %s expired. Reading it again.", targetConfig)
 	}
 
 	// Cache miss, so look up the output and store in cache
@@ -625,7 +684,7 @@ func getOutputJSONWithCaching(ctx *ParsingContext, targetConfig string) ([]byte,
 		newJSONBytes = newJSONBytes[index:]
 	}
 
-	jsonOutputCache.Store(targetConfig, newJSONBytes)
+	jsonOutputCache.Store(targetConfig, cachedOutputJSON{jsonBytes: newJSONBytes, readAt: now})
 
 	return newJSONBytes, nil
 }
@@ -847,7 +906,7 @@ func getTerragruntOutputJSONFromInitFolder(ctx *ParsingContext, terraformWorking
 	jsonString := strings.TrimSpace(out.Stdout.String())
 	jsonBytes := []byte(jsonString)
 
-	ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s", targetConfigPath, jsonString)
+	ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s", targetConfigPath, outputJSONForLog(ctx.TerragruntOptions, jsonBytes))
 
 	return jsonBytes, nil
 }
@@ -909,7 +968,7 @@ func getTerragruntOutputJSONFromRemoteState(
 				return nil, err
 			}
 
-			ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s using s3 bucket", targetTGOptions.TerragruntConfigPath, jsonBytes)
+			ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s using s3 bucket", targetTGOptions.TerragruntConfigPath, outputJSONForLog(ctx.TerragruntOptions, jsonBytes))
 
 			return jsonBytes, nil
 		default:
@@ -953,7 +1012,7 @@ func getTerragruntOutputJSONFromRemoteState(
 
 	jsonString := strings.TrimSpace(out.Stdout.String())
 	jsonBytes := []byte(jsonString)
-	ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s", targetConfigPath, jsonString)
+	ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s", targetConfigPath, outputJSONForLog(ctx.TerragruntOptions, jsonBytes))
 
 	return jsonBytes, nil
 }
@@ -1068,14 +1127,47 @@ func runTerragruntOutputJSON(ctx *ParsingContext, targetConfig string) ([]byte,
 	jsonString := strings.TrimSpace(stdoutBuffer.String())
 	jsonBytes := []byte(jsonString)
 
-	ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s", targetConfig, jsonString)
+	ctx.TerragruntOptions.Logger.Debugf("Retrieved output from %s as json: %s", targetConfig, outputJSONForLog(ctx.TerragruntOptions, jsonBytes))
 
 	return jsonBytes, nil
 }
 
+// outputJSONForLog returns the given terraform output json for logging, with the values of the outputs marked as
+// sensitive replaced by [REDACTED] if RedactSensitiveOutputs is set. An output json that cannot be parsed is redacted
+// as a whole, as its sensitive outputs cannot be told apart.
+func outputJSONForLog(opts *options.TerragruntOptions, jsonBytes []byte) string {
+	if !opts.RedactSensitiveOutputs {
+		return string(jsonBytes)
+	}
+
+	var outputs map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(jsonBytes, &outputs); err != nil {
+		return "[REDACTED]"
+	}
+
+	for _, output := range outputs {
+		if string(output["sensitive"]) == "true" {
+			output["value"] = json.RawMessage(`"[REDACTED]"`)
+		}
+	}
+
+	redactedJSON, err := json.Marshal(outputs)
+	if err != nil {
+		return "[REDACTED]"
+	}
+
+	return string(redactedJSON)
+}
+
 // TerraformOutputJSONToCtyValueMap takes the terraform output json and converts to a mapping between output keys to the
 // parsed cty.Value encoding of the json objects.
 func TerraformOutputJSONToCtyValueMap(targetConfigPath string, jsonBytes []byte) (map[string]cty.Value, error) {
+	return terraformOutputJSONToCtyValueMap(targetConfigPath, jsonBytes, nil)
+}
+
+// terraformOutputJSONToCtyValueMap is TerraformOutputJSONToCtyValueMap restricted to the outputs with the given keys,
+// the values of the other outputs are not parsed. All the outputs are converted if outputKeys is nil.
+func terraformOutputJSONToCtyValueMap(targetConfigPath string, jsonBytes []byte, outputKeys []string) (map[string]cty.Value, error) {
 	// When getting all outputs, terraform returns a json with the data containing metadata about the types, so we
 	// can't quite return the data directly. Instead, we will need further processing to get the output we want.
 	// To do so, we first Unmarshal the json into a simple go map to a OutputMeta struct.
@@ -1095,6 +1187,10 @@ func TerraformOutputJSONToCtyValueMap(targetConfigPath string, jsonBytes []byte)
 	flattenedOutput := map[string]cty.Value{}
 
 	for k, v := range outputs {
+		if outputKeys != nil && !util.ListContainsElement(outputKeys, k) {
+			continue
+		}
+
 		outputType, err := ctyjson.UnmarshalType(v.Type)
 		if err != nil {
 			return nil, errors.New(TerragruntOutputParsingError{Path: targetConfigPath, Err: err})
@@ -1116,6 +1212,12 @@ func ClearOutputCache() {
 	jsonOutputCache = sync.Map{}
 }
 
+// InvalidateOutputCache removes the cached outputs of the dependency with the given config path, so that they are read
+// again the next time. Called once the dependency has run, as running it may change its outputs.
+func InvalidateOutputCache(configPath string) {
+	jsonOutputCache.Delete(util.CleanPath(configPath))
+}
+
 // runTerraformInitForDependencyOutput will run terraform init in a mode that doesn't pull down plugins or modules. Note
 // that this will cause the command to fail for most modules as terraform init does a validation check to make sure the
 // plugins are available, even though we don't need it for our purposes (terraform output does not depend on any of the
@@ -1,12 +1,17 @@
 package config_test
 
 import (
+	"bytes"
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
 
 	"github.com/gruntwork-io/go-commons/env"
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
@@ -150,3 +155,253 @@ dependency "vpc" {
 	require.NoError(t, file.Decode(&decoded, &hcl.EvalContext{}))
 	assert.Len(t, decoded.Dependencies, 2)
 }
+
+func TestParseDependencyBlockInjectedOutputs(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	vpcDir := filepath.Join(tempDir, "vpc")
+	appDir := filepath.Join(tempDir, "app")
+
+	require.NoError(t, os.MkdirAll(vpcDir, os.ModePerm))
+	require.NoError(t, os.MkdirAll(appDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(vpcDir, config.DefaultTerragruntConfigPath), []byte(""), os.ModePerm))
+
+	appConfig := `
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+inputs = {
+  vpc_id = dependency.vpc.outputs.vpc_id
+}
+`
+	appConfigPath := filepath.Join(appDir, config.DefaultTerragruntConfigPath)
+	require.NoError(t, os.WriteFile(appConfigPath, []byte(appConfig), os.ModePerm))
+
+	newParsingContext := func(injected map[string]map[string]cty.Value) *config.ParsingContext {
+		opts, err := options.NewTerragruntOptionsForTest(appConfigPath)
+		require.NoError(t, err)
+
+		opts.TerraformCommand = "plan"
+		// reading the state of the dependency would run this binary and fail
+		opts.TerraformPath = filepath.Join(tempDir, "no-terraform")
+		opts.DependencyOutputs = injected
+
+		return config.NewParsingContext(context.Background(), opts)
+	}
+
+	ctx := newParsingContext(map[string]map[string]cty.Value{
+		vpcDir: {"vpc_id": cty.StringVal("vpc-123")},
+	})
+
+	tfConfig, err := config.ParseConfigFile(ctx, appConfigPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "vpc-123", tfConfig.Inputs["vpc_id"])
+
+	ctx = newParsingContext(map[string]map[string]cty.Value{})
+
+	_, err = config.ParseConfigFile(ctx, appConfigPath, nil)
+
+	var notInjectedErr config.DependencyOutputsNotInjectedError
+	require.ErrorAs(t, err, &notInjectedErr)
+	assert.Equal(t, vpcDir, notInjectedErr.Path)
+}
+
+func TestParseDependencyBlockOutputKeys(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	vpcDir := filepath.Join(tempDir, "vpc")
+	appDir := filepath.Join(tempDir, "app")
+
+	require.NoError(t, os.MkdirAll(vpcDir, os.ModePerm))
+	require.NoError(t, os.MkdirAll(appDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(vpcDir, config.DefaultTerragruntConfigPath), []byte(""), os.ModePerm))
+
+	appConfig := `
+dependency "vpc" {
+  config_path = "../vpc"
+  output_keys = ["vpc_id"]
+}
+
+inputs = {
+  vpc_outputs = dependency.vpc.outputs
+}
+`
+	appConfigPath := filepath.Join(appDir, config.DefaultTerragruntConfigPath)
+	require.NoError(t, os.WriteFile(appConfigPath, []byte(appConfig), os.ModePerm))
+
+	// the type of the unrequested output is invalid, so parsing it would fail
+	outputJSON := `{
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"},
+  "route_tables": {"sensitive": false, "type": "not-a-type", "value": null}
+}`
+
+	opts, err := options.NewTerragruntOptionsForTest(appConfigPath)
+	require.NoError(t, err)
+
+	opts.TerraformCommand = "plan"
+	opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+		_, err := opts.Writer.Write([]byte(outputJSON))
+		return err
+	}
+
+	tfConfig, err := config.ParseConfigFile(config.NewParsingContext(context.Background(), opts), appConfigPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"vpc_id": "vpc-123"}, tfConfig.Inputs["vpc_outputs"])
+
+	// injected outputs are restricted to the requested keys as well
+	opts.DependencyOutputs = map[string]map[string]cty.Value{
+		vpcDir: {"vpc_id": cty.StringVal("vpc-456"), "route_tables": cty.ListValEmpty(cty.String)},
+	}
+
+	tfConfig, err = config.ParseConfigFile(config.NewParsingContext(context.Background(), opts), appConfigPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"vpc_id": "vpc-456"}, tfConfig.Inputs["vpc_outputs"])
+}
+
+func TestParseDependencyBlockRedactsSensitiveOutputs(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	vpcDir := filepath.Join(tempDir, "vpc")
+	appDir := filepath.Join(tempDir, "app")
+
+	require.NoError(t, os.MkdirAll(vpcDir, os.ModePerm))
+	require.NoError(t, os.MkdirAll(appDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(vpcDir, config.DefaultTerragruntConfigPath), []byte(""), os.ModePerm))
+
+	appConfig := `
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+inputs = {
+  vpc_id      = dependency.vpc.outputs.vpc_id
+  db_password = dependency.vpc.outputs.db_password
+}
+`
+	appConfigPath := filepath.Join(appDir, config.DefaultTerragruntConfigPath)
+	require.NoError(t, os.WriteFile(appConfigPath, []byte(appConfig), os.ModePerm))
+
+	outputJSON := `{
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"},
+  "db_password": {"sensitive": true, "type": "string", "value": "hunter2-do-not-log"}
+}`
+
+	for _, redact := range []bool{true, false} {
+		var logs bytes.Buffer
+
+		formatter := format.NewFormatter()
+		formatter.DisableColors = true
+
+		opts, err := options.NewTerragruntOptionsForTest(appConfigPath)
+		require.NoError(t, err)
+
+		opts.TerraformCommand = "plan"
+		opts.Logger = log.New(log.WithOutput(&logs), log.WithLevel(log.DebugLevel), log.WithFormatter(formatter))
+		opts.RedactSensitiveOutputs = redact
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			_, err := opts.Writer.Write([]byte(outputJSON))
+			return err
+		}
+
+		// the outputs are cached by dependency, so every run reads them afresh
+		config.InvalidateOutputCache(filepath.Join(vpcDir, config.DefaultTerragruntConfigPath))
+
+		tfConfig, err := config.ParseConfigFile(config.NewParsingContext(context.Background(), opts), appConfigPath, nil)
+		require.NoError(t, err)
+
+		// the dependent still gets the sensitive value
+		assert.Equal(t, "hunter2-do-not-log", tfConfig.Inputs["db_password"])
+		assert.Equal(t, "vpc-123", tfConfig.Inputs["vpc_id"])
+
+		assert.Contains(t, logs.String(), "vpc-123")
+
+		if redact {
+			assert.NotContains(t, logs.String(), "hunter2-do-not-log")
+			assert.Contains(t, logs.String(), "[REDACTED]")
+		} else {
+			assert.Contains(t, logs.String(), "hunter2-do-not-log")
+		}
+	}
+}
+
+// manualClock is a Clock whose time only moves when set.
+type manualClock struct {
+	options.RealClock
+	now time.Time
+}
+
+func (clock *manualClock) Now() time.Time {
+	return clock.now
+}
+
+func TestParseDependencyBlockOutputCacheTTL(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	vpcDir := filepath.Join(tempDir, "vpc")
+	appDir := filepath.Join(tempDir, "app")
+
+	require.NoError(t, os.MkdirAll(vpcDir, os.ModePerm))
+	require.NoError(t, os.MkdirAll(appDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(vpcDir, config.DefaultTerragruntConfigPath), []byte(""), os.ModePerm))
+
+	appConfig := `
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+inputs = {
+  vpc_id = dependency.vpc.outputs.vpc_id
+}
+`
+	appConfigPath := filepath.Join(appDir, config.DefaultTerragruntConfigPath)
+	require.NoError(t, os.WriteFile(appConfigPath, []byte(appConfig), os.ModePerm))
+
+	clock := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	reads := 0
+
+	opts, err := options.NewTerragruntOptionsForTest(appConfigPath)
+	require.NoError(t, err)
+
+	opts.TerraformCommand = "plan"
+	opts.Clock = clock
+	opts.OutputCacheTTL = time.Minute
+	opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+		reads++
+		_, err := opts.Writer.Write([]byte(`{"vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"}}`))
+
+		return err
+	}
+
+	parse := func() {
+		tfConfig, err := config.ParseConfigFile(config.NewParsingContext(context.Background(), opts), appConfigPath, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "vpc-123", tfConfig.Inputs["vpc_id"])
+	}
+
+	parse()
+	assert.Equal(t, 1, reads)
+
+	// within the TTL, the cached outputs are used
+	clock.now = clock.now.Add(30 * time.Second)
+	parse()
+	assert.Equal(t, 1, reads)
+
+	// after the TTL, the outputs are read from the state again
+	clock.now = clock.now.Add(time.Minute)
+	parse()
+	assert.Equal(t, 2, reads)
+
+	// the outputs of a dependency that ran are read again even within the TTL
+	config.InvalidateOutputCache(filepath.Join(vpcDir, config.DefaultTerragruntConfigPath))
+	parse()
+	assert.Equal(t, 3, reads)
+}
@@ -198,6 +198,14 @@ func (err DependencyConfigNotFound) Error() string {
 	return err.Path + " does not exist"
 }
 
+type DependencyOutputsNotInjectedError struct {
+	Path string
+}
+
+func (err DependencyOutputsNotInjectedError) Error() string {
+	return fmt.Sprintf("The outputs of dependency %s are missing from the injected dependency outputs.", err.Path)
+}
+
 type TerragruntOutputParsingError struct {
 	Path string
 	Err  error
@@ -225,16 +233,31 @@ func (err TerragruntOutputListEncodingError) Error() string {
 	return fmt.Sprintf("Could not encode output from list of terragrunt configs %v. Underlying error: %s", err.Paths, err.Err)
 }
 
+// DependencyOutputReadError is returned when the outputs of a dependency cannot be read, e.g. because its state is
+// unreachable. It keeps the message of the underlying error and only records the dependency it was read from.
+type DependencyOutputReadError struct {
+	Path string
+	Err  error
+}
+
+func (err DependencyOutputReadError) Error() string {
+	return err.Err.Error()
+}
+
+func (err DependencyOutputReadError) Unwrap() error {
+	return err.Err
+}
+
 type TerragruntOutputTargetNoOutputs struct {
-	targetConfig  string
-	currentConfig string
+	TargetConfig  string
+	CurrentConfig string
 }
 
 func (err TerragruntOutputTargetNoOutputs) Error() string {
 	return fmt.Sprintf(
 		"%s is a dependency of %s but detected no outputs. Either the target module has not been applied yet, or the module has no outputs. If this is expected, set the skip_outputs flag to true on the dependency block.",
-		err.targetConfig,
-		err.currentConfig,
+		err.TargetConfig,
+		err.CurrentConfig,
 	)
 }
 
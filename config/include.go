@@ -307,6 +307,18 @@ func (cfg *TerragruntConfig) Merge(sourceConfig *TerragruntConfig, terragruntOpt
 		cfg.RetryableErrors = sourceConfig.RetryableErrors
 	}
 
+	if sourceConfig.Labels != nil {
+		cfg.Labels = sourceConfig.Labels
+	}
+
+	if sourceConfig.Features != nil {
+		cfg.Features = sourceConfig.Features
+	}
+
+	if sourceConfig.ResourceTags != nil {
+		cfg.ResourceTags = sourceConfig.ResourceTags
+	}
+
 	// Merge the generate configs. This is a shallow merge. Meaning, if the child has the same name generate block, then the
 	// child's generate block will override the parent's block.
 
@@ -442,6 +454,39 @@ func (cfg *TerragruntConfig) DeepMerge(sourceConfig *TerragruntConfig, terragrun
 		cfg.RetryableErrors = append(cfg.RetryableErrors, sourceConfig.RetryableErrors...)
 	}
 
+	// Labels are merged by key, with the child labels taking precedence.
+	if sourceConfig.Labels != nil {
+		if cfg.Labels == nil {
+			cfg.Labels = map[string]string{}
+		}
+
+		for key, val := range sourceConfig.Labels {
+			cfg.Labels[key] = val
+		}
+	}
+
+	// Features are merged by name, with the child features taking precedence.
+	if sourceConfig.Features != nil {
+		if cfg.Features == nil {
+			cfg.Features = map[string]bool{}
+		}
+
+		for name, enabled := range sourceConfig.Features {
+			cfg.Features[name] = enabled
+		}
+	}
+
+	// Resource tags are merged by tag, with the child costs taking precedence.
+	if sourceConfig.ResourceTags != nil {
+		if cfg.ResourceTags == nil {
+			cfg.ResourceTags = map[string]int{}
+		}
+
+		for tag, cost := range sourceConfig.ResourceTags {
+			cfg.ResourceTags[tag] = cost
+		}
+	}
+
 	// Handle complex structs by recursively merging the structs together
 	if sourceConfig.Terraform != nil {
 		if cfg.Terraform == nil {
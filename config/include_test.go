@@ -152,6 +152,26 @@ func TestMergeConfigIntoIncludedConfig(t *testing.T) {
 			&config.TerragruntConfig{Terraform: &config.TerraformConfig{IncludeInCopy: &[]string{"abc"}}},
 			&config.TerragruntConfig{Terraform: &config.TerraformConfig{CopyTerraformLockFile: &[]bool{false}[0], IncludeInCopy: &[]string{"abc"}}},
 		},
+		{
+			&config.TerragruntConfig{},
+			&config.TerragruntConfig{Features: map[string]bool{"isolate_workdir": true}},
+			&config.TerragruntConfig{Features: map[string]bool{"isolate_workdir": true}},
+		},
+		{
+			&config.TerragruntConfig{Features: map[string]bool{"prefix_include_level": true}},
+			&config.TerragruntConfig{Features: map[string]bool{"isolate_workdir": true}},
+			&config.TerragruntConfig{Features: map[string]bool{"prefix_include_level": true}},
+		},
+		{
+			&config.TerragruntConfig{},
+			&config.TerragruntConfig{ResourceTags: map[string]int{"github_api": 1}},
+			&config.TerragruntConfig{ResourceTags: map[string]int{"github_api": 1}},
+		},
+		{
+			&config.TerragruntConfig{ResourceTags: map[string]int{"db_connections": 2}},
+			&config.TerragruntConfig{ResourceTags: map[string]int{"github_api": 1}},
+			&config.TerragruntConfig{ResourceTags: map[string]int{"db_connections": 2}},
+		},
 	}
 
 	for _, testCase := range testCases {
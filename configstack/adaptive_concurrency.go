@@ -0,0 +1,69 @@
+package configstack
+
+import (
+	"strconv"
+
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+const (
+	// Number of the last modules that ran whose failure rate drives the AdaptiveConcurrency.
+	adaptiveConcurrencyWindow = 10
+	// Number of modules that have to run before the concurrency adapts, so that a single early failure doesn't halve
+	// it.
+	adaptiveConcurrencyMinOutcomes = 4
+	// Failure rate above which the concurrency is halved.
+	adaptiveConcurrencyMaxFailureRate = 0.5
+)
+
+// adaptConcurrency records the outcome of the given module that a worker has run and, with AdaptiveConcurrency, halves
+// the concurrency limit if more than half of the last modules that ran failed, or grows it back by one if the module
+// succeeded and they fail less often, emitting a RunEventConcurrencyChanged event when it changes.
+func (scheduler *scheduler) adaptConcurrency(result workerResult) {
+	if !scheduler.opts.AdaptiveConcurrency {
+		return
+	}
+
+	failed := result.err != nil
+
+	scheduler.outcomes = append(scheduler.outcomes, failed)
+	if len(scheduler.outcomes) > adaptiveConcurrencyWindow {
+		scheduler.outcomes = scheduler.outcomes[1:]
+	}
+
+	if len(scheduler.outcomes) < adaptiveConcurrencyMinOutcomes {
+		return
+	}
+
+	failures := 0
+
+	for _, outcome := range scheduler.outcomes {
+		if outcome {
+			failures++
+		}
+	}
+
+	limit := scheduler.adaptiveLimit
+
+	failureRate := float64(failures) / float64(len(scheduler.outcomes))
+	if failureRate > adaptiveConcurrencyMaxFailureRate {
+		if failed {
+			limit = max(limit/2, 1) //nolint:mnd
+		}
+	} else if !failed {
+		limit = min(limit+1, scheduler.workers)
+	}
+
+	if limit == scheduler.adaptiveLimit {
+		return
+	}
+
+	if limit < scheduler.adaptiveLimit {
+		scheduler.opts.Logger.Warnf("%d of the last %d modules failed, lowering the concurrency to %d", failures, len(scheduler.outcomes), limit)
+	} else {
+		scheduler.opts.Logger.Infof("%d of the last %d modules failed, raising the concurrency to %d", failures, len(scheduler.outcomes), limit)
+	}
+
+	scheduler.adaptiveLimit = limit
+	scheduler.emit(options.RunEvent{Type: options.RunEventConcurrencyChanged, Path: result.module.Module.Path, Message: strconv.Itoa(limit)})
+}
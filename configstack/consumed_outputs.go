@@ -0,0 +1,131 @@
+package configstack
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// allOutputsKey stands for all the outputs of a dependency, in the ConsumedOutputs of the modules that reference the
+// outputs of a dependency as a whole, e.g. dependency.vpc.outputs.
+const allOutputsKey = "*"
+
+// consumedOutputs returns the output keys that the module at the given path reads from each of the given dependency
+// blocks of its config, by the path of the dependency, from the references to dependency.<name>.outputs.<key> in the
+// config file at the given path. References in the files it includes are not looked at, nor are the configs written
+// in JSON.
+func consumedOutputs(configPath, modulePath string, dependencies []config.Dependency) (map[string][]string, error) {
+	dependencyPaths := map[string]string{}
+
+	for _, dependency := range dependencies {
+		if (dependency.Enabled != nil && !*dependency.Enabled) || dependency.ConfigPath.Type() != cty.String || !dependency.ConfigPath.IsWhollyKnown() {
+			continue
+		}
+
+		dependencyPath, err := util.CanonicalPath(dependency.ConfigPath.AsString(), modulePath)
+		if err != nil {
+			return nil, err
+		}
+
+		dependencyPaths[dependency.Name] = dependencyPath
+	}
+
+	if len(dependencyPaths) == 0 || filepath.Ext(configPath) == ".json" {
+		return nil, nil
+	}
+
+	file, diags := hclparse.NewParser().ParseHCLFile(configPath)
+	if diags.HasErrors() {
+		return nil, errors.New(diags)
+	}
+
+	body, isSyntaxBody := file.Body.(*hclsyntax.Body)
+	if !isSyntaxBody {
+		return nil, nil
+	}
+
+	keys := map[string]map[string]bool{}
+
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics { //nolint:errcheck
+		expr, isTraversal := node.(*hclsyntax.ScopeTraversalExpr)
+		if !isTraversal {
+			return nil
+		}
+
+		name, key, found := dependencyOutputReference(expr.Traversal)
+		if !found {
+			return nil
+		}
+
+		dependencyPath, isDependency := dependencyPaths[name]
+		if !isDependency {
+			return nil
+		}
+
+		if keys[dependencyPath] == nil {
+			keys[dependencyPath] = map[string]bool{}
+		}
+
+		keys[dependencyPath][key] = true
+
+		return nil
+	})
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	consumed := make(map[string][]string, len(keys))
+
+	for dependencyPath, dependencyKeys := range keys {
+		for key := range dependencyKeys {
+			consumed[dependencyPath] = append(consumed[dependencyPath], key)
+		}
+
+		sort.Strings(consumed[dependencyPath])
+	}
+
+	return consumed, nil
+}
+
+// dependencyOutputReference returns the name of the dependency block and the output key referenced by the given
+// traversal, if it is of the form dependency.<name>.outputs.<key> or dependency.<name>.outputs["<key>"], with
+// allOutputsKey as the key if it references the outputs as a whole.
+func dependencyOutputReference(traversal hcl.Traversal) (string, string, bool) {
+	const outputsStep = 2
+
+	if len(traversal) <= outputsStep || traversal.RootName() != "dependency" {
+		return "", "", false
+	}
+
+	name, isAttr := traversal[1].(hcl.TraverseAttr)
+	if !isAttr {
+		return "", "", false
+	}
+
+	if outputs, isAttr := traversal[outputsStep].(hcl.TraverseAttr); !isAttr || outputs.Name != "outputs" {
+		return "", "", false
+	}
+
+	if len(traversal) == outputsStep+1 {
+		return name.Name, allOutputsKey, true
+	}
+
+	switch step := traversal[outputsStep+1].(type) {
+	case hcl.TraverseAttr:
+		return name.Name, step.Name, true
+	case hcl.TraverseIndex:
+		if step.Key.Type() == cty.String {
+			return name.Name, step.Key.AsString(), true
+		}
+	}
+
+	return name.Name, allOutputsKey, true
+}
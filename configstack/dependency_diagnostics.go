@@ -0,0 +1,118 @@
+package configstack
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+)
+
+// DependencyDiagnosticReason is the category of the reason why a dependency of a module could not be resolved.
+type DependencyDiagnosticReason string
+
+const (
+	// DependencyMissingOutput is the reason of a dependency that has no outputs, e.g. because it has not been applied
+	// yet, or whose outputs were not injected into the run.
+	DependencyMissingOutput DependencyDiagnosticReason = "missing-output"
+	// DependencyPathNotFound is the reason of a dependency whose config does not exist.
+	DependencyPathNotFound DependencyDiagnosticReason = "path-not-found"
+	// DependencyBackendAuth is the reason of a dependency whose state could not be read because access to its backend
+	// was denied.
+	DependencyBackendAuth DependencyDiagnosticReason = "backend-auth"
+	// DependencyUnreadableState is the reason of a dependency whose state or outputs could not be read for another
+	// reason.
+	DependencyUnreadableState DependencyDiagnosticReason = "unreadable-state"
+)
+
+// backendAuthErrorCodes are the codes of the AWS errors caused by missing or invalid credentials.
+var backendAuthErrorCodes = map[string]bool{
+	"AccessDenied":          true,
+	"ExpiredToken":          true,
+	"Forbidden":             true,
+	"InvalidAccessKeyId":    true,
+	"InvalidClientTokenId":  true,
+	"NoCredentialProviders": true,
+	"SignatureDoesNotMatch": true,
+}
+
+// DependencyDiagnostic describes a dependency of a module that could not be resolved during a run.
+type DependencyDiagnostic struct {
+	// Path of the module whose dependency could not be resolved.
+	ModulePath string
+	// Path of the directory of the dependency.
+	DependencyPath string
+	Reason         DependencyDiagnosticReason
+	Err            error
+}
+
+// dependencyDiagnostics returns the diagnostics of the modules of the summary that failed because one of their
+// dependencies could not be resolved, sorted by module path and dependency path. The modules blocked by a failed
+// module are left out, as their error is the one of the failed module.
+func (summary RunSummary) dependencyDiagnostics() []DependencyDiagnostic {
+	var diagnostics []DependencyDiagnostic
+
+	for path, result := range summary.Modules {
+		if result.Status != ModuleFailed {
+			continue
+		}
+
+		for _, err := range errors.UnwrapMultiErrors(result.Err) {
+			if diagnostic, ok := newDependencyDiagnostic(path, err); ok {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+	}
+
+	sort.Slice(diagnostics, func(i, j int) bool {
+		if diagnostics[i].ModulePath != diagnostics[j].ModulePath {
+			return diagnostics[i].ModulePath < diagnostics[j].ModulePath
+		}
+
+		return diagnostics[i].DependencyPath < diagnostics[j].DependencyPath
+	})
+
+	return diagnostics
+}
+
+// newDependencyDiagnostic categorizes the given error of the module with the given path, returning false if it is not
+// an error resolving a dependency.
+func newDependencyDiagnostic(modulePath string, err error) (DependencyDiagnostic, bool) {
+	diagnostic := DependencyDiagnostic{ModulePath: modulePath, Err: err}
+
+	var (
+		noOutputsErr   config.TerragruntOutputTargetNoOutputs
+		notInjectedErr config.DependencyOutputsNotInjectedError
+		notFoundErr    config.DependencyConfigNotFound
+		parsingErr     config.TerragruntOutputParsingError
+		readErr        config.DependencyOutputReadError
+	)
+
+	switch {
+	case errors.As(err, &noOutputsErr):
+		diagnostic.DependencyPath = filepath.Dir(noOutputsErr.TargetConfig)
+		diagnostic.Reason = DependencyMissingOutput
+	case errors.As(err, &notInjectedErr):
+		diagnostic.DependencyPath = notInjectedErr.Path
+		diagnostic.Reason = DependencyMissingOutput
+	case errors.As(err, &notFoundErr):
+		diagnostic.DependencyPath = filepath.Dir(notFoundErr.Path)
+		diagnostic.Reason = DependencyPathNotFound
+	case errors.As(err, &parsingErr):
+		diagnostic.DependencyPath = filepath.Dir(parsingErr.Path)
+		diagnostic.Reason = DependencyUnreadableState
+	case errors.As(err, &readErr):
+		diagnostic.DependencyPath = filepath.Dir(readErr.Path)
+		diagnostic.Reason = DependencyUnreadableState
+
+		var awsErr awserr.Error
+		if errors.As(readErr.Err, &awsErr) && backendAuthErrorCodes[awsErr.Code()] {
+			diagnostic.Reason = DependencyBackendAuth
+		}
+	default:
+		return diagnostic, false
+	}
+
+	return diagnostic, true
+}
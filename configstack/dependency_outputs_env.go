@@ -0,0 +1,112 @@
+package configstack
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// dependencyOutputEnvPrefix is the prefix of the env vars through which terraform reads the value of its variables.
+const dependencyOutputEnvPrefix = "TF_VAR_"
+
+// envNameUnsafeChars matches the characters that can't be part of the name of an env var.
+var envNameUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// dependencyOutputEnvName returns the name of the env var through which the given output of the module at the given
+// path is passed to its dependents, e.g. TF_VAR_vpc_id for the output id of the module at live/vpc.
+func dependencyOutputEnvName(modulePath, output string) string {
+	moduleName := envNameUnsafeChars.ReplaceAllString(filepath.Base(modulePath), "_")
+	output = envNameUnsafeChars.ReplaceAllString(output, "_")
+
+	return dependencyOutputEnvPrefix + moduleName + "_" + output
+}
+
+// outputEnv reads the terraform outputs of the module and returns them as the env vars passed to its dependents with
+// DependencyOutputsAsEnv. Outputs of type string are passed as is, others as JSON, which terraform parses as the value
+// of variables of complex types.
+func (module *TerraformModule) outputEnv(ctx context.Context) (map[string]string, error) {
+	outputsJSON, err := module.terraformOutputs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs map[string]struct {
+		Value json.RawMessage `json:"value"`
+	}
+
+	if err := json.Unmarshal(outputsJSON, &outputs); err != nil {
+		return nil, errors.New(err)
+	}
+
+	env := make(map[string]string, len(outputs))
+
+	for output, value := range outputs {
+		var str string
+		if err := json.Unmarshal(value.Value, &str); err != nil {
+			str = string(value.Value)
+		}
+
+		env[dependencyOutputEnvName(module.Path, output)] = str
+	}
+
+	return env, nil
+}
+
+// optionsWithDependencyOutputs returns the given options of the module with the outputs of its dependencies that ran
+// in this run set in their env, in a copy of the options if there are any. Env vars already set in the options are
+// kept as is. Returns a DependencyOutputEnvCollisionError if the outputs of several dependencies are passed as the
+// same env var.
+func (module *RunningModule) optionsWithDependencyOutputs(opts *options.TerragruntOptions) (*options.TerragruntOptions, error) {
+	env := map[string]string{}
+	sources := map[string][]string{}
+
+	for dependencyPath, dependencyEnv := range module.dependencyOutputEnv {
+		for name, value := range dependencyEnv {
+			env[name] = value
+			sources[name] = append(sources[name], dependencyPath)
+		}
+	}
+
+	if len(env) == 0 {
+		return opts, nil
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if len(sources[name]) > 1 {
+			sort.Strings(sources[name])
+			return nil, errors.New(DependencyOutputEnvCollisionError{Module: module.Module, Name: name, Dependencies: sources[name]})
+		}
+	}
+
+	envOptions, err := opts.Clone(opts.TerragruntConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if envOptions.Env == nil {
+		envOptions.Env = map[string]string{}
+	}
+
+	for _, name := range names {
+		if _, ok := envOptions.Env[name]; ok {
+			module.Module.TerragruntOptions.Logger.Debugf("Env var %s is already set for module %s, not passing the output of module %s", name, module.Module.Path, sources[name][0])
+			continue
+		}
+
+		envOptions.Env[name] = env[name]
+	}
+
+	return envOptions, nil
+}
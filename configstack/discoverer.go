@@ -0,0 +1,27 @@
+package configstack
+
+import (
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// Discoverer finds the Terragrunt configuration files of the modules of a stack.
+type Discoverer interface {
+	// FindConfigFiles returns the paths of the configuration files of the modules in the given directory and its
+	// subdirectories.
+	FindConfigFiles(rootPath string, terragruntOptions *options.TerragruntOptions) ([]string, error)
+	// ConfigExists returns true if there is a configuration file at the given path.
+	ConfigExists(configPath string) bool
+}
+
+// fileSystemDiscoverer is the Discoverer of the stacks, which looks for the configuration files on the file system.
+type fileSystemDiscoverer struct{}
+
+func (fileSystemDiscoverer) FindConfigFiles(rootPath string, terragruntOptions *options.TerragruntOptions) ([]string, error) {
+	return config.FindConfigFilesInPath(rootPath, terragruntOptions)
+}
+
+func (fileSystemDiscoverer) ConfigExists(configPath string) bool {
+	return util.FileExists(configPath)
+}
@@ -0,0 +1,197 @@
+package configstack
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// checkEstimatedDuration returns an EstimatedDurationExceededError if the run of the modules with the given parallelism
+// is estimated to take longer than the MaxEstimatedDuration of the options, from the durations in the given history.
+// Nothing is checked if none of the modules is in the history.
+func (modules RunningModules) checkEstimatedDuration(opts *options.TerragruntOptions, history durationHistory, parallelism int) error {
+	if opts.MaxEstimatedDuration <= 0 {
+		return nil
+	}
+
+	durations := modules.historicalDurations(history)
+	if durations == nil {
+		opts.Logger.Debugf("No duration history for the modules, the duration of the run is not estimated")
+		return nil
+	}
+
+	estimate := modules.estimateDuration(durations, parallelism)
+	criticalPath, criticalPathDuration := modules.estimatedCriticalPath(durations)
+
+	opts.Logger.Debugf("Estimated duration of the run: %s, of which %s for the critical path %v", estimate, criticalPathDuration, criticalPath)
+
+	if estimate > opts.MaxEstimatedDuration {
+		return errors.New(EstimatedDurationExceededError{
+			Estimate:             estimate,
+			Budget:               opts.MaxEstimatedDuration,
+			CriticalPath:         criticalPath,
+			CriticalPathDuration: criticalPathDuration,
+		})
+	}
+
+	return nil
+}
+
+// historicalDurations returns the duration of every module of the run in the given history, zero for the modules that
+// won't run or are not in the history, or nil if none of the modules is in the history.
+func (modules RunningModules) historicalDurations(history durationHistory) map[string]time.Duration {
+	durations := make(map[string]time.Duration, len(modules))
+	found := false
+
+	for path, module := range modules {
+		entry, inHistory := history.Modules[path]
+		found = found || inHistory
+
+		if inHistory && !module.FlagExcluded && !module.Module.AssumeAlreadyApplied {
+			durations[path] = time.Duration(entry.DurationMs) * time.Millisecond
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return durations
+}
+
+// estimateDuration simulates the run of the modules with the given durations and parallelism, starting the modules in
+// the order of their paths as soon as their dependencies are done and a slot is free, and returns its duration.
+func (modules RunningModules) estimateDuration(durations map[string]time.Duration, parallelism int) time.Duration {
+	remaining := make(map[string]int, len(modules))
+	dependents := map[string][]string{}
+
+	var ready []string
+
+	for path, module := range modules {
+		for dependencyPath := range module.Dependencies {
+			if _, inRun := modules[dependencyPath]; inRun {
+				remaining[path]++
+				dependents[dependencyPath] = append(dependents[dependencyPath], path)
+			}
+		}
+
+		if remaining[path] == 0 {
+			ready = append(ready, path)
+		}
+	}
+
+	type runningModule struct {
+		path string
+		end  time.Duration
+	}
+
+	var (
+		now     time.Duration
+		running []runningModule
+	)
+
+	for {
+		sort.Strings(ready)
+
+		for len(ready) > 0 && len(running) < parallelism {
+			running = append(running, runningModule{path: ready[0], end: now + durations[ready[0]]})
+			ready = ready[1:]
+		}
+
+		if len(running) == 0 {
+			return now
+		}
+
+		sort.Slice(running, func(i, j int) bool {
+			if running[i].end != running[j].end {
+				return running[i].end < running[j].end
+			}
+
+			return running[i].path < running[j].path
+		})
+
+		done := running[0]
+		running = running[1:]
+		now = done.end
+
+		for _, dependent := range dependents[done.path] {
+			if remaining[dependent]--; remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+}
+
+// estimatedCriticalPath returns the longest chain of dependent modules by total duration of its modules, with that
+// duration. Of the chains of the same duration, the one with the lowest paths is returned.
+func (modules RunningModules) estimatedCriticalPath(durations map[string]time.Duration) ([]string, time.Duration) {
+	type chain struct {
+		// dependency preceding the last module of the chain, empty if the chain is a single module
+		previous string
+		duration time.Duration
+	}
+
+	// longest chain ending at each module, memoized
+	chains := map[string]chain{}
+
+	var longest func(path string) chain
+
+	longest = func(path string) chain {
+		if chain, found := chains[path]; found {
+			return chain
+		}
+
+		current := chain{duration: durations[path]}
+
+		dependencyPaths := make([]string, 0, len(modules[path].Dependencies))
+		for dependencyPath := range modules[path].Dependencies {
+			if _, inRun := modules[dependencyPath]; inRun {
+				dependencyPaths = append(dependencyPaths, dependencyPath)
+			}
+		}
+
+		sort.Strings(dependencyPaths)
+
+		var longestDependency chain
+
+		for _, dependencyPath := range dependencyPaths {
+			if dependencyChain := longest(dependencyPath); longestDependency.previous == "" || dependencyChain.duration > longestDependency.duration {
+				longestDependency = chain{previous: dependencyPath, duration: dependencyChain.duration}
+			}
+		}
+
+		current.previous = longestDependency.previous
+		current.duration += longestDependency.duration
+		chains[path] = current
+
+		return current
+	}
+
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	var (
+		last     string
+		duration time.Duration
+	)
+
+	for _, path := range paths {
+		if chain := longest(path); last == "" || chain.duration > duration {
+			last, duration = path, chain.duration
+		}
+	}
+
+	var criticalPath []string
+
+	for path := last; path != ""; path = chains[path].previous {
+		criticalPath = append([]string{path}, criticalPath...)
+	}
+
+	return criticalPath, duration
+}
@@ -0,0 +1,134 @@
+package configstack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// durationHistory is the JSON form of the DurationHistoryFile of the options, with the duration of the last successful
+// run of every module, in milliseconds:
+//
+//	{
+//	  "modules": {
+//	    "/stack/vpc": {"duration_ms": 12000}
+//	  }
+//	}
+type durationHistory struct {
+	Modules map[string]durationHistoryEntry `json:"modules"`
+}
+
+type durationHistoryEntry struct {
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// DurationRegression is a module that took more than DurationRegressionThreshold percent longer to run than its
+// duration in the DurationHistoryFile.
+type DurationRegression struct {
+	Path     string
+	Baseline time.Duration
+	Duration time.Duration
+	// Increase of the duration over the baseline, in percent.
+	Increase float64
+}
+
+// readDurationHistory reads the duration history from the given file, returning an empty history if the file does not
+// exist yet.
+func readDurationHistory(path string) (durationHistory, error) {
+	history := durationHistory{Modules: map[string]durationHistoryEntry{}}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+
+	if err != nil {
+		return history, errors.New(err)
+	}
+
+	if err := json.Unmarshal(contents, &history); err != nil {
+		return history, errors.New(err)
+	}
+
+	if history.Modules == nil {
+		history.Modules = map[string]durationHistoryEntry{}
+	}
+
+	return history, nil
+}
+
+// write writes the duration history to the given file as JSON.
+func (history durationHistory) write(path string) error {
+	contents, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return errors.New(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	if err := os.WriteFile(path, append(contents, '\n'), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// flagDurationRegressions flags the modules that ran successfully and took more than the DurationRegressionThreshold
+// of the options longer than their duration in the given history, logging a warning for each of them.
+func (modules RunningModules) flagDurationRegressions(opts *options.TerragruntOptions, history durationHistory) {
+	threshold := opts.DurationRegressionThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	for path, module := range modules {
+		entry, found := history.Modules[path]
+		if !found || entry.DurationMs <= 0 || !module.ranSuccessfully() {
+			continue
+		}
+
+		baseline := time.Duration(entry.DurationMs) * time.Millisecond
+		duration := module.EndTime.Sub(module.StartTime)
+
+		increase := (float64(duration) - float64(baseline)) / float64(baseline) * 100 //nolint:mnd
+		if increase <= threshold {
+			continue
+		}
+
+		module.durationRegression = &DurationRegression{Path: path, Baseline: baseline, Duration: duration, Increase: increase}
+		module.Module.TerragruntOptions.Logger.Warnf("Module %s took %s, %.0f%% longer than the %s of its last successful run", path, duration, increase, baseline)
+	}
+}
+
+// update records the durations of the modules that ran successfully in the history.
+func (history durationHistory) update(modules RunningModules) {
+	for path, module := range modules {
+		if module.ranSuccessfully() {
+			history.Modules[path] = durationHistoryEntry{DurationMs: module.EndTime.Sub(module.StartTime).Milliseconds()}
+		}
+	}
+}
+
+// durationRegressions returns the regressions flagged on the modules of the run, sorted by path.
+func (modules RunningModules) durationRegressions() []DurationRegression {
+	var regressions []DurationRegression
+
+	for _, module := range modules {
+		if module.durationRegression != nil {
+			regressions = append(regressions, *module.durationRegression)
+		}
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		return regressions[i].Path < regressions[j].Path
+	})
+
+	return regressions
+}
@@ -0,0 +1,77 @@
+package configstack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// redactedValue replaces the values of sensitive environment variables in the effective options.
+const redactedValue = "<redacted>"
+
+// sensitiveEnvNameRegex matches the names of the environment variables whose values are redacted from the effective
+// options, such as AWS_SECRET_ACCESS_KEY or GITHUB_TOKEN.
+var sensitiveEnvNameRegex = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|CREDENTIAL|PRIVATE_KEY|ACCESS_KEY|API_KEY)`)
+
+// logEffectiveOptions logs, for each module to run, a normalized snapshot of the options the module runs with, so that
+// differences in behavior between modules can be traced back to differences in their options.
+func (modules RunningModules) logEffectiveOptions(parallelism int) {
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		module := modules[path].Module
+		module.TerragruntOptions.Logger.Infof("Effective options of module %s: %s", module.Path, effectiveOptions(module.TerragruntOptions, parallelism))
+	}
+}
+
+// effectiveOptions renders the key options as key=value pairs, with the values of sensitive environment
+// variables redacted.
+func effectiveOptions(opts *options.TerragruntOptions, parallelism int) string {
+	settings := []string{
+		fmt.Sprintf("parallelism=%d", parallelism),
+		fmt.Sprintf("working-dir=%s", opts.WorkingDir),
+		fmt.Sprintf("download-dir=%s", opts.DownloadDir),
+		fmt.Sprintf("source=%s", opts.Source),
+		fmt.Sprintf("terraform-path=%s", opts.TerraformPath),
+		fmt.Sprintf("terraform-args=%v", opts.TerraformCliArgs),
+		fmt.Sprintf("exclude-dirs=%v", opts.ExcludeDirs),
+		fmt.Sprintf("include-dirs=%v", opts.IncludeDirs),
+		fmt.Sprintf("non-interactive=%t", opts.NonInteractive),
+		fmt.Sprintf("ignore-dependency-errors=%t", opts.IgnoreDependencyErrors),
+		fmt.Sprintf("ignore-dependency-order=%t", opts.IgnoreDependencyOrder),
+		fmt.Sprintf("ignore-external-dependencies=%t", opts.IgnoreExternalDependencies),
+		fmt.Sprintf("include-external-dependencies=%t", opts.IncludeExternalDependencies),
+		fmt.Sprintf("auto-retry=%t", opts.AutoRetry),
+		fmt.Sprintf("retry-max-attempts=%d", opts.RetryMaxAttempts),
+	}
+
+	envNames := make([]string, 0, len(opts.Env))
+	for name := range opts.Env {
+		envNames = append(envNames, name)
+	}
+
+	sort.Strings(envNames)
+
+	env := make([]string, 0, len(envNames))
+
+	for _, name := range envNames {
+		value := opts.Env[name]
+		if sensitiveEnvNameRegex.MatchString(name) {
+			value = redactedValue
+		}
+
+		env = append(env, name+"="+value)
+	}
+
+	settings = append(settings, fmt.Sprintf("env=[%s]", strings.Join(env, " ")))
+
+	return strings.Join(settings, ", ")
+}
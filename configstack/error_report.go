@@ -0,0 +1,65 @@
+package configstack
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+)
+
+// writeErrorReport writes the report of the modules that ran and failed for DeferErrorOutput: the error of each module
+// along with what it wrote to stderr, ordered by the level of the modules in the dependency graph, then by path.
+// Nothing is written if no module failed.
+func (modules RunningModules) writeErrorReport(w io.Writer) error {
+	terraformModules := make(TerraformModules, 0, len(modules))
+	failed := []*RunningModule{}
+
+	for _, module := range modules {
+		terraformModules = append(terraformModules, module.Module)
+
+		if module.resultStatus() == ModuleFailed {
+			failed = append(failed, module)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	levels := terraformModules.Levels()
+
+	sort.Slice(failed, func(i, j int) bool {
+		left, right := failed[i].Module.Path, failed[j].Module.Path
+		if levels[left] != levels[right] {
+			return levels[left] < levels[right]
+		}
+
+		return left < right
+	})
+
+	var report strings.Builder
+
+	fmt.Fprintf(&report, "%d modules failed:\n", len(failed))
+
+	for _, module := range failed {
+		fmt.Fprintf(&report, "\n--- Module %s (level %d) ---\nError: %v\n", module.Module.Path, levels[module.Module.Path], module.Err)
+
+		if module.deferredErrors != nil {
+			if output := module.deferredErrors.String(); output != "" {
+				report.WriteString(output)
+
+				if !strings.HasSuffix(output, "\n") {
+					report.WriteString("\n")
+				}
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, report.String()); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
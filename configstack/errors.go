@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/gruntwork-io/terragrunt/options"
 	"github.com/gruntwork-io/terragrunt/util"
 )
 
@@ -45,10 +47,445 @@ func (err InfiniteRecursionError) Error() string {
 
 var ErrNoTerraformModulesFound = errors.New("could not find any subfolders with Terragrunt configuration files")
 
+// SkipModule can be returned by the BeforeModuleHook or the RunTerragrunt command of a module to consider the module
+// done without running it: the module is skipped as a success, and the modules that depend on it run as usual.
+var SkipModule = errors.New("skip module") //nolint:errname,revive,stylecheck
+
+// maxSingleLineCycleLength is the length above which a DependencyCycleError is rendered with one module per line.
+const maxSingleLineCycleLength = 80
+
+// DependencyCycleError is a dependency cycle, as the paths of the modules along the cycle, the first module being
+// repeated at the end.
 type DependencyCycleError []string
 
+// Error renders the cycle as "[a] -> b -> c -> [a]", the module closing the cycle being marked with brackets. Long
+// cycles are rendered with one module per line.
 func (err DependencyCycleError) Error() string {
-	return "Found a dependency cycle between modules: " + strings.Join([]string(err), " -> ")
+	paths := make([]string, len(err))
+
+	for i, path := range err {
+		if len(err) > 1 && path == err[len(err)-1] {
+			path = "[" + path + "]"
+		}
+
+		paths[i] = path
+	}
+
+	if cycle := strings.Join(paths, " -> "); len(cycle) <= maxSingleLineCycleLength {
+		return "Found a dependency cycle between modules: " + cycle
+	}
+
+	return "Found a dependency cycle between modules:\n  " + strings.Join(paths, "\n  -> ")
+}
+
+// IsolatedModulesError lists the paths of the modules that neither depend on another module nor are a dependency of
+// one.
+type IsolatedModulesError []string
+
+func (err IsolatedModulesError) Error() string {
+	return "Found modules that neither depend on nor are a dependency of any other module: " + strings.Join(err, ", ")
+}
+
+// MissingDependencyError is returned for a module that depends on a module that is not part of the stack.
+type MissingDependencyError struct {
+	Module     *TerraformModule
+	Dependency *TerraformModule
+}
+
+func (err MissingDependencyError) Error() string {
+	return fmt.Sprintf("Module %s depends on module %s, which is not part of the stack", err.Module.Path, err.Dependency.Path)
+}
+
+// ExcludedDependencyError is returned for a module that runs but depends on a module that is excluded.
+type ExcludedDependencyError struct {
+	Module     *TerraformModule
+	Dependency *TerraformModule
+}
+
+func (err ExcludedDependencyError) Error() string {
+	return fmt.Sprintf("Module %s depends on module %s, which is excluded", err.Module.Path, err.Dependency.Path)
+}
+
+// StateKeyCollision is a state location shared by the modules with the given paths.
+type StateKeyCollision struct {
+	StateKey string
+	Paths    []string
+}
+
+// StateKeyCollisionsError lists the state locations shared by several modules, which would overwrite each other's
+// state.
+type StateKeyCollisionsError []StateKeyCollision
+
+func (err StateKeyCollisionsError) Error() string {
+	collisions := make([]string, len(err))
+	for i, collision := range err {
+		collisions[i] = fmt.Sprintf("\n  - %s: %s", collision.StateKey, strings.Join(collision.Paths, ", "))
+	}
+
+	return "Found modules storing their state in the same place:" + strings.Join(collisions, "")
+}
+
+// IncludeCycleError is a DependencyCycleError that goes through the include of a config: the module with the given
+// path includes the given config, which either declares the dependency of the module that is part of the cycle, or is
+// the config of the next module of the cycle.
+type IncludeCycleError struct {
+	Cycle   DependencyCycleError
+	Module  string
+	Include string
+}
+
+func (err IncludeCycleError) Error() string {
+	return fmt.Sprintf("Module %s includes %s, which makes it part of a dependency cycle. %v", err.Module, err.Include, err.Cycle)
+}
+
+func (err IncludeCycleError) Unwrap() error {
+	return err.Cycle
+}
+
+// EntangledModulesError is a DependencyCycleError reported along with the strongly connected components of the
+// modules, as the paths of their modules: the sets of modules that all depend on each other through one cycle or
+// another, which all have to be untangled to break the cycles.
+type EntangledModulesError struct {
+	Cycle      DependencyCycleError
+	Components [][]string
+}
+
+func (err EntangledModulesError) Error() string {
+	components := make([]string, len(err.Components))
+	for i, component := range err.Components {
+		components[i] = "\n  - " + strings.Join(component, ", ")
+	}
+
+	return err.Cycle.Error() + "\nModules entangled in dependency cycles:" + strings.Join(components, "")
+}
+
+func (err EntangledModulesError) Unwrap() error {
+	return err.Cycle
+}
+
+type InvalidParallelismError int
+
+func (err InvalidParallelismError) Error() string {
+	return fmt.Sprintf("Invalid parallelism %d: at least one module must be allowed to run at a time", int(err))
+}
+
+type UnrecognizedModuleError string
+
+func (err UnrecognizedModuleError) Error() string {
+	return fmt.Sprintf("Module %s is not part of the stack", string(err))
+}
+
+type InvalidModuleSelectorError string
+
+func (err InvalidModuleSelectorError) Error() string {
+	return fmt.Sprintf("Invalid module selector %q, expected a comma separated list of key=value pairs", string(err))
+}
+
+type PhaseDependencyError struct {
+	Module     *TerraformModule
+	Dependency *TerraformModule
+}
+
+func (err PhaseDependencyError) Error() string {
+	return fmt.Sprintf("Module %s in phase %d depends on module %s in the later phase %d", err.Module.Path, err.Module.Phase, err.Dependency.Path, err.Dependency.Phase)
+}
+
+// RunLastDependencyError is returned when a module depends on a module that runs last, as that dependency could never
+// be satisfied.
+type RunLastDependencyError struct {
+	Module     *TerraformModule
+	Dependency *TerraformModule
+}
+
+func (err RunLastDependencyError) Error() string {
+	return fmt.Sprintf("Module %s depends on module %s, which runs after all the modules that don't run last", err.Module.Path, err.Dependency.Path)
+}
+
+// InvalidRunSummaryFileError is returned when the file of a RunSummary cannot be parsed.
+type InvalidRunSummaryFileError struct {
+	Path string
+	Err  error
+}
+
+func (err InvalidRunSummaryFileError) Error() string {
+	return fmt.Sprintf("Could not parse the run summary file %s: %v", err.Path, err.Err)
+}
+
+func (err InvalidRunSummaryFileError) Unwrap() error {
+	return err.Err
+}
+
+type InvalidGraphQuoteStyleError string
+
+func (err InvalidGraphQuoteStyleError) Error() string {
+	return fmt.Sprintf("Invalid graph quote style %q, expected one of %q, %q or %q", string(err), options.GraphQuoteStyleDefault, options.GraphQuoteStyleStrict, options.GraphQuoteStyleHTML)
+}
+
+type InvalidCIAnnotationsError string
+
+func (err InvalidCIAnnotationsError) Error() string {
+	return fmt.Sprintf("Invalid CI annotations %q, expected %q", string(err), options.CIAnnotationsBuildkite)
+}
+
+type InvalidGraphEdgeDirectionError string
+
+func (err InvalidGraphEdgeDirectionError) Error() string {
+	return fmt.Sprintf("Invalid graph edge direction %q, expected %q or %q", string(err), options.GraphEdgeDirectionDependsOn, options.GraphEdgeDirectionFeedsInto)
+}
+
+type InvalidOnMissingConfigError string
+
+func (err InvalidOnMissingConfigError) Error() string {
+	return fmt.Sprintf("Invalid handling of modules without a config %q, expected %q, %q or %q", string(err), options.OnMissingConfigWarn, options.OnMissingConfigSkip, options.OnMissingConfigError)
+}
+
+type ModuleValidationError struct {
+	ModulePath string
+	Err        error
+}
+
+func (err ModuleValidationError) Error() string {
+	return fmt.Sprintf("Module %s failed validation: %v", err.ModulePath, err.Err)
+}
+
+func (err ModuleValidationError) Unwrap() error {
+	return err.Err
+}
+
+// TruncatedErrors is the error of a run with more failed modules than the MaxErrorEntries of the options: its message
+// only lists the first MaxEntries errors, while all of them remain available with All, and to errors.Is and errors.As.
+type TruncatedErrors struct {
+	Errors     []error
+	MaxEntries int
+}
+
+func (err TruncatedErrors) Error() string {
+	entries := make([]string, 0, err.MaxEntries)
+
+	for _, entryErr := range err.Errors[:min(err.MaxEntries, len(err.Errors))] {
+		lines := strings.Split(strings.ReplaceAll(entryErr.Error(), "\r\n", "\n"), "\n")
+		entries = append(entries, "* "+strings.Join(lines, "\n  "))
+	}
+
+	return fmt.Sprintf("%d errors occurred:\n\n%s\n\n... and %d more\n", len(err.Errors), strings.Join(entries, "\n\n"), len(err.Errors)-len(entries))
+}
+
+// All returns all the errors of the run, including the ones left out of the message.
+func (err TruncatedErrors) All() []error {
+	return err.Errors
+}
+
+func (err TruncatedErrors) Unwrap() []error {
+	return err.Errors
+}
+
+type TooManyModulesError struct {
+	Count int
+	Max   int
+}
+
+func (err TooManyModulesError) Error() string {
+	return fmt.Sprintf("Refusing to run %d modules, more than the maximum of %d. Check the modules that were discovered, or raise the maximum.", err.Count, err.Max)
+}
+
+type UnreachableSourceError struct {
+	ModulePath string
+	Source     string
+	Err        error
+}
+
+func (err UnreachableSourceError) Error() string {
+	return fmt.Sprintf("The source %s of module %s is unreachable: %v", err.Source, err.ModulePath, err.Err)
+}
+
+func (err UnreachableSourceError) Unwrap() error {
+	return err.Err
+}
+
+type InvalidShardError struct {
+	Index int
+	Total int
+}
+
+func (err InvalidShardError) Error() string {
+	return fmt.Sprintf("Invalid shard index %d, expected a value between 0 and %d for %d shards", err.Index, err.Total-1, err.Total)
+}
+
+type EmptyModuleConfigError string
+
+func (err EmptyModuleConfigError) Error() string {
+	return fmt.Sprintf("Module %s has an empty configuration, with no terraform files in its terraform source or its folder. Was it initialized from its Terragrunt configuration?", string(err))
+}
+
+type ModuleTimeoutError struct {
+	Module  *TerraformModule
+	Timeout time.Duration
+}
+
+func (err ModuleTimeoutError) Error() string {
+	return fmt.Sprintf("Module %s did not finish within the timeout of %s", err.Module.Path, err.Timeout)
+}
+
+type GroupMemberFailedError struct {
+	Module       *TerraformModule
+	FailedMember *TerraformModule
+}
+
+func (err GroupMemberFailedError) Error() string {
+	return fmt.Sprintf("Module %s was skipped because %s, another member of its group %s, finished with an error", err.Module.Path, err.FailedMember.Path, err.Module.Group)
+}
+
+// SkippedDependencyError is the error of a module that is blocked because one of its dependencies was skipped rather
+// than run, when RunDependentsOnSkippedDependency is not set.
+type SkippedDependencyError struct {
+	Module     *TerraformModule
+	Dependency *TerraformModule
+}
+
+func (err SkippedDependencyError) Error() string {
+	return fmt.Sprintf("Cannot process module %s because one of its dependencies, %s, was skipped rather than run", err.Module.Path, err.Dependency.Path)
+}
+
+// ApplyLimitReachedError is the error of a module that did not run because ApplyLimit modules had already succeeded.
+// It is left out of the errors of the run, as the module is left for a later run rather than failed.
+type ApplyLimitReachedError struct {
+	Module *TerraformModule
+	Limit  int
+}
+
+func (err ApplyLimitReachedError) Error() string {
+	return fmt.Sprintf("Module %s was not run because the apply limit of %d modules was reached", err.Module.Path, err.Limit)
+}
+
+// LevelNotContinuedError is the error of a module that did not run because, with PauseBetweenLevels, the run was not
+// continued to its level, or to an earlier one.
+type LevelNotContinuedError struct {
+	Module *TerraformModule
+	Level  int
+	Err    error
+}
+
+func (err LevelNotContinuedError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("Module %s was not run because the run could not be continued to level %d: %v", err.Module.Path, err.Level, err.Err)
+	}
+
+	return fmt.Sprintf("Module %s was not run because the run was not continued to level %d", err.Module.Path, err.Level)
+}
+
+func (err LevelNotContinuedError) Unwrap() error {
+	return err.Err
+}
+
+// ExitCoder is implemented by the errors that carry the exit code of the terraform command that caused them.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// Exit code reported for a failed module when the exit code of the underlying command is unknown
+const unknownExitCode = 1
+
+// ModuleExitError wraps the error of a module whose terraform command exited with a failing exit code.
+type ModuleExitError struct {
+	Module *TerraformModule
+	Err    error
+	Code   int
+}
+
+func (err ModuleExitError) Error() string {
+	return err.Err.Error()
+}
+
+func (err ModuleExitError) ExitCode() int {
+	return err.Code
+}
+
+func (err ModuleExitError) ExitStatus() (int, error) {
+	return err.Code, nil
+}
+
+func (err ModuleExitError) Unwrap() error {
+	return err.Err
+}
+
+// ModulePanicError is the error of a module whose run panicked, with the value it panicked with and the stack trace of
+// the panic.
+type ModulePanicError struct {
+	Module *TerraformModule
+	Value  any
+	Stack  []byte
+}
+
+func (err ModulePanicError) Error() string {
+	return fmt.Sprintf("Module %s panicked: %v\n%s", err.Module.Path, err.Value, err.Stack)
+}
+
+type ResourceCapacityExceededError struct {
+	Module   *TerraformModule
+	Tag      string
+	Cost     int
+	Capacity int
+}
+
+func (err ResourceCapacityExceededError) Error() string {
+	return fmt.Sprintf("Module %s uses %d of resource %q, more than its capacity of %d, and can never run", err.Module.Path, err.Cost, err.Tag, err.Capacity)
+}
+
+type WarmUpCommandError struct {
+	Command []string
+	Err     error
+}
+
+func (err WarmUpCommandError) Error() string {
+	return fmt.Sprintf("Warm-up command %q failed, no module was run: %v", strings.Join(err.Command, " "), err.Err)
+}
+
+func (err WarmUpCommandError) Unwrap() error {
+	return err.Err
+}
+
+type DependencyOutputEnvCollisionError struct {
+	Module       *TerraformModule
+	Name         string
+	Dependencies []string
+}
+
+func (err DependencyOutputEnvCollisionError) Error() string {
+	return fmt.Sprintf("The outputs of the dependencies %s of module %s are all passed as the env var %s", strings.Join(err.Dependencies, ", "), err.Module.Path, err.Name)
+}
+
+type EstimatedDurationExceededError struct {
+	Estimate             time.Duration
+	Budget               time.Duration
+	CriticalPath         []string
+	CriticalPathDuration time.Duration
+}
+
+func (err EstimatedDurationExceededError) Error() string {
+	return fmt.Sprintf("The run is estimated to take %s, more than the budget of %s, no module was run. Critical path (%s): %s", err.Estimate, err.Budget, err.CriticalPathDuration, strings.Join(err.CriticalPath, " -> "))
+}
+
+// RunExitCodeError is the error of a run for which the WithExitCodeFunc of the stack returned a non-zero exit code,
+// wrapping the errors of the modules, if any.
+type RunExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (err RunExitCodeError) Error() string {
+	if err.Err != nil {
+		return err.Err.Error()
+	}
+
+	return fmt.Sprintf("Run failed with exit code %d", err.Code)
+}
+
+func (err RunExitCodeError) ExitStatus() (int, error) {
+	return err.Code, nil
+}
+
+func (err RunExitCodeError) Unwrap() error {
+	return err.Err
 }
 
 type ProcessingModuleDependencyError struct {
@@ -69,6 +506,17 @@ func (err ProcessingModuleDependencyError) ExitStatus() (int, error) {
 	return -1, err
 }
 
+// ExitCode returns the exit code of the command that failed in the dependency chain of the module, or 1 if it is not
+// known.
+func (err ProcessingModuleDependencyError) ExitCode() int {
+	var exitCoder ExitCoder
+	if errors.As(err.Err, &exitCoder) {
+		return exitCoder.ExitCode()
+	}
+
+	return unknownExitCode
+}
+
 func (err ProcessingModuleDependencyError) Unwrap() error {
 	return err.Err
 }
@@ -0,0 +1,182 @@
+package configstack
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+)
+
+const (
+	// eventSocketBufferSize is the number of events buffered for each subscriber of the EventSocket. The events sent to
+	// a subscriber whose buffer is full are dropped, so that a slow subscriber never blocks the run.
+	eventSocketBufferSize = 256
+	// eventSocketWriteTimeout bounds the time spent writing an event to a subscriber, so that closing the socket does
+	// not wait on a subscriber that stopped reading.
+	eventSocketWriteTimeout = 5 * time.Second
+)
+
+// eventSocketMessage is the JSON form of a RunEvent, written as one line to the subscribers of the EventSocket:
+//
+//	{"type":"module_finished","time":"2024-01-01T00:00:01Z","path":"/stack/vpc","message":"...","duration_ms":0}
+//
+// The message and duration_ms are left out when empty.
+type eventSocketMessage struct {
+	Type       options.RunEventType `json:"type"`
+	Time       time.Time            `json:"time"`
+	Path       string               `json:"path,omitempty"`
+	Message    string               `json:"message,omitempty"`
+	DurationMs int64                `json:"duration_ms,omitempty"`
+}
+
+// eventSocket publishes the events of a run as newline-delimited JSON to every process connected to a Unix domain
+// socket.
+type eventSocket struct {
+	listener net.Listener
+	logger   log.Logger
+
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]bool
+	closed      bool
+	waitGroup   sync.WaitGroup
+}
+
+// eventSubscriber is a process connected to the eventSocket, with the events waiting to be written to it.
+type eventSubscriber struct {
+	conn    net.Conn
+	events  chan []byte
+	dropped int
+}
+
+// listenEventSocket listens on the Unix domain socket with the given path, replacing the socket left behind by an
+// earlier run if any, and accepts subscribers until closed.
+func listenEventSocket(path string, logger log.Logger) (*eventSocket, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, errors.New(err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.New(err)
+	}
+
+	socket := &eventSocket{
+		listener:    listener,
+		logger:      logger,
+		subscribers: map[*eventSubscriber]bool{},
+	}
+
+	socket.waitGroup.Add(1)
+
+	go socket.accept()
+
+	return socket, nil
+}
+
+// accept adds the processes connecting to the socket as subscribers until the socket is closed.
+func (socket *eventSocket) accept() {
+	defer socket.waitGroup.Done()
+
+	for {
+		conn, err := socket.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		subscriber := &eventSubscriber{conn: conn, events: make(chan []byte, eventSocketBufferSize)}
+
+		socket.mu.Lock()
+
+		if socket.closed {
+			socket.mu.Unlock()
+			conn.Close() //nolint:errcheck
+
+			return
+		}
+
+		socket.subscribers[subscriber] = true
+		socket.waitGroup.Add(1)
+		socket.mu.Unlock()
+
+		go socket.write(subscriber)
+	}
+}
+
+// write writes the events of the given subscriber to its connection until its events are closed or a write fails.
+func (socket *eventSocket) write(subscriber *eventSubscriber) {
+	defer socket.waitGroup.Done()
+	defer subscriber.conn.Close() //nolint:errcheck
+
+	for line := range subscriber.events {
+		if err := subscriber.conn.SetWriteDeadline(time.Now().Add(eventSocketWriteTimeout)); err != nil {
+			break
+		}
+
+		if _, err := subscriber.conn.Write(line); err != nil {
+			socket.logger.Debugf("Event socket subscriber disconnected: %v", err)
+			break
+		}
+	}
+
+	// no more events are sent to a subscriber that is gone
+	socket.mu.Lock()
+	delete(socket.subscribers, subscriber)
+	socket.mu.Unlock()
+}
+
+// publish sends the given event to every subscriber, dropping it for the subscribers whose buffer is full.
+func (socket *eventSocket) publish(event options.RunEvent) {
+	line, err := json.Marshal(eventSocketMessage{
+		Type:       event.Type,
+		Time:       event.Time,
+		Path:       event.Path,
+		Message:    event.Message,
+		DurationMs: event.Duration.Milliseconds(),
+	})
+	if err != nil {
+		socket.logger.Errorf("Failed to encode run event %s: %v", event.Type, err)
+		return
+	}
+
+	line = append(line, '\n')
+
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	for subscriber := range socket.subscribers {
+		select {
+		case subscriber.events <- line:
+		default:
+			subscriber.dropped++
+		}
+	}
+}
+
+// close stops accepting subscribers and waits for the buffered events to be written to the subscribers before closing
+// their connections and removing the socket.
+func (socket *eventSocket) close() {
+	socket.mu.Lock()
+	socket.closed = true
+
+	for subscriber := range socket.subscribers {
+		if subscriber.dropped > 0 {
+			socket.logger.Warnf("Dropped %d run events for a slow event socket subscriber", subscriber.dropped)
+		}
+
+		close(subscriber.events)
+		delete(socket.subscribers, subscriber)
+	}
+
+	socket.mu.Unlock()
+
+	// closing the listener also removes the socket file
+	socket.listener.Close() //nolint:errcheck
+	socket.waitGroup.Wait()
+}
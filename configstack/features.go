@@ -0,0 +1,40 @@
+package configstack
+
+import "sort"
+
+// The features a module can enable in the features attribute of its config, turning on behaviors of the runner for
+// that module only, as the corresponding options do for all the modules.
+const (
+	// FeatureIsolateWorkdir runs the module in an isolated copy of its directory, as with IsolateWorkdir.
+	FeatureIsolateWorkdir = "isolate_workdir"
+	// FeaturePrefixIncludeLevel includes the level of the module in its log prefix, as with PrefixIncludeLevel.
+	FeaturePrefixIncludeLevel = "prefix_include_level"
+)
+
+var knownFeatures = map[string]bool{
+	FeatureIsolateWorkdir:     true,
+	FeaturePrefixIncludeLevel: true,
+}
+
+// featureEnabled returns true if the module enables the given feature.
+func (module *TerraformModule) featureEnabled(feature string) bool {
+	return module.Features[feature]
+}
+
+// warnUnknownFeatures warns about the features of the module that the runner doesn't know about, which have no effect,
+// e.g. because of a typo.
+func (module *TerraformModule) warnUnknownFeatures() {
+	var unknown []string
+
+	for feature := range module.Features {
+		if !knownFeatures[feature] {
+			unknown = append(unknown, feature)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	for _, feature := range unknown {
+		module.TerragruntOptions.Logger.Warnf("Module %s enables the unknown feature %q, which has no effect", module.Path, feature)
+	}
+}
@@ -0,0 +1,98 @@
+package configstack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+)
+
+// foldedStacks returns the durations of the modules that ran as folded stacks, the input format of flamegraph tools:
+// one line per dependency chain leading to a module, from a module without dependencies down to the module, with the
+// frames separated by semicolons and followed by the duration of the module in milliseconds, such as "a;b;c 3000".
+// A module reached through several chains has its duration split between them, so that the widths of the frames add
+// up to the durations of the modules. The lines are sorted.
+func (modules RunningModules) foldedStacks(dependencies map[string][]string) []string {
+	chains := map[string][][]string{}
+
+	var chainsTo func(path string, visiting map[string]bool) [][]string
+
+	chainsTo = func(path string, visiting map[string]bool) [][]string {
+		if cached, found := chains[path]; found {
+			return cached
+		}
+
+		// Cycles are rejected before running, this only guards against looping forever.
+		if visiting[path] {
+			return nil
+		}
+
+		visiting[path] = true
+		defer delete(visiting, path)
+
+		var result [][]string
+
+		for _, dependencyPath := range dependencies[path] {
+			for _, chain := range chainsTo(dependencyPath, visiting) {
+				result = append(result, append(append([]string{}, chain...), path))
+			}
+		}
+
+		if len(result) == 0 {
+			result = [][]string{{path}}
+		}
+
+		chains[path] = result
+
+		return result
+	}
+
+	var lines []string
+
+	for path, module := range modules {
+		if module.StartTime.IsZero() {
+			continue
+		}
+
+		durationMs := module.EndTime.Sub(module.StartTime).Milliseconds()
+		moduleChains := chainsTo(path, map[string]bool{})
+		share := durationMs / int64(len(moduleChains))
+
+		for i, chain := range moduleChains {
+			value := share
+			if i == 0 {
+				value += durationMs % int64(len(moduleChains))
+			}
+
+			lines = append(lines, fmt.Sprintf("%s %d", strings.Join(chain, ";"), value))
+		}
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
+
+// writeFoldedStacks writes the folded stacks of the modules, which had the given dependencies, to the given file.
+func (modules RunningModules) writeFoldedStacks(path string, dependencies map[string][]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New(err)
+	}
+	defer file.Close()
+
+	for _, line := range modules.foldedStacks(dependencies) {
+		if _, err := fmt.Fprintln(file, line); err != nil {
+			return errors.New(err)
+		}
+	}
+
+	return nil
+}
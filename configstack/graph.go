@@ -0,0 +1,675 @@
+package configstack
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// graphPathPrefix returns the prefix trimmed from the module paths in the graph outputs: all paths are relative to the
+// GraphTrimPrefix if set, or to the TerragruntConfigPath otherwise.
+func graphPathPrefix(terragruntOptions *options.TerragruntOptions) string {
+	if terragruntOptions.GraphTrimPrefix != "" {
+		return strings.TrimSuffix(terragruntOptions.GraphTrimPrefix, "/") + "/"
+	}
+
+	return filepath.Dir(terragruntOptions.TerragruntConfigPath) + "/"
+}
+
+// graphLabel returns the text displayed for the module in the graph outputs: its Name if set, its path relative to
+// the given prefix otherwise.
+func (module *TerraformModule) graphLabel(prefix string) string {
+	if module.Name != "" {
+		return module.Name
+	}
+
+	return strings.TrimPrefix(module.Path, prefix)
+}
+
+// dotQuoter returns the function quoting the node identifiers of the DOT output in the given style, and the function
+// quoting its labels.
+func dotQuoter(style string) (func(string) string, func(string) string, error) {
+	switch style {
+	case options.GraphQuoteStyleDefault:
+		escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		quoteLabel := func(label string) string { return `"` + escaper.Replace(label) + `"` }
+
+		return func(id string) string { return `"` + id + `"` }, quoteLabel, nil
+	case options.GraphQuoteStyleStrict:
+		escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+		quote := func(id string) string { return `"` + escaper.Replace(id) + `"` }
+
+		return quote, quote, nil
+	case options.GraphQuoteStyleHTML:
+		quote := func(id string) string { return "<" + html.EscapeString(id) + ">" }
+
+		return quote, quote, nil
+	}
+
+	return nil, nil, errors.New(InvalidGraphQuoteStyleError(style))
+}
+
+// WriteDot is used to emit a GraphViz compatible definition
+// for a directed graph. It can be used to dump a .dot file.
+// This is a similar implementation to terraform's digraph https://github.com/hashicorp/terraform/blob/master/digraph/graphviz.go
+// adding some styling to modules that are excluded from the execution in *-all commands.
+// If GraphCompact is set, the standalone declaration of a node is omitted when the node is part of an edge and has no
+// styling, since the edge already introduces it.
+// The nodes are identified by their path, modules with a Name are labeled with it, and modules with Labels show them
+// as a tooltip. The identifiers and labels are quoted according to the GraphQuoteStyle. The edges go from each module
+// to its dependencies, or the other way around if the GraphEdgeDirection is GraphEdgeDirectionFeedsInto. If
+// GraphByComponent is set, each weakly connected component of the graph is laid out as a separate cluster. If
+// GraphShowBatches is set, each node has a batch attribute with the level of its module, as computed by Levels. If
+// GraphTransitiveReduction is set, the redundant edges are left out, and a DependencyCycleError is returned if the
+// dependencies form a cycle, as the reduction is only defined for a DAG.
+func (modules TerraformModules) WriteDot(w io.Writer, terragruntOptions *options.TerragruntOptions) error {
+	quoteID, quoteLabel, err := dotQuoter(terragruntOptions.GraphQuoteStyle)
+	if err != nil {
+		return err
+	}
+
+	feedsInto := false
+
+	switch terragruntOptions.GraphEdgeDirection {
+	case "", options.GraphEdgeDirectionDependsOn:
+	case options.GraphEdgeDirectionFeedsInto:
+		feedsInto = true
+	default:
+		return errors.New(InvalidGraphEdgeDirectionError(terragruntOptions.GraphEdgeDirection))
+	}
+
+	var redundant map[string]map[string]bool
+
+	if terragruntOptions.GraphTransitiveReduction {
+		if redundant, err = modules.redundantEdges(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.Write([]byte("digraph {\n")); err != nil {
+		return errors.New(err)
+	}
+	defer func(w io.Writer, p []byte) {
+		_, err := w.Write(p)
+		if err != nil {
+			terragruntOptions.Logger.Warnf("Failed to close graphviz output: %v", err)
+		}
+	}(w, []byte("}\n"))
+
+	prefix := graphPathPrefix(terragruntOptions)
+
+	// in compact mode, the nodes that are part of an edge are introduced by that edge
+	pathsWithEdges := map[string]bool{}
+
+	if terragruntOptions.GraphCompact {
+		for _, source := range modules {
+			for _, target := range source.Dependencies {
+				if redundant[source.Path][target.Path] {
+					continue
+				}
+
+				pathsWithEdges[source.Path] = true
+				pathsWithEdges[target.Path] = true
+			}
+		}
+	}
+
+	var levels map[string]int
+	if terragruntOptions.GraphShowBatches {
+		levels = modules.Levels()
+	}
+
+	components := []TerraformModules{modules}
+	indent := "\t"
+
+	if terragruntOptions.GraphByComponent {
+		components = modules.connectedComponents()
+		indent = "\t\t"
+	}
+
+	for i, component := range components {
+		if terragruntOptions.GraphByComponent {
+			if _, err := fmt.Fprintf(w, "\tsubgraph \"cluster_%d\" {\n", i); err != nil {
+				return errors.New(err)
+			}
+		}
+
+		for _, source := range component {
+			attributes := []string{}
+
+			// apply a different coloring for excluded nodes
+			if source.FlagExcluded {
+				attributes = append(attributes, "color=red")
+			}
+
+			if source.Name != "" {
+				attributes = append(attributes, "label="+quoteLabel(source.Name))
+			}
+
+			if len(source.Labels) > 0 {
+				attributes = append(attributes, "tooltip="+quoteLabel(source.labelsString()))
+			}
+
+			if terragruntOptions.GraphShowBatches {
+				attributes = append(attributes, fmt.Sprintf("batch=%d", levels[source.Path]))
+			}
+
+			style := ""
+			if len(attributes) > 0 {
+				style = "[" + strings.Join(attributes, ", ") + "]"
+			}
+
+			if style != "" || !pathsWithEdges[source.Path] {
+				nodeLine := fmt.Sprintf("%s%s %s;\n",
+					indent, quoteID(strings.TrimPrefix(source.Path, prefix)), style)
+
+				_, err := w.Write([]byte(nodeLine))
+				if err != nil {
+					return errors.New(err)
+				}
+			}
+
+			for _, target := range source.Dependencies {
+				if redundant[source.Path][target.Path] {
+					continue
+				}
+
+				from, to := source.Path, target.Path
+				if feedsInto {
+					from, to = to, from
+				}
+
+				line := fmt.Sprintf("%s%s -> %s;\n",
+					indent,
+					quoteID(strings.TrimPrefix(from, prefix)),
+					quoteID(strings.TrimPrefix(to, prefix)),
+				)
+
+				_, err := w.Write([]byte(line))
+				if err != nil {
+					return errors.New(err)
+				}
+			}
+		}
+
+		if terragruntOptions.GraphByComponent {
+			if _, err := io.WriteString(w, "\t}\n"); err != nil {
+				return errors.New(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// redundantEdges returns the dependencies of the modules that are not part of the transitive reduction of the graph, by
+// the path of the module and then of the dependency: those the module also reaches through another of its
+// dependencies. Dependencies on modules that are not part of the list are followed as well. A DependencyCycleError is
+// returned if the dependencies form a cycle.
+func (modules TerraformModules) redundantEdges() (map[string]map[string]bool, error) {
+	if err := modules.CheckForCycles(); err != nil {
+		return nil, err
+	}
+
+	// the paths of the modules reachable from each module through one or more dependencies
+	reachable := map[string]map[string]bool{}
+
+	var reach func(module *TerraformModule) map[string]bool
+
+	reach = func(module *TerraformModule) map[string]bool {
+		if paths, found := reachable[module.Path]; found {
+			return paths
+		}
+
+		paths := map[string]bool{}
+
+		for _, dependency := range module.Dependencies {
+			paths[dependency.Path] = true
+
+			for path := range reach(dependency) {
+				paths[path] = true
+			}
+		}
+
+		reachable[module.Path] = paths
+
+		return paths
+	}
+
+	redundant := map[string]map[string]bool{}
+
+	for _, module := range modules {
+		for _, target := range module.Dependencies {
+			for _, other := range module.Dependencies {
+				if other.Path != target.Path && reach(other)[target.Path] {
+					if redundant[module.Path] == nil {
+						redundant[module.Path] = map[string]bool{}
+					}
+
+					redundant[module.Path][target.Path] = true
+
+					break
+				}
+			}
+		}
+	}
+
+	return redundant, nil
+}
+
+// connectedComponents splits the modules into their weakly connected components, i.e. the sets of modules linked by
+// dependencies regardless of their direction. The components are in the order of their first module in the given
+// modules, and the modules of each component keep their order.
+func (modules TerraformModules) connectedComponents() []TerraformModules {
+	parents := map[string]string{}
+
+	var find func(path string) string
+
+	find = func(path string) string {
+		parent, found := parents[path]
+		if !found || parent == path {
+			parents[path] = path
+			return path
+		}
+
+		root := find(parent)
+		parents[path] = root
+
+		return root
+	}
+
+	for _, module := range modules {
+		for _, dependency := range module.Dependencies {
+			parents[find(dependency.Path)] = find(module.Path)
+		}
+	}
+
+	components := []TerraformModules{}
+	componentIndex := map[string]int{}
+
+	for _, module := range modules {
+		root := find(module.Path)
+
+		i, found := componentIndex[root]
+		if !found {
+			i = len(components)
+			componentIndex[root] = i
+
+			components = append(components, TerraformModules{})
+		}
+
+		components[i] = append(components[i], module)
+	}
+
+	return components
+}
+
+// graphJSON is the document written by WriteJSON.
+type graphJSON struct {
+	Nodes []graphJSONNode `json:"nodes"`
+	Edges []graphJSONEdge `json:"edges"`
+}
+
+type graphJSONNode struct {
+	ID       string            `json:"id"`
+	Label    string            `json:"label"`
+	Excluded bool              `json:"excluded"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// graphJSONEdge goes from a module to one of its dependencies, like the edges of WriteDot.
+type graphJSONEdge struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	OutputKeys []string `json:"output_keys,omitempty"`
+}
+
+// WriteJSON writes the graph of the modules as a JSON document with a list of nodes and a list of edges, each edge
+// going from a module to one of its dependencies. The nodes are identified by their path relative to the
+// TerragruntConfigPath, and labeled with their Name if set, or with that path otherwise. The labels of the modules are
+// included with their nodes, and the output keys each module reads from its dependencies with its edges.
+func (modules TerraformModules) WriteJSON(w io.Writer, terragruntOptions *options.TerragruntOptions) error {
+	prefix := graphPathPrefix(terragruntOptions)
+
+	graph := graphJSON{Nodes: []graphJSONNode{}, Edges: []graphJSONEdge{}}
+
+	for _, source := range modules {
+		graph.Nodes = append(graph.Nodes, graphJSONNode{
+			ID:       strings.TrimPrefix(source.Path, prefix),
+			Label:    source.graphLabel(prefix),
+			Excluded: source.FlagExcluded,
+			Labels:   source.Labels,
+		})
+
+		for _, target := range source.Dependencies {
+			graph.Edges = append(graph.Edges, graphJSONEdge{
+				From:       strings.TrimPrefix(source.Path, prefix),
+				To:         strings.TrimPrefix(target.Path, prefix),
+				OutputKeys: source.ConsumedOutputs[target.Path],
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(graph); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// graphMLNamespace is the XML namespace of GraphML documents.
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+// graphML is the document written by WriteGraphML.
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// graphMLKey declares an attribute of the nodes or of the edges.
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLEdge goes from a module to one of its dependencies, like the edges of WriteJSON.
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// WriteGraphML writes the graph of the modules as a GraphML document, e.g. for yEd or Gephi, with an edge going from
+// each module to each of its dependencies. The nodes are identified by their path relative to the
+// TerragruntConfigPath, and carry the label of the module, whether it is excluded or assumed already applied, and its
+// labels if any. The edges carry the output keys the module reads from the dependency, if any. The nodes are sorted
+// by path and the edges by source and target, so that the document is the same whatever the order of the modules.
+func (modules TerraformModules) WriteGraphML(w io.Writer, terragruntOptions *options.TerragruntOptions) error {
+	prefix := graphPathPrefix(terragruntOptions)
+
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	document := graphML{
+		XMLNS: graphMLNamespace,
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "excluded", For: "node", AttrName: "excluded", AttrType: "boolean"},
+			{ID: "assume_applied", For: "node", AttrName: "assume_applied", AttrType: "boolean"},
+			{ID: "labels", For: "node", AttrName: "labels", AttrType: "string"},
+			{ID: "output_keys", For: "edge", AttrName: "output_keys", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "G", EdgeDefault: "directed"},
+	}
+
+	for _, source := range sortedModules {
+		node := graphMLNode{
+			ID: strings.TrimPrefix(source.Path, prefix),
+			Data: []graphMLData{
+				{Key: "label", Value: source.graphLabel(prefix)},
+				{Key: "excluded", Value: strconv.FormatBool(source.FlagExcluded)},
+				{Key: "assume_applied", Value: strconv.FormatBool(source.AssumeAlreadyApplied)},
+			},
+		}
+
+		if len(source.Labels) > 0 {
+			node.Data = append(node.Data, graphMLData{Key: "labels", Value: source.labelsString()})
+		}
+
+		document.Graph.Nodes = append(document.Graph.Nodes, node)
+
+		targets := make([]string, 0, len(source.Dependencies))
+		for _, target := range source.Dependencies {
+			targets = append(targets, target.Path)
+		}
+
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			edge := graphMLEdge{
+				ID:     fmt.Sprintf("e%d", len(document.Graph.Edges)),
+				Source: node.ID,
+				Target: strings.TrimPrefix(target, prefix),
+			}
+
+			if keys := source.ConsumedOutputs[target]; len(keys) > 0 {
+				edge.Data = append(edge.Data, graphMLData{Key: "output_keys", Value: strings.Join(keys, ", ")})
+			}
+
+			document.Graph.Edges = append(document.Graph.Edges, edge)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.New(err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(document); err != nil {
+		return errors.New(err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// Neo4j labels of the nodes and type of the relationships written by WriteNeo4jCSV.
+const (
+	neo4jNodeLabel        = "Module"
+	neo4jRelationshipType = "DEPENDS_ON"
+)
+
+// WriteNeo4jCSV writes the graph of the modules as the node and relationship CSV files of `neo4j-admin database
+// import`, each with its header row. The nodes are identified by their path relative to the TerragruntConfigPath, and
+// carry the label of the module and whether it is excluded or assumed already applied. Each relationship goes from a
+// module to one of its dependencies. The nodes are sorted by path and the relationships by source and target, so that
+// the files are the same whatever the order of the modules.
+func (modules TerraformModules) WriteNeo4jCSV(nodesW, edgesW io.Writer, terragruntOptions *options.TerragruntOptions) error {
+	prefix := graphPathPrefix(terragruntOptions)
+
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	nodes := [][]string{{"path:ID", "label", "excluded:boolean", "assume_applied:boolean", ":LABEL"}}
+	edges := [][]string{{":START_ID", ":END_ID", ":TYPE"}}
+
+	for _, source := range sortedModules {
+		path := strings.TrimPrefix(source.Path, prefix)
+
+		nodes = append(nodes, []string{
+			path,
+			source.graphLabel(prefix),
+			strconv.FormatBool(source.FlagExcluded),
+			strconv.FormatBool(source.AssumeAlreadyApplied),
+			neo4jNodeLabel,
+		})
+
+		targets := make([]string, 0, len(source.Dependencies))
+		for _, target := range source.Dependencies {
+			targets = append(targets, strings.TrimPrefix(target.Path, prefix))
+		}
+
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			edges = append(edges, []string{path, target, neo4jRelationshipType})
+		}
+	}
+
+	if err := csv.NewWriter(nodesW).WriteAll(nodes); err != nil {
+		return errors.New(err)
+	}
+
+	if err := csv.NewWriter(edgesW).WriteAll(edges); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// WriteMermaid writes the graph of the modules as a Mermaid flowchart, with an edge going from each module to each of
+// its dependencies and the excluded modules styled in red. As Mermaid node ids can't contain the characters of a path,
+// every path is assigned a generated id, and the nodes are labeled with the Name of the module if set, or with its
+// path relative to the TerragruntConfigPath otherwise.
+func (modules TerraformModules) WriteMermaid(w io.Writer, terragruntOptions *options.TerragruntOptions) error {
+	prefix := graphPathPrefix(terragruntOptions)
+
+	var sb strings.Builder
+
+	sb.WriteString("flowchart TD\n")
+
+	ids := map[string]string{}
+
+	nodeID := func(module *TerraformModule) string {
+		id, found := ids[module.Path]
+		if !found {
+			id = fmt.Sprintf("m%d", len(ids))
+			ids[module.Path] = id
+
+			sb.WriteString(fmt.Sprintf("\t%s[\"%s\"]\n", id, strings.ReplaceAll(module.graphLabel(prefix), `"`, "#quot;")))
+		}
+
+		return id
+	}
+
+	excluded := []string{}
+
+	for _, source := range modules {
+		id := nodeID(source)
+		if source.FlagExcluded {
+			excluded = append(excluded, id)
+		}
+	}
+
+	for _, source := range modules {
+		for _, target := range source.Dependencies {
+			sb.WriteString(fmt.Sprintf("\t%s --> %s\n", nodeID(source), nodeID(target)))
+		}
+	}
+
+	if len(excluded) > 0 {
+		sb.WriteString("\tclassDef excluded stroke:red,color:red\n")
+		sb.WriteString(fmt.Sprintf("\tclass %s excluded\n", strings.Join(excluded, ",")))
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// WritePlantUML writes the graph of the modules as a PlantUML component diagram, with an arrow going from each module
+// to each of its dependencies and the excluded modules styled in red. As PlantUML aliases can't contain the characters
+// of a path, every path is assigned a generated alias, and the components are labeled with the Name of the module if
+// set, or with its path relative to the TerragruntConfigPath otherwise. PlantUML can't escape double quotes in the
+// labels, which are replaced with single quotes. The modules are sorted by path and the arrows by source and target, so
+// that the diagram is the same whatever the order of the modules.
+func (modules TerraformModules) WritePlantUML(w io.Writer, terragruntOptions *options.TerragruntOptions) error {
+	prefix := graphPathPrefix(terragruntOptions)
+
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	var sb strings.Builder
+
+	sb.WriteString("@startuml\n")
+	sb.WriteString("skinparam component<<excluded>> {\n\tBorderColor red\n\tFontColor red\n}\n")
+
+	aliases := map[string]string{}
+
+	alias := func(module *TerraformModule) string {
+		id, found := aliases[module.Path]
+		if !found {
+			id = fmt.Sprintf("m%d", len(aliases))
+			aliases[module.Path] = id
+
+			sb.WriteString(fmt.Sprintf("component \"%s\" as %s", strings.ReplaceAll(module.graphLabel(prefix), `"`, "'"), id))
+
+			if module.FlagExcluded {
+				sb.WriteString(" <<excluded>>")
+			}
+
+			sb.WriteString("\n")
+		}
+
+		return id
+	}
+
+	for _, source := range sortedModules {
+		alias(source)
+	}
+
+	for _, source := range sortedModules {
+		targets := make(TerraformModules, len(source.Dependencies))
+		copy(targets, source.Dependencies)
+
+		sort.Slice(targets, func(i, j int) bool {
+			return targets[i].Path < targets[j].Path
+		})
+
+		for _, target := range targets {
+			sb.WriteString(fmt.Sprintf("%s --> %s\n", alias(source), alias(target)))
+		}
+	}
+
+	sb.WriteString("@enduml\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
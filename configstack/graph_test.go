@@ -0,0 +1,570 @@
+package configstack_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terragrunt/configstack"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createNamedGraphTestModules() configstack.TerraformModules {
+	vpc := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/network/vpc", Name: "Network"}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/app", Dependencies: configstack.TerraformModules{vpc}, FlagExcluded: true}
+
+	return configstack.TerraformModules{vpc, app}
+}
+
+func TestWriteDotModuleName(t *testing.T) {
+	t.Parallel()
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	require.NoError(t, createNamedGraphTestModules().WriteDot(&stdout, terragruntOptions))
+
+	expected := strings.TrimSpace(`
+digraph {
+	"network/vpc" [label="Network"];
+	"app" [color=red];
+	"app" -> "network/vpc";
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+}
+
+func TestWriteDotDefaultQuoteStyle(t *testing.T) {
+	t.Parallel()
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{
+		&configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/réseau", Name: `Réseau "principal" \ 東京`},
+	}
+
+	var stdout bytes.Buffer
+	require.NoError(t, modules.WriteDot(&stdout, terragruntOptions))
+
+	// the non-ASCII characters are kept as they are, only the double quotes and backslashes are escaped
+	expected := strings.TrimSpace(`
+digraph {
+	"réseau" [label="Réseau \"principal\" \\ 東京"];
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+}
+
+func TestWriteDotQuoteStyle(t *testing.T) {
+	t.Parallel()
+
+	modules := func() configstack.TerraformModules {
+		db := &configstack.TerraformModule{Path: `/stack/db "primary"`, Name: `Primary "DB"`}
+		app := &configstack.TerraformModule{Path: "/stack/app<1>", Dependencies: configstack.TerraformModules{db}}
+
+		return configstack.TerraformModules{db, app}
+	}
+
+	testCases := []struct {
+		style    string
+		expected string
+	}{
+		{
+			options.GraphQuoteStyleStrict,
+			`
+digraph {
+	"db \"primary\"" [label="Primary \"DB\""];
+	"app<1>" ;
+	"app<1>" -> "db \"primary\"";
+}`,
+		},
+		{
+			options.GraphQuoteStyleHTML,
+			`
+digraph {
+	<db &#34;primary&#34;> [label=<Primary &#34;DB&#34;>];
+	<app&lt;1&gt;> ;
+	<app&lt;1&gt;> -> <db &#34;primary&#34;>;
+}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.style, func(t *testing.T) {
+			t.Parallel()
+
+			terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+			require.NoError(t, err)
+
+			terragruntOptions.GraphQuoteStyle = testCase.style
+
+			var stdout bytes.Buffer
+			require.NoError(t, modules().WriteDot(&stdout, terragruntOptions))
+
+			assert.Equal(t, strings.TrimSpace(testCase.expected), strings.TrimSpace(stdout.String()))
+		})
+	}
+}
+
+func TestWriteDotInvalidQuoteStyle(t *testing.T) {
+	t.Parallel()
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	terragruntOptions.GraphQuoteStyle = "single"
+
+	var stdout bytes.Buffer
+
+	err = createNamedGraphTestModules().WriteDot(&stdout, terragruntOptions)
+
+	var quoteStyleErr configstack.InvalidGraphQuoteStyleError
+	require.ErrorAs(t, err, &quoteStyleErr)
+	assert.Empty(t, stdout.String())
+}
+
+func TestWriteDotFeedsInto(t *testing.T) {
+	t.Parallel()
+
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/a"}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/b"}
+	e := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/e", Dependencies: configstack.TerraformModules{a}}
+	f := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/f", Dependencies: configstack.TerraformModules{a, b}}
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	terragruntOptions.GraphEdgeDirection = options.GraphEdgeDirectionFeedsInto
+
+	var stdout bytes.Buffer
+	require.NoError(t, configstack.TerraformModules{a, b, e, f}.WriteDot(&stdout, terragruntOptions))
+
+	expected := strings.TrimSpace(`
+digraph {
+	"a" ;
+	"b" ;
+	"e" ;
+	"a" -> "e";
+	"f" ;
+	"a" -> "f";
+	"b" -> "f";
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+
+	terragruntOptions.GraphEdgeDirection = "upstream"
+
+	var edgeDirectionErr configstack.InvalidGraphEdgeDirectionError
+	require.ErrorAs(t, configstack.TerraformModules{a, b, e, f}.WriteDot(&bytes.Buffer{}, terragruntOptions), &edgeDirectionErr)
+}
+
+func TestWriteDotByComponent(t *testing.T) {
+	t.Parallel()
+
+	vpc := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/vpc"}
+	dns := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/dns"}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/app", Dependencies: configstack.TerraformModules{vpc}}
+	cdn := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/cdn", Dependencies: configstack.TerraformModules{dns}}
+	web := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/web", Dependencies: configstack.TerraformModules{app, cdn}}
+	billing := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/billing"}
+	reports := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/reports", Dependencies: configstack.TerraformModules{billing}}
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	terragruntOptions.GraphByComponent = true
+
+	var stdout bytes.Buffer
+	require.NoError(t, configstack.TerraformModules{vpc, dns, billing, app, cdn, reports, web}.WriteDot(&stdout, terragruntOptions))
+
+	expected := strings.TrimSpace(`
+digraph {
+	subgraph "cluster_0" {
+		"vpc" ;
+		"dns" ;
+		"app" ;
+		"app" -> "vpc";
+		"cdn" ;
+		"cdn" -> "dns";
+		"web" ;
+		"web" -> "app";
+		"web" -> "cdn";
+	}
+	subgraph "cluster_1" {
+		"billing" ;
+		"reports" ;
+		"reports" -> "billing";
+	}
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+}
+
+func TestWriteDotShowBatches(t *testing.T) {
+	t.Parallel()
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/terragrunt.hcl")
+	require.NoError(t, err)
+
+	terragruntOptions.GraphShowBatches = true
+
+	var stdout bytes.Buffer
+	require.NoError(t, createGraphTestModules().WriteDot(&stdout, terragruntOptions))
+
+	expected := strings.TrimSpace(`
+digraph {
+	"a" [batch=0];
+	"b" [batch=0];
+	"c" [batch=0];
+	"d" [batch=0];
+	"e" [batch=1];
+	"e" -> "a";
+	"f" [batch=1];
+	"f" -> "a";
+	"f" -> "b";
+	"g" [batch=2];
+	"g" -> "e";
+	"h" [batch=3];
+	"h" -> "g";
+	"h" -> "f";
+	"h" -> "c";
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+}
+
+func TestWriteDotTransitiveReduction(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+
+	// h reaches a through g and e, so its direct edge to a is redundant
+	h := modules[7]
+	h.Dependencies = append(h.Dependencies, modules[0])
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/terragrunt.hcl")
+	require.NoError(t, err)
+
+	terragruntOptions.GraphTransitiveReduction = true
+
+	var stdout bytes.Buffer
+	require.NoError(t, modules.WriteDot(&stdout, terragruntOptions))
+
+	expected := strings.TrimSpace(`
+digraph {
+	"a" ;
+	"b" ;
+	"c" ;
+	"d" ;
+	"e" ;
+	"e" -> "a";
+	"f" ;
+	"f" -> "a";
+	"f" -> "b";
+	"g" ;
+	"g" -> "e";
+	"h" ;
+	"h" -> "g";
+	"h" -> "f";
+	"h" -> "c";
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+
+	// without the reduction, the redundant edge is drawn
+	terragruntOptions.GraphTransitiveReduction = false
+
+	stdout.Reset()
+	require.NoError(t, modules.WriteDot(&stdout, terragruntOptions))
+	assert.Contains(t, stdout.String(), `"h" -> "a";`)
+
+	// the reduction is only defined for a DAG
+	modules[0].Dependencies = configstack.TerraformModules{h}
+	terragruntOptions.GraphTransitiveReduction = true
+
+	stdout.Reset()
+
+	var cycleErr configstack.DependencyCycleError
+	require.ErrorAs(t, modules.WriteDot(&stdout, terragruntOptions), &cycleErr)
+	assert.Empty(t, stdout.String())
+}
+
+func TestWriteJSONModuleName(t *testing.T) {
+	t.Parallel()
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	require.NoError(t, createNamedGraphTestModules().WriteJSON(&stdout, terragruntOptions))
+
+	expected := `{
+  "nodes": [
+    {"id": "network/vpc", "label": "Network", "excluded": false},
+    {"id": "app", "label": "app", "excluded": true}
+  ],
+  "edges": [
+    {"from": "app", "to": "network/vpc"}
+  ]
+}`
+	assert.JSONEq(t, expected, stdout.String())
+}
+
+func TestGraphCustomTrimPrefix(t *testing.T) {
+	t.Parallel()
+
+	// by default, the paths would be relative to /stack/app, leaving /stack/network/vpc untrimmed
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/app/terragrunt.hcl")
+	require.NoError(t, err)
+
+	terragruntOptions.GraphTrimPrefix = "/stack/"
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, createNamedGraphTestModules().WriteJSON(&jsonOut, terragruntOptions))
+
+	expected := `{
+  "nodes": [
+    {"id": "network/vpc", "label": "Network", "excluded": false},
+    {"id": "app", "label": "app", "excluded": true}
+  ],
+  "edges": [
+    {"from": "app", "to": "network/vpc"}
+  ]
+}`
+	assert.JSONEq(t, expected, jsonOut.String())
+
+	// with or without a trailing slash
+	terragruntOptions.GraphTrimPrefix = "/stack"
+
+	var dotOut bytes.Buffer
+	require.NoError(t, createNamedGraphTestModules().WriteDot(&dotOut, terragruntOptions))
+	assert.Contains(t, dotOut.String(), `"app" -> "network/vpc";`)
+	assert.NotContains(t, dotOut.String(), "/stack")
+}
+
+func TestGraphModuleLabels(t *testing.T) {
+	t.Parallel()
+
+	vpc := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/vpc", Labels: map[string]string{"team": "network", "tier": "core"}}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/app", Dependencies: configstack.TerraformModules{vpc}}
+	modules := configstack.TerraformModules{vpc, app}
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, modules.WriteJSON(&jsonOut, terragruntOptions))
+
+	expected := `{
+  "nodes": [
+    {"id": "vpc", "label": "vpc", "excluded": false, "labels": {"team": "network", "tier": "core"}},
+    {"id": "app", "label": "app", "excluded": false}
+  ],
+  "edges": [
+    {"from": "app", "to": "vpc"}
+  ]
+}`
+	assert.JSONEq(t, expected, jsonOut.String())
+
+	var dotOut bytes.Buffer
+	require.NoError(t, modules.WriteDot(&dotOut, terragruntOptions))
+	assert.Contains(t, dotOut.String(), `"vpc" [tooltip="team=network, tier=core"];`)
+}
+
+func TestWriteMermaidModuleName(t *testing.T) {
+	t.Parallel()
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	require.NoError(t, createNamedGraphTestModules().WriteMermaid(&stdout, terragruntOptions))
+
+	expected := strings.TrimSpace(`
+flowchart TD
+	m0["Network"]
+	m1["app"]
+	m1 --> m0
+	classDef excluded stroke:red,color:red
+	class m1 excluded
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+}
+
+func TestWritePlantUML(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+	modules[3].FlagExcluded = true
+	modules[7].Name = `Front "end"`
+
+	// the order of the modules doesn't matter
+	modules[0], modules[7] = modules[7], modules[0]
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	require.NoError(t, modules.WritePlantUML(&stdout, terragruntOptions))
+
+	assert.Equal(t, `@startuml
+skinparam component<<excluded>> {
+	BorderColor red
+	FontColor red
+}
+component "a" as m0
+component "b" as m1
+component "c" as m2
+component "d" as m3 <<excluded>>
+component "e" as m4
+component "f" as m5
+component "g" as m6
+component "Front 'end'" as m7
+m4 --> m0
+m5 --> m0
+m5 --> m1
+m6 --> m4
+m7 --> m2
+m7 --> m5
+m7 --> m6
+@enduml
+`, stdout.String())
+}
+
+func TestWriteNeo4jCSV(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+	modules[1].AssumeAlreadyApplied = true
+	modules[3].FlagExcluded = true
+	modules[7].Name = `Front, "end"`
+
+	// the order of the modules doesn't matter
+	modules[0], modules[7] = modules[7], modules[0]
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var nodes, edges bytes.Buffer
+	require.NoError(t, modules.WriteNeo4jCSV(&nodes, &edges, terragruntOptions))
+
+	assert.Equal(t, `path:ID,label,excluded:boolean,assume_applied:boolean,:LABEL
+a,a,false,false,Module
+b,b,false,true,Module
+c,c,false,false,Module
+d,d,true,false,Module
+e,e,false,false,Module
+f,f,false,false,Module
+g,g,false,false,Module
+h,"Front, ""end""",false,false,Module
+`, nodes.String())
+
+	assert.Equal(t, `:START_ID,:END_ID,:TYPE
+e,a,DEPENDS_ON
+f,a,DEPENDS_ON
+f,b,DEPENDS_ON
+g,e,DEPENDS_ON
+h,c,DEPENDS_ON
+h,f,DEPENDS_ON
+h,g,DEPENDS_ON
+`, edges.String())
+
+	rows, err := csv.NewReader(&nodes).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, `Front, "end"`, rows[8][1])
+}
+
+func TestWriteGraphML(t *testing.T) {
+	t.Parallel()
+
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/a"}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/b", AssumeAlreadyApplied: true}
+	c := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/c"}
+	d := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/d", FlagExcluded: true}
+	e := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/e", Dependencies: configstack.TerraformModules{a}}
+	f := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/f", Dependencies: configstack.TerraformModules{a, b}}
+	g := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/g", Dependencies: configstack.TerraformModules{e}}
+	h := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "/stack/h", Name: "Frontend", Dependencies: configstack.TerraformModules{g, f, c}, ConsumedOutputs: map[string][]string{"/stack/g": {"endpoint", "id"}}}
+
+	modules := configstack.TerraformModules{a, b, c, d, e, f, g, h}
+
+	terragruntOptions, err := options.NewTerragruntOptionsForTest("/stack/terragrunt.hcl")
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	require.NoError(t, modules.WriteGraphML(&stdout, terragruntOptions))
+
+	var document struct {
+		XMLName xml.Name `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+		Graph   struct {
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []struct {
+				ID   string `xml:"id,attr"`
+				Data []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+
+	require.NoError(t, xml.Unmarshal(stdout.Bytes(), &document))
+	assert.Equal(t, "directed", document.Graph.EdgeDefault)
+
+	nodes := map[string]map[string]string{}
+
+	for _, node := range document.Graph.Nodes {
+		nodes[node.ID] = map[string]string{}
+		for _, data := range node.Data {
+			nodes[node.ID][data.Key] = data.Value
+		}
+	}
+
+	assert.Equal(t, map[string]map[string]string{
+		"a": {"label": "a", "excluded": "false", "assume_applied": "false"},
+		"b": {"label": "b", "excluded": "false", "assume_applied": "true"},
+		"c": {"label": "c", "excluded": "false", "assume_applied": "false"},
+		"d": {"label": "d", "excluded": "true", "assume_applied": "false"},
+		"e": {"label": "e", "excluded": "false", "assume_applied": "false"},
+		"f": {"label": "f", "excluded": "false", "assume_applied": "false"},
+		"g": {"label": "g", "excluded": "false", "assume_applied": "false"},
+		"h": {"label": "Frontend", "excluded": "false", "assume_applied": "false"},
+	}, nodes)
+
+	edges := []string{}
+	outputKeys := map[string]string{}
+
+	for _, edge := range document.Graph.Edges {
+		edges = append(edges, edge.Source+" -> "+edge.Target)
+
+		for _, data := range edge.Data {
+			outputKeys[edge.Source+" -> "+edge.Target] = data.Key + "=" + data.Value
+		}
+	}
+
+	assert.Equal(t, []string{"e -> a", "f -> a", "f -> b", "g -> e", "h -> c", "h -> f", "h -> g"}, edges)
+	assert.Equal(t, map[string]string{"h -> g": "output_keys=endpoint, id"}, outputKeys)
+
+	// the output doesn't depend on the order of the modules
+	reversed := slices.Clone(modules)
+	slices.Reverse(reversed)
+
+	var reversedOut bytes.Buffer
+	require.NoError(t, reversed.WriteGraphML(&reversedOut, terragruntOptions))
+	assert.Equal(t, stdout.String(), reversedOut.String())
+}
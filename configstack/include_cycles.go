@@ -0,0 +1,257 @@
+package configstack
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// includeEdge is an edge of the graph checked by CheckForIncludeCycles, from a module to a module it depends on or
+// whose config it includes. Include is the included config the edge comes from, if known: it is set on the edges to
+// the included configs, while the edges to the dependencies are only attributed to an include once they are found to
+// be part of a cycle.
+type includeEdge struct {
+	to      *TerraformModule
+	include string
+}
+
+// CheckForIncludeCycles checks for dependency cycles in the graph of the modules along with the includes of their
+// configs: a module whose config includes the config of another module is considered to depend on it, and the
+// dependencies declared in an included config are attributed to the include. Returns an IncludeCycleError pointing at
+// the include that is part of the first cycle found, if any. The cycles made of explicit dependencies only are left to
+// CheckForCycles. The included configs are only read to attribute the dependencies of the modules that are part of a
+// cycle, and those that can't be read are not attributed any dependency.
+func (modules TerraformModules) CheckForIncludeCycles() error {
+	byConfigPath := make(map[string]*TerraformModule, len(modules))
+	hasIncludes := false
+
+	for _, module := range modules {
+		if module.TerragruntOptions != nil {
+			byConfigPath[filepath.Clean(module.TerragruntOptions.TerragruntConfigPath)] = module
+		}
+
+		hasIncludes = hasIncludes || len(module.Config.ProcessedIncludes) > 0
+	}
+
+	if !hasIncludes {
+		return nil
+	}
+
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	var (
+		visited      = map[string]bool{}
+		onPath       = map[string]int{}
+		path         []*TerraformModule
+		edges        []includeEdge
+		dependencies = includedDependencies{}
+	)
+
+	var visit func(module *TerraformModule) error
+
+	visit = func(module *TerraformModule) error {
+		if visited[module.Path] {
+			return nil
+		}
+
+		if index, found := onPath[module.Path]; found {
+			return includeCycle(path[index:], edges[index:], module, dependencies)
+		}
+
+		onPath[module.Path] = len(path)
+		path = append(path, module)
+
+		for _, edge := range module.includeEdges(byConfigPath) {
+			edges = append(edges, edge)
+
+			if err := visit(edge.to); err != nil {
+				return err
+			}
+
+			edges = edges[:len(edges)-1]
+		}
+
+		path = path[:len(path)-1]
+		delete(onPath, module.Path)
+		visited[module.Path] = true
+
+		return nil
+	}
+
+	for _, module := range sortedModules {
+		if err := visit(module); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// includePaths returns the paths of the configs included by the config of the module, sorted.
+func (module *TerraformModule) includePaths() []string {
+	includePaths := make([]string, 0, len(module.Config.ProcessedIncludes))
+
+	for _, include := range module.Config.ProcessedIncludes {
+		includePath := include.Path
+		if !filepath.IsAbs(includePath) && module.TerragruntOptions != nil {
+			includePath = filepath.Join(filepath.Dir(module.TerragruntOptions.TerragruntConfigPath), includePath)
+		}
+
+		includePaths = append(includePaths, filepath.Clean(includePath))
+	}
+
+	sort.Strings(includePaths)
+
+	return includePaths
+}
+
+// includeEdges returns the edges of the module in the graph checked by CheckForIncludeCycles, sorted by the path of
+// the module they go to, given the modules by the path of their config.
+func (module *TerraformModule) includeEdges(byConfigPath map[string]*TerraformModule) []includeEdge {
+	edges := make([]includeEdge, 0, len(module.Dependencies))
+
+	for _, dependency := range module.Dependencies {
+		edges = append(edges, includeEdge{to: dependency})
+	}
+
+	for _, includePath := range module.includePaths() {
+		if included, found := byConfigPath[includePath]; found {
+			edges = append(edges, includeEdge{to: included, include: includePath})
+		}
+	}
+
+	sort.SliceStable(edges, func(i, j int) bool {
+		return edges[i].to.Path < edges[j].to.Path
+	})
+
+	return edges
+}
+
+// includeCycle returns the IncludeCycleError of the cycle going through the given modules along the given edges, back
+// to the given module, or nil if none of the edges comes from an include, reading the dependencies declared in the
+// included configs with the given includedDependencies.
+func includeCycle(modules []*TerraformModule, edges []includeEdge, closing *TerraformModule, dependencies includedDependencies) error {
+	for i, edge := range edges {
+		include := edge.include
+		if include == "" {
+			include = dependencies.declaringInclude(modules[i], edge.to)
+		}
+
+		if include == "" {
+			continue
+		}
+
+		cycle := make(DependencyCycleError, 0, len(modules)+1)
+		for _, module := range modules {
+			cycle = append(cycle, module.Path)
+		}
+
+		cycle = append(cycle, closing.Path)
+
+		return errors.New(IncludeCycleError{Cycle: cycle, Module: modules[i].Path, Include: include})
+	}
+
+	return nil
+}
+
+// includedDependencies are the paths of the dependencies declared in the included configs, as they are written in
+// them, by the path of the config, read once per check.
+type includedDependencies map[string][]string
+
+// declaringInclude returns the path of the first config included by the config of the given module that declares its
+// dependency on the given module, if any.
+func (dependencies includedDependencies) declaringInclude(module, dependency *TerraformModule) string {
+	for _, includePath := range module.includePaths() {
+		declared, found := dependencies[includePath]
+		if !found {
+			var err error
+
+			if declared, err = includedDependencyPaths(includePath); err != nil {
+				module.TerragruntOptions.Logger.Debugf("Could not read the dependencies declared in %s, included by module %s: %v", includePath, module.Path, err)
+			}
+
+			dependencies[includePath] = declared
+		}
+
+		for _, dependencyPath := range declared {
+			if path, err := util.CanonicalPath(dependencyPath, module.Path); err == nil && path == dependency.Path {
+				return includePath
+			}
+		}
+	}
+
+	return ""
+}
+
+// includedDependencyPaths returns the paths of the dependencies declared with a literal path in the dependency and
+// dependencies blocks of the included config at the given path, as they are written in the config. They are relative
+// to the modules that include it, as it is merged into their config.
+func includedDependencyPaths(includePath string) ([]string, error) {
+	if filepath.Ext(includePath) == ".json" {
+		return nil, nil
+	}
+
+	file, diags := hclparse.NewParser().ParseHCLFile(includePath)
+	if diags.HasErrors() {
+		return nil, errors.New(diags)
+	}
+
+	body, isSyntaxBody := file.Body.(*hclsyntax.Body)
+	if !isSyntaxBody {
+		return nil, nil
+	}
+
+	var paths []string
+
+	for _, block := range body.Blocks {
+		var attrName string
+
+		switch block.Type {
+		case config.MetadataDependency:
+			attrName = "config_path"
+		case config.MetadataDependencies:
+			attrName = "paths"
+		default:
+			continue
+		}
+
+		attr, found := block.Body.Attributes[attrName]
+		if !found {
+			continue
+		}
+
+		// only literals evaluate without a context, any other path is left out
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || !value.IsWhollyKnown() || value.IsNull() {
+			continue
+		}
+
+		var literals []cty.Value
+
+		switch {
+		case value.Type() == cty.String:
+			literals = []cty.Value{value}
+		case value.Type().IsTupleType() || value.Type().IsListType():
+			literals = value.AsValueSlice()
+		}
+
+		for _, literal := range literals {
+			if literal.Type() == cty.String {
+				paths = append(paths, literal.AsString())
+			}
+		}
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,144 @@
+package configstack
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+const (
+	// isolatedWorkdirManifest is the manifest of the files copied to the isolated working directory of a module.
+	isolatedWorkdirManifest = ".terragrunt-isolated-manifest"
+	// localStateFile is the state file written by the local backend in the directory terraform runs in.
+	localStateFile = "terraform.tfstate"
+)
+
+// isolatedOptions copies the directory of the module to a new temporary directory, leaving out the files matching the
+// given ignore patterns and the download dir of the module, and returns a copy of the given options running the module
+// in that directory, along with the function removing it. The TerragruntConfigPath is left as is, so that includes and
+// dependencies resolve relative to the original directory and read the real state of the dependencies. The local state
+// files written in the isolated directory are copied back to the original directory before it is removed, so that
+// the state of the modules with a local backend is not lost.
+func (module *TerraformModule) isolatedOptions(opts *options.TerragruntOptions, ignore []string) (*options.TerragruntOptions, func(), error) {
+	isolatedDir, err := os.MkdirTemp("", "terragrunt-isolated-")
+	if err != nil {
+		return nil, nil, errors.New(err)
+	}
+
+	moduleDir := filepath.Dir(opts.TerragruntConfigPath)
+
+	cleanup := func() {
+		if err := copyLocalStateBack(isolatedDir, moduleDir, opts.DownloadDir); err != nil {
+			opts.Logger.Errorf("Failed to copy the local state of module %s back from its isolated working directory %s: %v", module.Path, isolatedDir, err)
+			return
+		}
+
+		if err := os.RemoveAll(isolatedDir); err != nil {
+			opts.Logger.Warnf("Failed to remove the isolated working directory %s of module %s: %v", isolatedDir, module.Path, err)
+		}
+	}
+
+	filter := func(path string) bool {
+		if path == opts.DownloadDir {
+			return false
+		}
+
+		relPath, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return true
+		}
+
+		for _, pattern := range ignore {
+			if matchesIgnorePattern(pattern, relPath) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := util.CopyFolderContentsWithFilter(opts.Logger, moduleDir, isolatedDir, isolatedWorkdirManifest, filter); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	isolatedOptions, err := opts.Clone(opts.TerragruntConfigPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	isolatedOptions.WorkingDir = isolatedDir
+	isolatedOptions.DownloadDir = filepath.Join(isolatedDir, util.TerragruntCacheDir)
+
+	opts.Logger.Debugf("Running module %s in the isolated working directory %s", module.Path, isolatedDir)
+
+	return isolatedOptions, cleanup, nil
+}
+
+// copyLocalStateBack copies the local state files written in the given isolated working directory, i.e. the
+// terraform.tfstate files, their backups and the states of the workspaces, to the same place in the given module
+// directory, or in the given download dir for those written in the download dir of the isolated directory.
+func copyLocalStateBack(isolatedDir, moduleDir, downloadDir string) error {
+	isolatedDownloadDir := filepath.Join(isolatedDir, util.TerragruntCacheDir)
+
+	return filepath.WalkDir(isolatedDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// the .terraform directories hold the backend config and the providers, not the state
+		if entry.IsDir() && entry.Name() == options.DefaultTFDataDir {
+			return filepath.SkipDir
+		}
+
+		if entry.IsDir() || !isLocalStateFile(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(isolatedDir, path)
+		if err != nil {
+			return errors.New(err)
+		}
+
+		destination := filepath.Join(moduleDir, relPath)
+		if relPath, err := filepath.Rel(isolatedDownloadDir, path); err == nil && !strings.HasPrefix(relPath, "..") {
+			destination = filepath.Join(downloadDir, relPath)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+			return errors.New(err)
+		}
+
+		return util.CopyFile(path, destination)
+	})
+}
+
+// isLocalStateFile returns true if the file at the given path is written by a local backend: a terraform.tfstate file,
+// its backup, or a file in the terraform.tfstate.d directory of the workspaces.
+func isLocalStateFile(path string) bool {
+	switch filepath.Base(path) {
+	case localStateFile, localStateFile + ".backup":
+		return true
+	}
+
+	return util.ListContainsElement(strings.Split(filepath.ToSlash(path), "/"), localStateFile+".d")
+}
+
+// matchesIgnorePattern returns true if the given path, relative to the directory of the module, or its base name
+// matches the given glob pattern. As the directories are filtered before their contents, ignoring a directory ignores
+// everything in it.
+func matchesIgnorePattern(pattern, relPath string) bool {
+	if matched, _ := filepath.Match(pattern, filepath.ToSlash(relPath)); matched {
+		return true
+	}
+
+	matched, _ := filepath.Match(pattern, filepath.Base(relPath))
+
+	return matched
+}
@@ -0,0 +1,125 @@
+package configstack
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+)
+
+// junitTestSuites is the root of the JUnit XML report written to the JUnitReportFile of the options, with a single
+// test suite holding one test case per module.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSuiteName is the name of the test suite and the class name of the test cases of the JUnit report.
+const junitSuiteName = "terragrunt"
+
+// junitReport builds the JUnit report of the modules: a module that ran is a test case that passed or failed
+// depending on its error, while a module that never ran, e.g. because one of its dependencies failed, is skipped.
+func (modules RunningModules) junitReport() junitTestSuites {
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	suite := junitTestSuite{Name: junitSuiteName, Cases: []junitTestCase{}}
+	timeline := modules.timeline()
+
+	for _, path := range paths {
+		module := modules[path]
+
+		testCase := junitTestCase{
+			Name:      path,
+			ClassName: junitSuiteName,
+			Time:      junitSeconds(module.EndTime.Sub(module.StartTime).Seconds()),
+		}
+
+		switch {
+		case module.StartTime.IsZero():
+			testCase.Time = junitSeconds(0)
+
+			message := "module did not run"
+			if module.Err != nil {
+				message = module.Err.Error()
+			}
+
+			testCase.Skipped = &junitMessage{Message: message}
+			suite.Skipped++
+		case module.Err != nil:
+			testCase.Failure = &junitMessage{Message: module.Err.Error(), Text: module.Err.Error()}
+			suite.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	suite.Tests = len(suite.Cases)
+	suite.Time = junitSeconds(float64(timeline.DurationMs) / 1000) //nolint:mnd
+
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+// writeJUnitReport writes the JUnit report of the modules to the given file.
+func (modules RunningModules) writeJUnitReport(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New(err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		return errors.New(err)
+	}
+
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(modules.junitReport()); err != nil {
+		return errors.New(err)
+	}
+
+	if _, err := file.WriteString("\n"); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// junitSeconds formats a duration in seconds the way JUnit reports do, with millisecond precision.
+func junitSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64) //nolint:mnd
+}
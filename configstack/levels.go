@@ -0,0 +1,136 @@
+package configstack
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/shell"
+)
+
+// runLevels returns the level of every module in the order in which they run: 0 for the modules without dependencies,
+// and one more than their deepest dependency for the others. Unlike TerraformModules.Levels, the dependencies are those
+// of the run, so that the levels are reversed along with the order of the run.
+func (modules RunningModules) runLevels() map[string]int {
+	levels := make(map[string]int, len(modules))
+
+	var level func(module *RunningModule) int
+
+	level = func(module *RunningModule) int {
+		if moduleLevel, found := levels[module.Module.Path]; found {
+			return moduleLevel
+		}
+
+		// guards against cycles, which are reported before the run
+		levels[module.Module.Path] = 0
+
+		moduleLevel := 0
+
+		for _, dependency := range module.Dependencies {
+			moduleLevel = max(moduleLevel, level(dependency)+1)
+		}
+
+		levels[module.Module.Path] = moduleLevel
+
+		return moduleLevel
+	}
+
+	for _, module := range modules {
+		level(module)
+	}
+
+	return levels
+}
+
+// waitForLevel holds back the given module, whose dependencies are all done, if it is in a level after the current
+// one with PauseBetweenLevels. Returns false if the module is held back.
+func (scheduler *scheduler) waitForLevel(module *RunningModule) bool {
+	if scheduler.levels == nil {
+		return true
+	}
+
+	level := scheduler.levels[module.Module.Path]
+	if level <= scheduler.currentLevel {
+		return true
+	}
+
+	scheduler.trace.record(traceEventQueued, module.Module.Path, "waiting for level %d to finish", scheduler.currentLevel)
+	scheduler.levelWaiting[level] = append(scheduler.levelWaiting[level], module)
+
+	return false
+}
+
+// advanceLevel moves on to the next level with PauseBetweenLevels once all the modules of the current level have
+// finished, if the ContinueToLevel of the options or the user agrees, queueing the modules of that level whose
+// dependencies are all done. Otherwise, the modules that haven't finished yet are skipped. Levels of which all the
+// modules have already finished, e.g. because their dependencies failed, are passed through in the same way.
+func (scheduler *scheduler) advanceLevel() {
+	if scheduler.levels == nil {
+		return
+	}
+
+	for scheduler.remaining > 0 && scheduler.levelRemaining[scheduler.currentLevel] == 0 {
+		next := scheduler.currentLevel + 1
+
+		scheduler.opts.Logger.Infof("All the modules of level %d have finished", scheduler.currentLevel)
+
+		if proceed, err := scheduler.continueToLevel(next); !proceed || err != nil {
+			scheduler.skipLevelsFrom(next, err)
+			return
+		}
+
+		scheduler.currentLevel = next
+
+		waiting := scheduler.levelWaiting[next]
+		delete(scheduler.levelWaiting, next)
+
+		for _, module := range waiting {
+			// a waiting module is skipped when a member of its group fails
+			if module.Status != Finished {
+				scheduler.markReady(module)
+			}
+		}
+	}
+}
+
+// continueToLevel asks whether the modules of the given level should run, either through the ContinueToLevel callback
+// of the options or by prompting the user.
+func (scheduler *scheduler) continueToLevel(level int) (bool, error) {
+	if scheduler.opts.ContinueToLevel != nil {
+		return scheduler.opts.ContinueToLevel(scheduler.ctx, level)
+	}
+
+	prompt := fmt.Sprintf("Continue with the modules of level %d?", level)
+
+	return shell.PromptUserForYesNo(scheduler.ctx, prompt, scheduler.opts)
+}
+
+// skipLevelsFrom skips the modules that haven't started yet, as the run was not continued to the given level, or
+// could not be because of the given error.
+func (scheduler *scheduler) skipLevelsFrom(level int, err error) {
+	var skipped []*RunningModule
+
+	for _, module := range scheduler.modules {
+		if !scheduler.started[module.Module.Path] && module.Status != Finished {
+			// marked as finished before any of them is, so that the skipped modules are not reported as blocked by
+			// each other
+			module.Status = Finished
+			skipped = append(skipped, module)
+		}
+	}
+
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Module.Path < skipped[j].Module.Path
+	})
+
+	if err != nil {
+		scheduler.opts.Logger.Errorf("Could not continue to level %d, the %d remaining modules will be skipped: %v", level, len(skipped), err)
+	} else {
+		scheduler.opts.Logger.Infof("Not continuing to level %d, the %d remaining modules will be skipped", level, len(skipped))
+	}
+
+	scheduler.levelWaiting = map[int][]*RunningModule{}
+
+	for _, module := range skipped {
+		scheduler.finish(module, LevelNotContinuedError{Module: module.Module, Level: level, Err: err})
+	}
+}
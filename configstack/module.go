@@ -1,13 +1,20 @@
 package configstack
 
 import (
+	"bytes"
+	"container/heap"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
+	"hash/fnv"
+	"math"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gruntwork-io/terragrunt/internal/cache"
 	"github.com/gruntwork-io/terragrunt/pkg/log"
@@ -19,9 +26,23 @@ import (
 	"github.com/gruntwork-io/terragrunt/options"
 	"github.com/gruntwork-io/terragrunt/shell"
 	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 const maxLevelsOfRecursion = 20
+
+// terraformParallelismFlag limits the number of concurrent operations of a terraform command, accepted by the commands
+// of terraformParallelismCommands.
+const terraformParallelismFlag = "-parallelism"
+
+var terraformParallelismCommands = map[string]bool{
+	terraform.CommandNamePlan:    true,
+	terraform.CommandNameApply:   true,
+	terraform.CommandNameDestroy: true,
+	terraform.CommandNameImport:  true,
+}
+
 const existingModulesCacheName = "existingModules"
 
 // TerraformModule represents a single module (i.e. folder with Terraform templates), including the Terragrunt configuration for that
@@ -34,6 +55,63 @@ type TerraformModule struct {
 	TerragruntOptions    *options.TerragruntOptions
 	AssumeAlreadyApplied bool
 	FlagExcluded         bool
+	// Barrier marks a module that doesn't run anything and only synchronizes the modules around it: it finishes as
+	// soon as all of its dependencies are done, and the modules that depend on it wait for it as usual.
+	Barrier bool
+	// Phase of the module in a phased run: all the modules of a phase finish before any module of a higher phase
+	// starts. Within a phase, the modules run in dependency order.
+	Phase int
+	// RunLast makes the module run after all the modules that don't run last are done, as if it depended on them, e.g.
+	// for a final validation of the stack. The modules that run last run in dependency order among themselves, and
+	// ahead of all the other modules when the order is reversed.
+	RunLast bool
+	// Name is a friendly name for the module, displayed instead of its path in the graph outputs.
+	Name string
+	// Retry settings of the module, overriding the RetryMaxAttempts, RetrySleepInterval and RetryableErrors of the
+	// options when set, e.g. to retry only known-flaky modules. Setting any of them enables retries for the module.
+	RetryAttempts   int
+	RetrySleep      time.Duration
+	RetryableErrors []string
+	// TerraformParallelism is passed to the terraform commands of the module that walk its resources, such as plan and
+	// apply, as their -parallelism flag, e.g. to lower it for heavy modules. It is independent of the number of modules
+	// running concurrently. Zero leaves the flag out.
+	TerraformParallelism int
+	// Group makes the module part of an atomic group of modules: if any member of the group fails, the members that
+	// haven't started yet are skipped, while the modules outside of the group carry on as usual. The members still run
+	// in dependency order.
+	Group string
+	// OutputHash is the hash of the terraform outputs of the module, read right before it runs with
+	// PropagateChangesToDependents and updated once it has run. A module whose outputs hash differently after it runs
+	// makes its dependents that are assumed already applied run as well.
+	OutputHash string
+	// Labels are arbitrary key-value pairs annotating the module, read from the labels attribute of its config. They
+	// are matched by the ModuleSelector of the options, shown in the graph outputs and reported in the RunSummary.
+	Labels map[string]string
+	// Features are the experimental behaviors of the runner enabled for the module only, read from the features
+	// attribute of its config. The unknown features are warned about and ignored.
+	Features map[string]bool
+	// ResourceTags are the shared finite resources the module uses while it runs, with the amount of each it uses, read
+	// from the resource_tags attribute of its config. They are admitted against the ResourceCapacities of the options.
+	ResourceTags map[string]int
+	// ConsumedOutputs are the output keys the module reads from each of its dependencies, by the path of the
+	// dependency, found in the references to dependency.<name>.outputs.<key> in its config. They are shown on the
+	// edges of the graph outputs.
+	ConsumedOutputs map[string][]string
+	// Whether the module is excluded because it succeeded in the run given by RerunFailedFrom. Unlike the other
+	// excluded modules, it is not considered skipped by its dependents, as it is already applied.
+	succeededBefore bool
+}
+
+// hasRetryOverrides returns true if any of the retry settings of the module is set.
+func (module *TerraformModule) hasRetryOverrides() bool {
+	return module.RetryAttempts > 0 || module.RetrySleep > 0 || module.RetryableErrors != nil
+}
+
+// hasTerraformParallelism returns true if the module has a TerraformParallelism and runs a terraform command that
+// accepts the -parallelism flag.
+func (module *TerraformModule) hasTerraformParallelism() bool {
+	return module.TerraformParallelism > 0 && len(module.TerragruntOptions.TerraformCliArgs) > 0 &&
+		terraformParallelismCommands[module.TerragruntOptions.TerraformCommand]
 }
 
 // String renders this module as a human-readable string
@@ -56,15 +134,64 @@ func (module *TerraformModule) MarshalJSON() ([]byte, error) {
 // FlushOutput flushes buffer data to the output writer.
 func (module *TerraformModule) FlushOutput() error {
 	if writer, ok := module.TerragruntOptions.Writer.(*ModuleWriter); ok {
-		module.outputMu.Lock()
-		defer module.outputMu.Unlock()
-
-		return writer.Flush()
+		return module.flushOutput(writer)
 	}
 
 	return nil
 }
 
+// recoverPanic recovers from a panic while running the module, storing a ModulePanicError in the given error instead, so
+// that the panic fails the module rather than the whole run. It must be deferred.
+func (module *TerraformModule) recoverPanic(err *error) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	*err = errors.New(ModulePanicError{Module: module, Value: value, Stack: debug.Stack()})
+}
+
+// flushOutput flushes the buffer data of the given writer of the module to its output writer.
+func (module *TerraformModule) flushOutput(writer *ModuleWriter) error {
+	module.outputMu.Lock()
+	defer module.outputMu.Unlock()
+
+	return writer.Flush()
+}
+
+// hasTerraformConfig returns true if the module has terraform files in the folder of its resolved terraform source,
+// or in its own folder if it has no source. A remote source is assumed to hold terraform files, as it is not
+// downloaded until the module runs.
+func (module *TerraformModule) hasTerraformConfig() (bool, error) {
+	source, err := config.GetTerraformSourceURL(module.TerragruntOptions, &module.Config)
+	if err != nil {
+		return false, err
+	}
+
+	dir := module.Path
+
+	if source != "" {
+		sourceURL, err := terraform.ToSourceURL(source, module.Path)
+		if err != nil {
+			return false, err
+		}
+
+		if !terraform.IsLocalSource(sourceURL) {
+			return true, nil
+		}
+
+		// the double-slash separating the subdirectory of a local source is collapsed by the cleaning
+		dir = filepath.Clean(sourceURL.Path)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return false, errors.New(err)
+	}
+
+	return len(matches) > 0, nil
+}
+
 // Check for cycles using a depth-first-search as described here:
 // https://en.wikipedia.org/wiki/Topological_sorting#Depth-first_search
 //
@@ -291,242 +418,1328 @@ func FindWhereWorkingDirIsIncluded(ctx context.Context, terragruntOptions *optio
 	return matchedModules
 }
 
-// WriteDot is used to emit a GraphViz compatible definition
-// for a directed graph. It can be used to dump a .dot file.
-// This is a similar implementation to terraform's digraph https://github.com/hashicorp/terraform/blob/master/digraph/graphviz.go
-// adding some styling to modules that are excluded from the execution in *-all commands
-func (modules TerraformModules) WriteDot(w io.Writer, terragruntOptions *options.TerragruntOptions) error {
-	if _, err := w.Write([]byte("digraph {\n")); err != nil {
-		return errors.New(err)
-	}
-	defer func(w io.Writer, p []byte) {
-		_, err := w.Write(p)
-		if err != nil {
-			terragruntOptions.Logger.Warnf("Failed to close graphviz output: %v", err)
+// Reversed returns a copy of the given modules with the direction of every dependency edge flipped: if module B
+// depends on module A in the original list, module A depends on module B in the returned list. The order of the phases
+// is flipped as well, by negating the phase of every module, and the modules that run last are moved to a phase of
+// their own ahead of all the others. The original modules are left untouched. An edge pointing to a module that is not
+// part of the list cannot be reversed, so it is kept as is, which lets the cross-linking step report it the same way it
+// does for the forward graph.
+func (modules TerraformModules) Reversed() TerraformModules {
+	reversedModules := make(TerraformModules, 0, len(modules))
+	reversedModulesMap := make(TerraformModulesMap, len(modules))
+
+	lastPhase := math.MinInt
+	for _, module := range modules {
+		if !module.RunLast {
+			lastPhase = max(lastPhase, module.Phase)
 		}
-	}(w, []byte("}\n"))
+	}
 
-	// all paths are relative to the TerragruntConfigPath
-	prefix := filepath.Dir(terragruntOptions.TerragruntConfigPath) + "/"
+	for _, module := range modules {
+		reversedModule := *module
+		reversedModule.Dependencies = TerraformModules{}
+		reversedModule.Phase = -module.Phase
 
-	for _, source := range modules {
-		// apply a different coloring for excluded nodes
-		style := ""
-		if source.FlagExcluded {
-			style = "[color=red]"
+		if module.RunLast {
+			reversedModule.RunLast = false
+			reversedModule.Phase = -(lastPhase + 1)
 		}
 
-		nodeLine := fmt.Sprintf("\t\"%s\" %s;\n",
-			strings.TrimPrefix(source.Path, prefix), style)
-
-		_, err := w.Write([]byte(nodeLine))
-		if err != nil {
-			return errors.New(err)
-		}
+		reversedModules = append(reversedModules, &reversedModule)
+		reversedModulesMap[module.Path] = &reversedModule
+	}
 
-		for _, target := range source.Dependencies {
-			line := fmt.Sprintf("\t\"%s\" -> \"%s\";\n",
-				strings.TrimPrefix(source.Path, prefix),
-				strings.TrimPrefix(target.Path, prefix),
-			)
+	for _, module := range modules {
+		for _, dependency := range module.Dependencies {
+			reversedDependency, found := reversedModulesMap[dependency.Path]
+			if !found {
+				reversedModule := reversedModulesMap[module.Path]
+				reversedModule.Dependencies = append(reversedModule.Dependencies, dependency)
 
-			_, err := w.Write([]byte(line))
-			if err != nil {
-				return errors.New(err)
+				continue
 			}
+
+			reversedDependency.Dependencies = append(reversedDependency.Dependencies, reversedModulesMap[module.Path])
 		}
 	}
 
-	return nil
+	return reversedModules
 }
 
-// RunModules runs the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
-// TerragruntOptions object. The modules will be executed in an order determined by their inter-dependencies, using
-// as much concurrency as possible.
-func (modules TerraformModules) RunModules(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
-	runningModules, err := modules.ToRunningModules(NormalOrder)
-	if err != nil {
-		return err
+// Dependents returns the reverse of the dependency graph of the modules: the modules of the list that depend directly
+// on each module, in the order in which they appear in the list, so that a module is a dependent of every module in its
+// Dependencies. Every module of the list is a key, with no dependents if nothing depends on it.
+func (modules TerraformModules) Dependents() map[*TerraformModule]TerraformModules {
+	dependents := make(map[*TerraformModule]TerraformModules, len(modules))
+
+	for _, module := range modules {
+		if _, found := dependents[module]; !found {
+			dependents[module] = TerraformModules{}
+		}
+
+		for _, dependency := range module.Dependencies {
+			dependents[dependency] = append(dependents[dependency], module)
+		}
 	}
 
-	return runningModules.runModules(ctx, opts, parallelism)
+	return dependents
 }
 
-// RunModulesReverseOrder runs the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
-// TerragruntOptions object. The modules will be executed in the reverse order of their inter-dependencies, using
-// as much concurrency as possible.
-func (modules TerraformModules) RunModulesReverseOrder(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
-	runningModules, err := modules.ToRunningModules(ReverseOrder)
-	if err != nil {
-		return err
+// Subgraph returns the modules with the given paths together with all of their direct and transitive dependencies,
+// in the order in which they appear in the given modules. An error is returned if any of the paths is not part of the
+// list.
+func (modules TerraformModules) Subgraph(paths ...string) (TerraformModules, error) {
+	inSubgraph, unknownPaths := modules.dependencyClosure(paths)
+	if len(unknownPaths) > 0 {
+		return nil, errors.New(UnrecognizedModuleError(unknownPaths[0]))
 	}
 
-	return runningModules.runModules(ctx, opts, parallelism)
-}
+	subgraph := TerraformModules{}
 
-// RunModulesIgnoreOrder runs the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
-// TerragruntOptions object. The modules will be executed without caring for inter-dependencies.
-func (modules TerraformModules) RunModulesIgnoreOrder(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
-	runningModules, err := modules.ToRunningModules(IgnoreOrder)
-	if err != nil {
-		return err
+	for _, module := range modules {
+		if inSubgraph[module.Path] {
+			subgraph = append(subgraph, module)
+		}
 	}
 
-	return runningModules.runModules(ctx, opts, parallelism)
+	return subgraph, nil
 }
 
-// ToRunningModules converts the list of modules to a map from module path to a runningModule struct. This struct contains information
-// about executing the module, such as whether it has finished running or not and any errors that happened. Note that
-// this does NOT actually run the module. For that, see the RunModules method.
-func (modules TerraformModules) ToRunningModules(dependencyOrder DependencyOrder) (RunningModules, error) {
-	runningModules := RunningModules{}
+// BlastRadius returns the modules that would have to run again if the module with the given path changed: the module
+// together with all of its direct and transitive dependents, in the order in which they appear in the given modules. An
+// error is returned if the path is not part of the list.
+func (modules TerraformModules) BlastRadius(path string) (TerraformModules, error) {
+	var changed *TerraformModule
+
 	for _, module := range modules {
-		runningModules[module.Path] = newRunningModule(module)
+		if module.Path == path {
+			changed = module
+			break
+		}
 	}
 
-	crossLinkedModules, err := runningModules.crossLinkDependencies(dependencyOrder)
-	if err != nil {
-		return crossLinkedModules, err
+	if changed == nil {
+		return nil, errors.New(UnrecognizedModuleError(path))
 	}
 
-	return crossLinkedModules.RemoveFlagExcluded(), nil
-}
+	dependents := modules.Dependents()
+	affected := map[*TerraformModule]bool{}
 
-// CheckForCycles checks for dependency cycles in the given list of modules and return an error if one is found.
-func (modules TerraformModules) CheckForCycles() error {
-	visitedPaths := []string{}
-	currentTraversalPaths := []string{}
+	var addModule func(module *TerraformModule)
+
+	addModule = func(module *TerraformModule) {
+		if affected[module] {
+			return
+		}
+
+		affected[module] = true
+
+		for _, dependent := range dependents[module] {
+			addModule(dependent)
+		}
+	}
+
+	addModule(changed)
+
+	blastRadius := TerraformModules{}
 
 	for _, module := range modules {
-		err := module.checkForCyclesUsingDepthFirstSearch(&visitedPaths, &currentTraversalPaths)
-		if err != nil {
-			return err
+		if affected[module] {
+			blastRadius = append(blastRadius, module)
 		}
 	}
 
-	return nil
+	return blastRadius, nil
 }
 
-// flagExcludedDirs iterates over a module slice and flags all entries as excluded, which should be ignored via the terragrunt-exclude-dir CLI flag.
-func (modules TerraformModules) flagExcludedDirs(terragruntOptions *options.TerragruntOptions) TerraformModules {
+// UnreachableFrom returns the modules that are neither one of the given targets nor one of their direct or transitive
+// dependencies, i.e. the modules that are left out of the Subgraph of the targets, in the order in which they appear
+// in the given modules. Targets that are not part of the list are ignored.
+func (modules TerraformModules) UnreachableFrom(targets []string) TerraformModules {
+	reachable, _ := modules.dependencyClosure(targets)
+
+	unreachable := TerraformModules{}
+
 	for _, module := range modules {
-		if module.findModuleInPath(terragruntOptions.ExcludeDirs) {
-			// Mark module itself as excluded
-			module.FlagExcluded = true
+		if !reachable[module.Path] {
+			unreachable = append(unreachable, module)
 		}
+	}
 
-		// Mark all affected dependencies as excluded
+	return unreachable
+}
+
+// Levels returns the level of the modules and of their dependencies in the dependency graph, by path: 0 for the
+// modules without dependencies, and one more than the highest level of its dependencies for the others, so that the
+// modules of a level only depend on modules of lower levels.
+func (modules TerraformModules) Levels() map[string]int {
+	levels := map[string]int{}
+
+	for _, module := range modules {
+		module.level(levels)
+	}
+
+	return levels
+}
+
+// level returns the level of the module, memoized in the given levels by path.
+func (module *TerraformModule) level(levels map[string]int) int {
+	if level, found := levels[module.Path]; found {
+		return level
+	}
+
+	// guards against the infinite recursion of a cycle, reported elsewhere
+	levels[module.Path] = 0
+
+	level := 0
+	for _, dependency := range module.Dependencies {
+		level = max(level, dependency.level(levels)+1)
+	}
+
+	levels[module.Path] = level
+
+	return level
+}
+
+// StableTopoSort returns the modules in a topological order, every module coming after all of its dependencies, in which
+// the modules that are not ordered by their dependencies keep their order in the given modules: at every step, the
+// first module in the given order whose dependencies have all been placed comes next. Dependencies on modules that are
+// not part of the list are ignored. A DependencyCycleError is returned if the dependencies form a cycle.
+func (modules TerraformModules) StableTopoSort() (TerraformModules, error) {
+	index := make(map[string]int, len(modules))
+	for i, module := range modules {
+		index[module.Path] = i
+	}
+
+	remainingDependencies := make([]int, len(modules))
+	dependents := make([][]int, len(modules))
+
+	for i, module := range modules {
 		for _, dependency := range module.Dependencies {
-			if dependency.findModuleInPath(terragruntOptions.ExcludeDirs) {
-				dependency.FlagExcluded = true
+			if j, found := index[dependency.Path]; found {
+				remainingDependencies[i]++
+				dependents[j] = append(dependents[j], i)
 			}
 		}
 	}
 
-	return modules
-}
+	available := &intHeap{}
 
-// flagIncludedDirs iterates over a module slice and flags all entries not in the list specified via the terragrunt-include-dir CLI flag as excluded.
-func (modules TerraformModules) flagIncludedDirs(terragruntOptions *options.TerragruntOptions) TerraformModules {
-	// If we're not excluding by default, we should include everything by default.
-	// This can happen when a user doesn't set include flags.
-	if !terragruntOptions.ExcludeByDefault {
-		// If we aren't given any include directories, but are given the strict include flag,
-		// return no modules.
-		if terragruntOptions.StrictInclude {
-			return TerraformModules{}
+	for i := range modules {
+		if remainingDependencies[i] == 0 {
+			heap.Push(available, i)
 		}
+	}
 
-		return modules
+	sorted := make(TerraformModules, 0, len(modules))
+
+	for available.Len() > 0 {
+		i := heap.Pop(available).(int) //nolint:forcetypeassert
+		sorted = append(sorted, modules[i])
+
+		for _, dependent := range dependents[i] {
+			remainingDependencies[dependent]--
+			if remainingDependencies[dependent] == 0 {
+				heap.Push(available, dependent)
+			}
+		}
+	}
+
+	if len(sorted) < len(modules) {
+		if err := modules.CheckForCycles(); err != nil {
+			return nil, err
+		}
 	}
 
+	return sorted, nil
+}
+
+// SerialOrder returns the modules in the order in which a run with a parallelism of 1 runs them, for reproducing
+// ordering issues: the modules without dependencies are queued by path, and every module that runs queues the modules
+// depending on it whose dependencies have all run, by path. Unlike in a run, where the modules queued by the same
+// module may come in any order, the order is fully determined by the graph. Dependencies on modules that are not part
+// of the list are ignored. A DependencyCycleError is returned if the dependencies form a cycle.
+func (modules TerraformModules) SerialOrder() (TerraformModules, error) {
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	inList := make(map[string]bool, len(modules))
 	for _, module := range modules {
-		if module.findModuleInPath(terragruntOptions.IncludeDirs) {
-			module.FlagExcluded = false
-		} else {
-			module.FlagExcluded = true
+		inList[module.Path] = true
+	}
+
+	remainingDependencies := make(map[string]int, len(modules))
+	dependents := make(map[string]TerraformModules, len(modules))
+	queue := TerraformModules{}
+
+	for _, module := range sortedModules {
+		for _, dependency := range module.Dependencies {
+			if inList[dependency.Path] {
+				remainingDependencies[module.Path]++
+				dependents[dependency.Path] = append(dependents[dependency.Path], module)
+			}
+		}
+
+		if remainingDependencies[module.Path] == 0 {
+			queue = append(queue, module)
 		}
 	}
 
-	// Mark all affected dependencies as included before proceeding if not in strict include mode.
-	if !terragruntOptions.StrictInclude {
-		for _, module := range modules {
-			if !module.FlagExcluded {
-				for _, dependency := range module.Dependencies {
-					dependency.FlagExcluded = false
-				}
+	order := make(TerraformModules, 0, len(modules))
+
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		order = append(order, module)
+
+		for _, dependent := range dependents[module.Path] {
+			remainingDependencies[dependent.Path]--
+			if remainingDependencies[dependent.Path] == 0 {
+				queue = append(queue, dependent)
 			}
 		}
 	}
 
-	return modules
+	if len(order) < len(modules) {
+		if err := modules.CheckForCycles(); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
 }
 
-// flagModulesThatDontInclude iterates over a module slice and flags all modules that don't include at least one file in
-// the specified include list on the TerragruntOptions ModulesThatInclude attribute. Flagged modules will be filtered
-// out of the set.
-func (modules TerraformModules) flagModulesThatDontInclude(terragruntOptions *options.TerragruntOptions) (TerraformModules, error) {
-	// If no ModulesThatInclude is specified return the modules list instantly
-	if len(terragruntOptions.ModulesThatInclude) == 0 {
-		return modules, nil
+// intHeap is a min-heap of ints for container/heap.
+type intHeap []int
+
+func (h intHeap) Len() int           { return len(h) }
+func (h intHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *intHeap) Push(x any) {
+	*h = append(*h, x.(int)) //nolint:forcetypeassert
+}
+
+func (h *intHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// dependencyClosure returns the set of paths of the modules with the given paths and of all of their direct and
+// transitive dependencies, along with the given paths that are not part of the list.
+func (modules TerraformModules) dependencyClosure(paths []string) (map[string]bool, []string) {
+	modulesMap := make(TerraformModulesMap, len(modules))
+	for _, module := range modules {
+		modulesMap[module.Path] = module
 	}
 
-	modulesThatIncludeCanonicalPath := []string{}
+	inClosure := map[string]bool{}
+	unknownPaths := []string{}
 
-	for _, includePath := range terragruntOptions.ModulesThatInclude {
-		canonicalPath, err := util.CanonicalPath(includePath, terragruntOptions.WorkingDir)
-		if err != nil {
-			return nil, err
+	var addModule func(module *TerraformModule)
+
+	addModule = func(module *TerraformModule) {
+		if inClosure[module.Path] {
+			return
 		}
 
-		modulesThatIncludeCanonicalPath = append(modulesThatIncludeCanonicalPath, canonicalPath)
+		inClosure[module.Path] = true
+
+		for _, dependency := range module.Dependencies {
+			addModule(dependency)
+		}
 	}
 
-	for _, module := range modules {
-		// Ignore modules that are already excluded because this feature is a filter for excluding the subset, not
-		// including modules that have already been excluded through other means.
-		if module.FlagExcluded {
+	for _, path := range paths {
+		module, found := modulesMap[path]
+		if !found {
+			unknownPaths = append(unknownPaths, path)
 			continue
 		}
 
-		// Mark modules that don't include any of the specified paths as excluded. To do this, we first flag the module
-		// as excluded, and if it includes any path in the set, we set the exclude flag back to false.
-		module.FlagExcluded = true
-		for _, includeConfig := range module.Config.ProcessedIncludes {
-			// resolve include config to canonical path to compare with modulesThatIncludeCanonicalPath
-			// https://github.com/gruntwork-io/terragrunt/issues/1944
-			canonicalPath, err := util.CanonicalPath(includeConfig.Path, module.Path)
-			if err != nil {
-				return nil, err
-			}
-
-			if util.ListContainsElement(modulesThatIncludeCanonicalPath, canonicalPath) {
-				module.FlagExcluded = false
-			}
-		}
+		addModule(module)
+	}
 
-		// Also search module dependencies and exclude if the dependency path doesn't include any of the specified
-		// paths, using a similar logic.
-		for _, dependency := range module.Dependencies {
-			if dependency.FlagExcluded {
-				continue
-			}
+	return inClosure, unknownPaths
+}
 
-			dependency.FlagExcluded = true
-			for _, includeConfig := range dependency.Config.ProcessedIncludes {
-				canonicalPath, err := util.CanonicalPath(includeConfig.Path, module.Path)
-				if err != nil {
-					return nil, err
-				}
+// WouldCreateCycle returns true if making the module with the from path depend on the module with the to path would
+// create a dependency cycle, i.e. if the to module is the from module or already depends on it, directly or
+// transitively. The modules are left untouched. An error is returned if either path is not part of the list.
+func (modules TerraformModules) WouldCreateCycle(from, to string) (bool, error) {
+	reachable, unknownPaths := modules.dependencyClosure([]string{to})
+	if len(unknownPaths) > 0 {
+		return false, errors.New(UnrecognizedModuleError(to))
+	}
 
-				if util.ListContainsElement(modulesThatIncludeCanonicalPath, canonicalPath) {
-					dependency.FlagExcluded = false
-				}
-			}
+	for _, module := range modules {
+		if module.Path == from {
+			return reachable[from], nil
 		}
 	}
 
-	return modules, nil
+	return false, errors.New(UnrecognizedModuleError(from))
+}
+
+// RunPlanFor returns the minimal set of modules that has to run to reach the given target module, i.e. the target and
+// its dependency closure, batched into execution levels: the modules of a level only depend on modules of previous
+// levels, so the modules of each level can run concurrently once the previous levels are done.
+func (modules TerraformModules) RunPlanFor(target string) ([][]*TerraformModule, error) {
+	subgraph, err := modules.Subgraph(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := subgraph.CheckForCycles(); err != nil {
+		return nil, err
+	}
+
+	runningModules, err := subgraph.ToRunningModules(NormalOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := runningModules.toTerraformModuleGroups(len(subgraph))
+
+	// the groups are TerraformModules, which can't be appended as []*TerraformModule in one go
+	levels := make([][]*TerraformModule, len(groups))
+	for i, group := range groups {
+		levels[i] = group
+	}
+
+	return levels, nil
+}
+
+// MarkApplied flags the modules with the given paths as already applied, so that they are skipped when running the
+// modules. An error is returned if any of the paths is not part of the list, in which case no module is flagged.
+func (modules TerraformModules) MarkApplied(paths []string) error {
+	modulesMap := make(TerraformModulesMap, len(modules))
+	for _, module := range modules {
+		modulesMap[module.Path] = module
+	}
+
+	for _, path := range paths {
+		if _, found := modulesMap[path]; !found {
+			return errors.New(UnrecognizedModuleError(path))
+		}
+	}
+
+	for _, path := range paths {
+		modulesMap[path].AssumeAlreadyApplied = true
+	}
+
+	return nil
+}
+
+// RunnableDiff returns the modules that the change from the before options to the after options adds to and removes
+// from the set of modules that run, i.e. that are not excluded by the include, exclude, modules-that-include and
+// selector options. It helps reviewing the effect of a change of these options. The modules themselves are left
+// untouched and the returned modules are sorted by path.
+func (modules TerraformModules) RunnableDiff(before, after *options.TerragruntOptions) (added, removed TerraformModules, err error) {
+	runnableBefore, err := modules.runnablePaths(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	runnableAfter, err := modules.runnablePaths(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	added, removed = TerraformModules{}, TerraformModules{}
+
+	for _, module := range modules {
+		switch {
+		case runnableAfter[module.Path] && !runnableBefore[module.Path]:
+			added = append(added, module)
+		case runnableBefore[module.Path] && !runnableAfter[module.Path]:
+			removed = append(removed, module)
+		}
+	}
+
+	sort.Slice(added, func(i, j int) bool { return added[i].Path < added[j].Path })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Path < removed[j].Path })
+
+	return added, removed, nil
+}
+
+// runnablePaths returns the paths of the modules that run with the given options, flagging a clone of the modules the
+// same way the stack does.
+func (modules TerraformModules) runnablePaths(terragruntOptions *options.TerragruntOptions) (map[string]bool, error) {
+	clonedModules := modules.clone()
+
+	cloned := make(map[*TerraformModule]bool, len(clonedModules))
+	for _, module := range clonedModules {
+		cloned[module] = true
+	}
+
+	for _, module := range clonedModules {
+		module.FlagExcluded = false
+
+		// the dependencies outside of the modules are not cloned, but they are flagged too
+		for i, dependency := range module.Dependencies {
+			if !cloned[dependency] {
+				clonedDependency := *dependency
+				module.Dependencies[i] = &clonedDependency
+			}
+		}
+	}
+
+	flaggedModules, err := clonedModules.flagIncludedDirs(terragruntOptions).flagExcludedDirs(terragruntOptions).flagModulesThatDontInclude(terragruntOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if flaggedModules, err = flaggedModules.flagModulesThatDontMatchSelector(terragruntOptions); err != nil {
+		return nil, err
+	}
+
+	runnable := map[string]bool{}
+
+	for _, module := range flaggedModules {
+		if !module.FlagExcluded {
+			runnable[module.Path] = true
+		}
+	}
+
+	return runnable, nil
+}
+
+// Hash returns a digest of the stack, e.g. to be used as a cache key in CI. It covers the path of every module, the
+// paths of its dependencies and its Terragrunt configuration, so that changing any of them changes the hash, but it
+// doesn't depend on the order of the modules.
+func (modules TerraformModules) Hash() (string, error) {
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	hash := sha256.New()
+
+	for _, module := range sortedModules {
+		configHash, err := module.configHash()
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(hash, "module %q %s\n", module.Path, configHash)
+
+		dependencies := make([]string, 0, len(module.Dependencies))
+		for _, dependency := range module.Dependencies {
+			dependencies = append(dependencies, dependency.Path)
+		}
+
+		sort.Strings(dependencies)
+
+		for _, dependency := range dependencies {
+			fmt.Fprintf(hash, "dependency %q -> %q\n", module.Path, dependency)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SimilarModules returns the groups of modules that have the same dependencies and the same config shape, meaning that
+// they set the same configuration attributes and inputs, possibly to different values. Such modules might be candidates
+// for consolidation. This is advisory only: each group is sorted by path, the groups are sorted by their first path,
+// and modules whose config can't be inspected are left out.
+func (modules TerraformModules) SimilarModules() [][]string {
+	groupsBySignature := map[string][]string{}
+
+	for _, module := range modules {
+		shape, err := module.configShape()
+		if err != nil {
+			module.TerragruntOptions.Logger.Debugf("Failed to inspect the config of module %s: %v", module.Path, err)
+			continue
+		}
+
+		dependencies := make([]string, 0, len(module.Dependencies))
+		for _, dependency := range module.Dependencies {
+			dependencies = append(dependencies, dependency.Path)
+		}
+
+		sort.Strings(dependencies)
+
+		signature := fmt.Sprintf("dependencies=%q shape=%q", dependencies, shape)
+		groupsBySignature[signature] = append(groupsBySignature[signature], module.Path)
+	}
+
+	groups := [][]string{}
+
+	for _, paths := range groupsBySignature {
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			groups = append(groups, paths)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+
+	return groups
+}
+
+// configShape returns the sorted names of the configuration attributes set by the module, followed by the sorted names
+// of its inputs.
+func (module *TerraformModule) configShape() ([]string, error) {
+	configCty, err := config.TerragruntConfigAsCty(&module.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []string{}
+
+	for name, value := range configCty.AsValueMap() {
+		if value.IsNull() || name == config.MetadataInputs || value.RawEquals(cty.StringVal("")) {
+			continue
+		}
+
+		attributes = append(attributes, name)
+	}
+
+	sort.Strings(attributes)
+
+	inputs := make([]string, 0, len(module.Config.Inputs))
+	for name := range module.Config.Inputs {
+		inputs = append(inputs, "inputs."+name)
+	}
+
+	sort.Strings(inputs)
+
+	return append(attributes, inputs...), nil
+}
+
+// configHash returns a digest of the Terragrunt configuration of the module.
+func (module *TerraformModule) configHash() (string, error) {
+	configCty, err := config.TerragruntConfigAsCty(&module.Config)
+	if err != nil {
+		return "", err
+	}
+
+	configJSON, err := ctyjson.Marshal(configCty, configCty.Type())
+	if err != nil {
+		return "", errors.New(err)
+	}
+
+	configHash := sha256.Sum256(configJSON)
+
+	return hex.EncodeToString(configHash[:]), nil
+}
+
+// accountLabel is the label of a module declaring the cloud account it manages.
+const accountLabel = "account_id"
+
+// account returns the identity of the cloud account the module manages, whose API rate limits it shares with the other
+// modules of the account: its account_id label, or else the AWS_PROFILE of its environment. It returns an empty string
+// if the module has neither.
+func (module *TerraformModule) account() string {
+	if accountID := module.Labels[accountLabel]; accountID != "" {
+		return accountLabel + "=" + accountID
+	}
+
+	if module.TerragruntOptions != nil {
+		if profile := module.TerragruntOptions.Env["AWS_PROFILE"]; profile != "" {
+			return "AWS_PROFILE=" + profile
+		}
+	}
+
+	return ""
+}
+
+// backendFenceKeys are the remote state config keys identifying the storage shared by the modules of a backend, e.g. the
+// bucket of the s3 and gcs backends, or the storage account and container of the azurerm backend, along with the key
+// prefixes within it.
+var backendFenceKeys = []string{"bucket", "storage_account_name", "container_name", "prefix", "workspace_key_prefix"}
+
+// backendFence returns the concurrency fence of the module derived from its remote state config: the modules with the
+// same fence store their state in the same place, and so contend on its locks. It returns an empty string if the
+// module has no remote state, or if its remote state config doesn't identify a shared storage, as with the local
+// backend.
+func (module *TerraformModule) backendFence() string {
+	remoteState := module.Config.RemoteState
+	if remoteState == nil || module.AssumeAlreadyApplied || module.Barrier {
+		return ""
+	}
+
+	parts := []string{}
+
+	for _, key := range backendFenceKeys {
+		if value, found := remoteState.Config[key]; found {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return remoteState.Backend + ":" + strings.Join(parts, ",")
+}
+
+// stateKeyConfigKeys are the remote state config keys identifying where the state of a module is stored: the shared
+// storage identified by the backendFenceKeys, along with the key or path of the state within it.
+var stateKeyConfigKeys = append([]string{"key", "path"}, backendFenceKeys...)
+
+// stateKey returns where the module stores its state, derived from its remote state config. It returns an empty string
+// if the module has no remote state, or doesn't run anything.
+func (module *TerraformModule) stateKey() string {
+	remoteState := module.Config.RemoteState
+	if remoteState == nil || module.AssumeAlreadyApplied || module.Barrier || module.FlagExcluded {
+		return ""
+	}
+
+	parts := []string{}
+
+	for _, key := range stateKeyConfigKeys {
+		if value, found := remoteState.Config[key]; found {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	sort.Strings(parts)
+
+	return remoteState.Backend + ":" + strings.Join(parts, ",")
+}
+
+// terraformOutputs returns the terraform outputs of the module, as read with terraform output -json.
+func (module *TerraformModule) terraformOutputs(ctx context.Context) ([]byte, error) {
+	outputOptions, err := module.TerragruntOptions.Clone(module.TerragruntOptions.TerragruntConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := bytes.Buffer{}
+	outputOptions.ForwardTFStdout = true
+	outputOptions.TerraformLogsToJSON = false
+	outputOptions.Writer = &stdout
+	outputOptions.TerraformCommand = terraform.CommandNameOutput
+	outputOptions.TerraformCliArgs = []string{terraform.CommandNameOutput, "-json"}
+
+	if err := outputOptions.RunTerragrunt(ctx, outputOptions); err != nil {
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// outputHash returns a digest of the terraform outputs of the module.
+func (module *TerraformModule) outputHash(ctx context.Context) (string, error) {
+	outputsJSON, err := module.terraformOutputs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// the outputs are re-encoded, sorting their keys, so that the hash doesn't depend on their order
+	var outputs interface{}
+	if err := json.Unmarshal(outputsJSON, &outputs); err == nil {
+		if outputsJSON, err = json.Marshal(outputs); err != nil {
+			return "", errors.New(err)
+		}
+	}
+
+	outputHash := sha256.Sum256(outputsJSON)
+
+	return hex.EncodeToString(outputHash[:]), nil
+}
+
+// clone returns a copy of the given modules in which the dependencies of the copied modules point to the copies as
+// well, so that the graph of the copy can be modified without affecting the original one. Dependencies on modules
+// that are not part of the list are kept as is.
+func (modules TerraformModules) clone() TerraformModules {
+	clonedModules := make(TerraformModules, 0, len(modules))
+	clonedModulesMap := make(TerraformModulesMap, len(modules))
+
+	for _, module := range modules {
+		clonedModule := *module
+
+		clonedModules = append(clonedModules, &clonedModule)
+		clonedModulesMap[module.Path] = &clonedModule
+	}
+
+	for _, clonedModule := range clonedModules {
+		dependencies := make(TerraformModules, 0, len(clonedModule.Dependencies))
+
+		for _, dependency := range clonedModule.Dependencies {
+			if clonedDependency, found := clonedModulesMap[dependency.Path]; found {
+				dependency = clonedDependency
+			}
+
+			dependencies = append(dependencies, dependency)
+		}
+
+		clonedModule.Dependencies = dependencies
+	}
+
+	return clonedModules
+}
+
+// RunModules runs the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
+// TerragruntOptions object. The modules will be executed in an order determined by their inter-dependencies, using
+// as much concurrency as possible.
+func (modules TerraformModules) RunModules(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
+	runningModules, err := modules.ToRunningModules(NormalOrder)
+	if err != nil {
+		return err
+	}
+
+	return runningModules.runModules(ctx, opts, parallelism)
+}
+
+// RunModulesReverseOrder runs the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
+// TerragruntOptions object. The modules will be executed in the reverse order of their inter-dependencies, using
+// as much concurrency as possible. The running graph links the given modules along the edges of the Reversed graph.
+func (modules TerraformModules) RunModulesReverseOrder(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
+	runningModules, err := modules.ToRunningModules(ReverseOrder)
+	if err != nil {
+		return err
+	}
+
+	return runningModules.runModules(ctx, opts, parallelism)
+}
+
+// RunModulesIgnoreOrder runs the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
+// TerragruntOptions object. The modules will be executed without caring for inter-dependencies.
+func (modules TerraformModules) RunModulesIgnoreOrder(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
+	runningModules, err := modules.ToRunningModules(IgnoreOrder)
+	if err != nil {
+		return err
+	}
+
+	return runningModules.runModules(ctx, opts, parallelism)
+}
+
+// ToRunningModules converts the list of modules to a map from module path to a runningModule struct. This struct contains information
+// about executing the module, such as whether it has finished running or not and any errors that happened. Note that
+// this does NOT actually run the module. For that, see the RunModules method.
+func (modules TerraformModules) ToRunningModules(dependencyOrder DependencyOrder) (RunningModules, error) {
+	runningModules := RunningModules{}
+	for _, module := range modules {
+		runningModules[module.Path] = newRunningModule(module)
+	}
+
+	crossLinkedModules, err := runningModules.crossLinkDependencies(dependencyOrder)
+	if err != nil {
+		return crossLinkedModules, err
+	}
+
+	if dependencyOrder != IgnoreOrder {
+		if crossLinkedModules, err = crossLinkedModules.linkPhases(dependencyOrder); err != nil {
+			return crossLinkedModules, err
+		}
+	}
+
+	return crossLinkedModules.RemoveFlagExcluded(), nil
+}
+
+// CheckForCycles checks for dependency cycles in the given list of modules and return an error if one is found. If the
+// modules are entangled in more than that cycle, an EntangledModulesError wrapping the cycle lists all the strongly
+// connected components of the modules.
+func (modules TerraformModules) CheckForCycles() error {
+	visitedPaths := []string{}
+	currentTraversalPaths := []string{}
+
+	for _, module := range modules {
+		err := module.checkForCyclesUsingDepthFirstSearch(&visitedPaths, &currentTraversalPaths)
+		if err == nil {
+			continue
+		}
+
+		var cycle DependencyCycleError
+		if !errors.As(err, &cycle) {
+			return err
+		}
+
+		components := modules.StronglyConnectedComponents()
+
+		// the first module of the cycle is repeated at its end
+		if len(components) == 1 && len(components[0]) == len(cycle)-1 {
+			return err
+		}
+
+		entangledErr := EntangledModulesError{Cycle: cycle, Components: make([][]string, len(components))}
+		for i, component := range components {
+			for _, module := range component {
+				entangledErr.Components[i] = append(entangledErr.Components[i], module.Path)
+			}
+		}
+
+		return errors.New(entangledErr)
+	}
+
+	return nil
+}
+
+// StronglyConnectedComponents returns the strongly connected components of the dependency graph of the modules that are
+// part of a cycle, using Tarjan's algorithm: the sets of two or more modules that all depend on each other, directly or
+// not, and the modules that depend on themselves. The modules of each component are sorted by path, and the components
+// by the path of their first module. Dependencies on modules that are not part of the list are followed as well.
+func (modules TerraformModules) StronglyConnectedComponents() [][]*TerraformModule {
+	var (
+		// order in which the modules were visited, and lowest order of the modules reachable from each module that
+		// are still on the stack
+		index   = map[string]int{}
+		lowLink = map[string]int{}
+		onStack = map[string]bool{}
+		stack   []*TerraformModule
+
+		components    [][]*TerraformModule
+		strongConnect func(module *TerraformModule)
+	)
+
+	strongConnect = func(module *TerraformModule) {
+		index[module.Path] = len(index)
+		lowLink[module.Path] = index[module.Path]
+		stack = append(stack, module)
+		onStack[module.Path] = true
+
+		selfLoop := false
+
+		for _, dependency := range module.Dependencies {
+			if dependency.Path == module.Path {
+				selfLoop = true
+			}
+
+			if _, visited := index[dependency.Path]; !visited {
+				strongConnect(dependency)
+				lowLink[module.Path] = min(lowLink[module.Path], lowLink[dependency.Path])
+			} else if onStack[dependency.Path] {
+				lowLink[module.Path] = min(lowLink[module.Path], index[dependency.Path])
+			}
+		}
+
+		// the module is the root of a component, made of the modules above it on the stack
+		if lowLink[module.Path] != index[module.Path] {
+			return
+		}
+
+		var component []*TerraformModule
+
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top.Path] = false
+			component = append(component, top)
+
+			if top.Path == module.Path {
+				break
+			}
+		}
+
+		if len(component) > 1 || selfLoop {
+			sort.Slice(component, func(i, j int) bool {
+				return component[i].Path < component[j].Path
+			})
+
+			components = append(components, component)
+		}
+	}
+
+	for _, module := range modules {
+		if _, visited := index[module.Path]; !visited {
+			strongConnect(module)
+		}
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i][0].Path < components[j][0].Path
+	})
+
+	return components
+}
+
+// CheckForEmptyConfigs returns an error listing every module with an empty configuration, i.e. without terraform files
+// in its resolved terraform source or its folder, in the order of their paths. The excluded modules, the barriers and
+// the modules assumed to be already applied are left out, as they don't run.
+func (modules TerraformModules) CheckForEmptyConfigs() error {
+	sortedModules := make(TerraformModules, len(modules))
+	copy(sortedModules, modules)
+
+	sort.Slice(sortedModules, func(i, j int) bool {
+		return sortedModules[i].Path < sortedModules[j].Path
+	})
+
+	var errs *errors.MultiError
+
+	for _, module := range sortedModules {
+		if module.FlagExcluded || module.Barrier || module.AssumeAlreadyApplied {
+			continue
+		}
+
+		hasConfig, err := module.hasTerraformConfig()
+		if err != nil {
+			return err
+		}
+
+		if !hasConfig {
+			errs = errs.Append(EmptyModuleConfigError(module.Path))
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// CheckForIsolatedModules returns an IsolatedModulesError listing the modules that neither depend on another module
+// nor are a dependency of one, which usually means that their dependencies were not declared. The excluded modules are
+// left out, and a single module is not considered isolated.
+func (modules TerraformModules) CheckForIsolatedModules() error {
+	if len(modules) < 2 {
+		return nil
+	}
+
+	connected := map[string]bool{}
+
+	for _, module := range modules {
+		for _, dependency := range module.Dependencies {
+			connected[module.Path] = true
+			connected[dependency.Path] = true
+		}
+	}
+
+	var isolated IsolatedModulesError
+
+	for _, module := range modules {
+		if !connected[module.Path] && !module.FlagExcluded {
+			isolated = append(isolated, module.Path)
+		}
+	}
+
+	if len(isolated) == 0 {
+		return nil
+	}
+
+	sort.Strings(isolated)
+
+	return errors.New(isolated)
+}
+
+// ValidateRunnable checks the modules left to run once the excluded modules are left out, as filtering the modules
+// may break their graph: it returns the DependencyCycleError of their dependencies if any, along with a
+// MissingDependencyError for each dependency of theirs that is not part of the list, and an ExcludedDependencyError
+// for each dependency of theirs that is excluded, as those would fail them with a SkippedDependencyError unless
+// RunDependentsOnSkippedDependency is set.
+func (modules TerraformModules) ValidateRunnable() error {
+	var (
+		errs     *errors.MultiError
+		runnable = TerraformModules{}
+		inList   = make(map[string]bool, len(modules))
+	)
+
+	for _, module := range modules {
+		inList[module.Path] = true
+
+		if !module.FlagExcluded {
+			runnable = append(runnable, module)
+		}
+	}
+
+	if err := runnable.CheckForCycles(); err != nil {
+		errs = errs.Append(err)
+	}
+
+	for _, module := range runnable {
+		for _, dependency := range module.Dependencies {
+			switch {
+			case !inList[dependency.Path]:
+				errs = errs.Append(errors.New(MissingDependencyError{Module: module, Dependency: dependency}))
+			case dependency.FlagExcluded:
+				errs = errs.Append(errors.New(ExcludedDependencyError{Module: module, Dependency: dependency}))
+			}
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// CheckForStateKeyCollisions returns a StateKeyCollisionsError listing the state locations shared by several of the
+// modules, as read from their remote state configs, sorted by state location. The excluded modules are left out.
+func (modules TerraformModules) CheckForStateKeyCollisions() error {
+	paths := map[string][]string{}
+
+	for _, module := range modules {
+		if stateKey := module.stateKey(); stateKey != "" {
+			paths[stateKey] = append(paths[stateKey], module.Path)
+		}
+	}
+
+	var collisions StateKeyCollisionsError
+
+	for stateKey, modulePaths := range paths {
+		if len(modulePaths) > 1 {
+			sort.Strings(modulePaths)
+			collisions = append(collisions, StateKeyCollision{StateKey: stateKey, Paths: modulePaths})
+		}
+	}
+
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].StateKey < collisions[j].StateKey
+	})
+
+	return errors.New(collisions)
+}
+
+// flagExcludedDirs iterates over a module slice and flags all entries as excluded, which should be ignored via the terragrunt-exclude-dir CLI flag.
+func (modules TerraformModules) flagExcludedDirs(terragruntOptions *options.TerragruntOptions) TerraformModules {
+	for _, module := range modules {
+		if module.findModuleInPath(terragruntOptions.ExcludeDirs) {
+			// Mark module itself as excluded
+			module.FlagExcluded = true
+		}
+
+		// Mark all affected dependencies as excluded
+		for _, dependency := range module.Dependencies {
+			if dependency.findModuleInPath(terragruntOptions.ExcludeDirs) {
+				dependency.FlagExcluded = true
+			}
+		}
+	}
+
+	return modules
+}
+
+// flagIncludedDirs iterates over a module slice and flags all entries not in the list specified via the terragrunt-include-dir CLI flag as excluded.
+func (modules TerraformModules) flagIncludedDirs(terragruntOptions *options.TerragruntOptions) TerraformModules {
+	// If we're not excluding by default, we should include everything by default.
+	// This can happen when a user doesn't set include flags.
+	if !terragruntOptions.ExcludeByDefault {
+		// If we aren't given any include directories, but are given the strict include flag,
+		// return no modules.
+		if terragruntOptions.StrictInclude {
+			return TerraformModules{}
+		}
+
+		return modules
+	}
+
+	for _, module := range modules {
+		if module.findModuleInPath(terragruntOptions.IncludeDirs) {
+			module.FlagExcluded = false
+		} else {
+			module.FlagExcluded = true
+		}
+	}
+
+	// Mark all affected dependencies as included before proceeding if not in strict include mode.
+	if !terragruntOptions.StrictInclude {
+		for _, module := range modules {
+			if !module.FlagExcluded {
+				for _, dependency := range module.Dependencies {
+					dependency.FlagExcluded = false
+				}
+			}
+		}
+	}
+
+	return modules
+}
+
+// flagModulesThatDontInclude iterates over a module slice and flags all modules that don't include at least one file in
+// the specified include list on the TerragruntOptions ModulesThatInclude attribute. Flagged modules will be filtered
+// out of the set.
+func (modules TerraformModules) flagModulesThatDontInclude(terragruntOptions *options.TerragruntOptions) (TerraformModules, error) {
+	// If no ModulesThatInclude is specified return the modules list instantly
+	if len(terragruntOptions.ModulesThatInclude) == 0 {
+		return modules, nil
+	}
+
+	modulesThatIncludeCanonicalPath := []string{}
+
+	for _, includePath := range terragruntOptions.ModulesThatInclude {
+		canonicalPath, err := util.CanonicalPath(includePath, terragruntOptions.WorkingDir)
+		if err != nil {
+			return nil, err
+		}
+
+		modulesThatIncludeCanonicalPath = append(modulesThatIncludeCanonicalPath, canonicalPath)
+	}
+
+	for _, module := range modules {
+		// Ignore modules that are already excluded because this feature is a filter for excluding the subset, not
+		// including modules that have already been excluded through other means.
+		if module.FlagExcluded {
+			continue
+		}
+
+		// Mark modules that don't include any of the specified paths as excluded. To do this, we first flag the module
+		// as excluded, and if it includes any path in the set, we set the exclude flag back to false.
+		module.FlagExcluded = true
+		for _, includeConfig := range module.Config.ProcessedIncludes {
+			// resolve include config to canonical path to compare with modulesThatIncludeCanonicalPath
+			// https://github.com/gruntwork-io/terragrunt/issues/1944
+			canonicalPath, err := util.CanonicalPath(includeConfig.Path, module.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			if util.ListContainsElement(modulesThatIncludeCanonicalPath, canonicalPath) {
+				module.FlagExcluded = false
+			}
+		}
+
+		// Also search module dependencies and exclude if the dependency path doesn't include any of the specified
+		// paths, using a similar logic.
+		for _, dependency := range module.Dependencies {
+			if dependency.FlagExcluded {
+				continue
+			}
+
+			dependency.FlagExcluded = true
+			for _, includeConfig := range dependency.Config.ProcessedIncludes {
+				canonicalPath, err := util.CanonicalPath(includeConfig.Path, module.Path)
+				if err != nil {
+					return nil, err
+				}
+
+				if util.ListContainsElement(modulesThatIncludeCanonicalPath, canonicalPath) {
+					dependency.FlagExcluded = false
+				}
+			}
+		}
+	}
+
+	return modules, nil
+}
+
+// flagModulesThatDontMatchSelector iterates over a module slice and flags all modules whose labels don't match the
+// selector specified on the TerragruntOptions ModuleSelector attribute as excluded. If ModuleSelectorIncludeDeps is
+// set, the dependencies of the matching modules are kept in the set.
+func (modules TerraformModules) flagModulesThatDontMatchSelector(terragruntOptions *options.TerragruntOptions) (TerraformModules, error) {
+	if terragruntOptions.ModuleSelector == "" {
+		return modules, nil
+	}
+
+	selector, err := parseModuleSelector(terragruntOptions.ModuleSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[*TerraformModule]bool{}
+
+	var selectModule func(module *TerraformModule)
+
+	selectModule = func(module *TerraformModule) {
+		if selected[module] || module.FlagExcluded {
+			return
+		}
+
+		selected[module] = true
+
+		if terragruntOptions.ModuleSelectorIncludeDeps {
+			for _, dependency := range module.Dependencies {
+				selectModule(dependency)
+			}
+		}
+	}
+
+	for _, module := range modules {
+		if module.matchesSelector(selector) {
+			selectModule(module)
+		}
+	}
+
+	// Ignore modules that are already excluded, as the selector only narrows down the set of modules.
+	for _, module := range modules {
+		if !selected[module] {
+			module.FlagExcluded = true
+		}
+	}
+
+	return modules, nil
+}
+
+// labelsString renders the labels of the module as `key=value` pairs sorted by key, or an empty string if it has none.
+func (module *TerraformModule) labelsString() string {
+	pairs := make([]string, 0, len(module.Labels))
+	for key, val := range module.Labels {
+		pairs = append(pairs, key+"="+val)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ", ")
+}
+
+// matchesSelector returns true if the module has a label for every key of the selector with the same value.
+func (module *TerraformModule) matchesSelector(selector map[string]string) bool {
+	for key, val := range selector {
+		if label, ok := module.Labels[key]; !ok || label != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseModuleSelector parses a selector in the `key=value,key2=value2` format into a map.
+func parseModuleSelector(selector string) (map[string]string, error) {
+	result := map[string]string{}
+
+	for _, pair := range strings.Split(selector, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, errors.New(InvalidModuleSelectorError(selector))
+		}
+
+		result[key] = strings.TrimSpace(val)
+	}
+
+	return result, nil
+}
+
+// flagModulesOutsideShard keeps the runnable modules of the ShardIndex out of ShardTotal shards, the modules being
+// partitioned deterministically by a hash of their path relative to the working directory, so that several machines
+// can share a run, each with its own shard. The runnable modules of the other shards are assumed already applied, so
+// that the modules of this shard that depend on them still run.
+func (modules TerraformModules) flagModulesOutsideShard(terragruntOptions *options.TerragruntOptions) (TerraformModules, error) {
+	if terragruntOptions.ShardTotal <= 0 {
+		return modules, nil
+	}
+
+	if terragruntOptions.ShardIndex < 0 || terragruntOptions.ShardIndex >= terragruntOptions.ShardTotal {
+		return nil, errors.New(InvalidShardError{Index: terragruntOptions.ShardIndex, Total: terragruntOptions.ShardTotal})
+	}
+
+	for _, module := range modules {
+		if module.FlagExcluded || module.shard(terragruntOptions) == terragruntOptions.ShardIndex {
+			continue
+		}
+
+		module.AssumeAlreadyApplied = true
+	}
+
+	return modules, nil
+}
+
+// shard returns the shard of the module out of the ShardTotal of the given options.
+func (module *TerraformModule) shard(terragruntOptions *options.TerragruntOptions) int {
+	path := module.Path
+	if relPath, err := filepath.Rel(terragruntOptions.WorkingDir, module.Path); err == nil {
+		path = filepath.ToSlash(relPath)
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(path)) //nolint:errcheck
+
+	return int(hash.Sum32() % uint32(terragruntOptions.ShardTotal)) //nolint:gosec
 }
 
 var existingModules = cache.NewCache[*TerraformModulesMap](existingModulesCacheName)
@@ -0,0 +1,262 @@
+package configstack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/terraform"
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// moduleHashes is the JSON form of the ModuleHashFile of the options, with the content hash of every module as of its
+// last successful run:
+//
+//	{
+//	  "modules": {
+//	    "/stack/vpc": {"hash": "9f86d08..."}
+//	  }
+//	}
+type moduleHashes struct {
+	Modules map[string]moduleHashEntry `json:"modules"`
+}
+
+type moduleHashEntry struct {
+	Hash string `json:"hash"`
+}
+
+// readModuleHashes reads the module hashes from the given file, returning no hashes if the file does not exist yet.
+func readModuleHashes(path string) (moduleHashes, error) {
+	hashes := moduleHashes{Modules: map[string]moduleHashEntry{}}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return hashes, nil
+	}
+
+	if err != nil {
+		return hashes, errors.New(err)
+	}
+
+	if err := json.Unmarshal(contents, &hashes); err != nil {
+		return hashes, errors.New(err)
+	}
+
+	if hashes.Modules == nil {
+		hashes.Modules = map[string]moduleHashEntry{}
+	}
+
+	return hashes, nil
+}
+
+// write writes the module hashes to the given file as JSON.
+func (hashes moduleHashes) write(path string) error {
+	contents, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return errors.New(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	if err := os.WriteFile(path, append(contents, '\n'), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// contentHash returns a digest of the module covering its parsed configuration, which includes the settings inherited
+// from the included files, the contents of its directory, such as its Terragrunt configuration file and its .tf files,
+// and its terraform source: the URL of the source, along with the contents of the source directory if it is local.
+func (module *TerraformModule) contentHash() (string, error) {
+	configHash, err := module.configHash()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "config %s\n", configHash)
+
+	if err := hashDirContents(hash, module.Path); err != nil {
+		return "", err
+	}
+
+	if module.Config.Terraform != nil && module.Config.Terraform.Source != nil {
+		sourceURL, err := terraform.ToSourceURL(*module.Config.Terraform.Source, module.Path)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(hash, "source %s\n", sourceURL)
+
+		// the source directory of a local source is the part before the double-slash, if any, as with the downloads
+		sourceDir := strings.SplitN(sourceURL.Path, "//", 2)[0] //nolint:mnd
+		if terraform.IsLocalSource(sourceURL) && util.IsDir(sourceDir) {
+			if err := hashDirContents(hash, sourceDir); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashDirContents writes the path relative to the given directory and the contents of every file in the directory and
+// its subdirectories to the given hash. The files generated by Terragrunt and terraform, in the .terragrunt-cache and
+// .terraform directories, are left out, as are the subdirectories holding other modules.
+func hashDirContents(hash io.Writer, dir string) error {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if entry.Name() == util.TerragruntCacheDir || entry.Name() == options.DefaultTFDataDir {
+				return filepath.SkipDir
+			}
+
+			if path != dir && util.FileExists(filepath.Join(path, config.DefaultTerragruntConfigPath)) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "file %s %d\n", filepath.ToSlash(relPath), len(contents))
+		hash.Write(contents) //nolint:errcheck
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// update records the hashes of the modules that ran successfully. The modules whose hash can't be computed are left
+// out, so that they run again.
+func (hashes moduleHashes) update(modules RunningModules) {
+	for path, module := range modules {
+		if !module.ranSuccessfully() {
+			continue
+		}
+
+		hash, err := module.Module.contentHash()
+		if err != nil {
+			module.Module.TerragruntOptions.Logger.Debugf("Failed to hash the contents of module %s: %v", path, err)
+			delete(hashes.Modules, path)
+
+			continue
+		}
+
+		hashes.Modules[path] = moduleHashEntry{Hash: hash}
+	}
+}
+
+// recordModuleHashes records the hashes of the modules that ran successfully in the module hashes of the given file,
+// keeping the hashes of the other modules.
+func (modules RunningModules) recordModuleHashes(path string) error {
+	hashes, err := readModuleHashes(path)
+	if err != nil {
+		return err
+	}
+
+	hashes.update(modules)
+
+	return hashes.write(path)
+}
+
+// flagUnchangedModules assumes the modules whose content hash is the same as in the ModuleHashFile of the given
+// options to be already applied, if RunChangedOnly is set. With RunDependentsOfChangedModules, the modules depending
+// on a changed module are considered changed as well.
+func (modules TerraformModules) flagUnchangedModules(terragruntOptions *options.TerragruntOptions) (TerraformModules, error) {
+	if !terragruntOptions.RunChangedOnly || terragruntOptions.ModuleHashFile == "" {
+		return modules, nil
+	}
+
+	hashes, err := readModuleHashes(terragruntOptions.ModuleHashFile)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := map[string]bool{}
+
+	for _, module := range modules {
+		entry, found := hashes.Modules[module.Path]
+		if !found {
+			changed[module.Path] = true
+			continue
+		}
+
+		hash, err := module.contentHash()
+		if err != nil {
+			module.TerragruntOptions.Logger.Debugf("Failed to hash the contents of module %s: %v", module.Path, err)
+		}
+
+		changed[module.Path] = err != nil || hash != entry.Hash
+	}
+
+	if terragruntOptions.RunDependentsOfChangedModules {
+		for _, module := range modules {
+			module.dependsOnChangedModule(changed, map[string]bool{})
+		}
+	}
+
+	for _, module := range modules {
+		if !changed[module.Path] && !module.FlagExcluded {
+			module.TerragruntOptions.Logger.Debugf("Module %s did not change since its last successful run, assuming it is already applied", module.Path)
+			module.AssumeAlreadyApplied = true
+		}
+	}
+
+	return modules, nil
+}
+
+// dependsOnChangedModule returns true if the module or one of its dependencies, directly or indirectly, changed,
+// marking the module as changed in the given map if so.
+func (module *TerraformModule) dependsOnChangedModule(changed map[string]bool, visited map[string]bool) bool {
+	if changed[module.Path] {
+		return true
+	}
+
+	if visited[module.Path] {
+		return false
+	}
+
+	visited[module.Path] = true
+
+	for _, dependency := range module.Dependencies {
+		if dependency.dependsOnChangedModule(changed, visited) {
+			changed[module.Path] = true
+			return true
+		}
+	}
+
+	return false
+}
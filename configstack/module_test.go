@@ -1,17 +1,42 @@
 package configstack_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	terraformcmd "github.com/gruntwork-io/terragrunt/cli/commands/terraform"
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/configstack"
 	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/cli"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/gruntwork-io/terragrunt/remote"
+	"github.com/gruntwork-io/terragrunt/shell"
+	"github.com/gruntwork-io/terragrunt/terraform"
+	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestGraph(t *testing.T) {
@@ -131,6 +156,32 @@ digraph {
 	assert.True(t, strings.Contains(stdout.String(), expected))
 }
 
+func TestGraphCompact(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+	modules[5].FlagExcluded = true
+
+	var stdout bytes.Buffer
+	terragruntOptions, _ := options.NewTerragruntOptionsForTest("/terragrunt.hcl")
+	terragruntOptions.GraphCompact = true
+	modules.WriteDot(&stdout, terragruntOptions)
+	expected := strings.TrimSpace(`
+digraph {
+	"d" ;
+	"e" -> "a";
+	"f" [color=red];
+	"f" -> "a";
+	"f" -> "b";
+	"g" -> "e";
+	"h" -> "g";
+	"h" -> "f";
+	"h" -> "c";
+}
+`)
+	assert.Equal(t, expected, strings.TrimSpace(stdout.String()))
+}
+
 func TestCheckForCycles(t *testing.T) {
 	t.Parallel()
 
@@ -211,173 +262,2848 @@ func TestCheckForCycles(t *testing.T) {
 	}
 }
 
-func TestRunModulesNoModules(t *testing.T) {
+func TestCheckForIsolatedModules(t *testing.T) {
 	t.Parallel()
 
-	opts, err := options.NewTerragruntOptionsForTest("")
-	require.NoError(t, err)
+	// c -> b -> a
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a"}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{a}}
+	c := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{b}}
 
-	modules := configstack.TerraformModules{}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
+	// d -> a
+	connectedD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", Dependencies: configstack.TerraformModules{a}}
+	isolatedD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d"}
+	excludedD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", FlagExcluded: true}
+
+	testCases := []struct {
+		modules  configstack.TerraformModules
+		expected configstack.IsolatedModulesError
+	}{
+		{configstack.TerraformModules{}, nil},
+		{configstack.TerraformModules{isolatedD}, nil},
+		{configstack.TerraformModules{a, b, c, connectedD}, nil},
+		{configstack.TerraformModules{a, b, c, isolatedD}, configstack.IsolatedModulesError{"d"}},
+		{configstack.TerraformModules{a, b, c, excludedD}, nil},
+		{configstack.TerraformModules{isolatedD, a}, configstack.IsolatedModulesError{"a", "d"}},
+	}
+
+	for _, testCase := range testCases {
+		actual := testCase.modules.CheckForIsolatedModules()
+		if testCase.expected == nil {
+			require.NoError(t, actual)
+		} else if assert.Error(t, actual, "For modules %v", testCase.modules) {
+			var actualErr configstack.IsolatedModulesError
+			require.ErrorAs(t, actual, &actualErr)
+			assert.Equal(t, testCase.expected, actualErr, "For modules %v", testCase.modules)
+		}
+	}
 }
 
-func TestRunModulesOneModuleSuccess(t *testing.T) {
+func TestCheckForStateKeyCollisions(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	newModule := func(path, key string) *configstack.TerraformModule {
+		remoteState := &remote.RemoteState{Backend: "s3", Config: map[string]interface{}{"bucket": "state", "key": key}}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Config: config.TerragruntConfig{RemoteState: remoteState}}
 	}
 
-	opts, err := options.NewTerragruntOptionsForTest("")
-	require.NoError(t, err)
+	a := newModule("a", "a/terraform.tfstate")
+	b := newModule("b", "b/terraform.tfstate")
+	copiedB := newModule("copied-b", "b/terraform.tfstate")
+	excludedB := newModule("excluded-b", "b/terraform.tfstate")
+	excludedB.FlagExcluded = true
+	local := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "local"}
 
-	modules := configstack.TerraformModules{moduleA}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
-	assert.True(t, aRan)
+	require.NoError(t, configstack.TerraformModules{a, b, local}.CheckForStateKeyCollisions())
+	require.NoError(t, configstack.TerraformModules{a, b, excludedB}.CheckForStateKeyCollisions())
+
+	err := configstack.TerraformModules{a, b, copiedB, local}.CheckForStateKeyCollisions()
+
+	var actualErr configstack.StateKeyCollisionsError
+	require.ErrorAs(t, err, &actualErr)
+	assert.Equal(t, configstack.StateKeyCollisionsError{
+		{StateKey: "s3:bucket=state,key=b/terraform.tfstate", Paths: []string{"b", "copied-b"}},
+	}, actualErr)
 }
 
-func TestRunModulesOneModuleAssumeAlreadyRan(t *testing.T) {
+func TestDependents(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:                &configstack.Stack{},
-		Path:                 "a",
-		Dependencies:         configstack.TerraformModules{},
-		Config:               config.TerragruntConfig{},
-		TerragruntOptions:    optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
-		AssumeAlreadyApplied: true,
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a"}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b"}
+	c := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c"}
+	d := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d"}
+
+	// e -> a, f -> a, b, g -> e, h -> g, f, c
+	e := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "e", Dependencies: configstack.TerraformModules{a}}
+	f := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "f", Dependencies: configstack.TerraformModules{a, b}}
+	g := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "g", Dependencies: configstack.TerraformModules{e}}
+	h := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "h", Dependencies: configstack.TerraformModules{g, f, c}}
+
+	modules := configstack.TerraformModules{a, b, c, d, e, f, g, h}
+	dependents := modules.Dependents()
+
+	assert.Equal(t, []string{"e", "f"}, modulePaths(dependents[a]))
+	assert.Equal(t, []string{"f"}, modulePaths(dependents[b]))
+	assert.Equal(t, []string{"h"}, modulePaths(dependents[c]))
+	assert.Empty(t, dependents[d])
+	assert.Contains(t, dependents, d)
+	assert.Empty(t, dependents[h])
+
+	// consistent with the dependencies
+	for _, module := range modules {
+		for _, dependency := range module.Dependencies {
+			assert.Contains(t, dependents[dependency], module)
+		}
 	}
+}
 
-	opts, err := options.NewTerragruntOptionsForTest("")
+func TestStronglyConnectedComponents(t *testing.T) {
+	t.Parallel()
+
+	// a -> b -> a, and b -> c -> d -> b: a, b, c and d are all entangled
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a"}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b"}
+	c := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c"}
+	d := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d"}
+	a.Dependencies = configstack.TerraformModules{b}
+	b.Dependencies = configstack.TerraformModules{a, c}
+	c.Dependencies = configstack.TerraformModules{d}
+	d.Dependencies = configstack.TerraformModules{b}
+
+	// e -> a is not part of a cycle, f -> f is
+	e := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "e", Dependencies: configstack.TerraformModules{a}}
+	f := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "f"}
+	f.Dependencies = configstack.TerraformModules{f}
+
+	g := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "g"}
+
+	modules := configstack.TerraformModules{e, f, g, a, b, c, d}
+
+	components := modules.StronglyConnectedComponents()
+	require.Len(t, components, 2)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, modulePaths(components[0]))
+	assert.Equal(t, []string{"f"}, modulePaths(components[1]))
+
+	assert.Empty(t, configstack.TerraformModules{g}.StronglyConnectedComponents())
+
+	// the dependencies that are not part of the list are followed
+	components = configstack.TerraformModules{e}.StronglyConnectedComponents()
+	require.Len(t, components, 1)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, modulePaths(components[0]))
+
+	// the cycle found is only part of the components, which are reported whole
+	err := modules.CheckForCycles()
+
+	var entangledErr configstack.EntangledModulesError
+	require.ErrorAs(t, err, &entangledErr)
+	assert.Equal(t, [][]string{{"a", "b", "c", "d"}, {"f"}}, entangledErr.Components)
+
+	var cycleErr configstack.DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
+	assert.Contains(t, err.Error(), "Modules entangled in dependency cycles:\n  - a, b, c, d\n  - f")
+}
+
+func TestDependencyCycleErrorFormatting(t *testing.T) {
+	t.Parallel()
+
+	err := configstack.DependencyCycleError([]string{"l", "m", "n", "o", "l"})
+	assert.Equal(t, "Found a dependency cycle between modules: [l] -> m -> n -> o -> [l]", err.Error())
+
+	err = configstack.DependencyCycleError([]string{"/infrastructure/live/prod/networking/vpc", "/infrastructure/live/prod/data/database", "/infrastructure/live/prod/networking/vpc"})
+	assert.Equal(t, `Found a dependency cycle between modules:
+  [/infrastructure/live/prod/networking/vpc]
+  -> /infrastructure/live/prod/data/database
+  -> [/infrastructure/live/prod/networking/vpc]`, err.Error())
+}
+
+func TestStableTopoSort(t *testing.T) {
+	t.Parallel()
+
+	z := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "z"}
+	y := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "y"}
+	x := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "x", Dependencies: configstack.TerraformModules{{Stack: &configstack.Stack{}, Path: "external/w"}}}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b"}
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", Dependencies: configstack.TerraformModules{b, y}}
+	c := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c"}
+
+	// x depends on a module that is not part of the list, which is ignored
+	sorted, err := configstack.TerraformModules{z, a, y, x, c, b}.StableTopoSort()
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
-	assert.False(t, aRan)
+	// the independent roots keep their input order, and a comes right after its dependencies y and b
+	assert.Equal(t, []string{"z", "y", "x", "c", "b", "a"}, modulePaths(sorted))
+
+	sorted, err = configstack.TerraformModules{c, b, z, y, a, x}.StableTopoSort()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "z", "y", "a", "x"}, modulePaths(sorted))
+
+	j := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "j"}
+	k := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "k", Dependencies: configstack.TerraformModules{j}}
+	j.Dependencies = configstack.TerraformModules{k}
+
+	_, err = configstack.TerraformModules{z, j, k}.StableTopoSort()
+
+	var cycleErr configstack.DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
 }
 
-func TestRunModulesReverseOrderOneModuleSuccess(t *testing.T) {
+func TestSerialOrder(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	modules := createGraphTestModules()
+	slices.Reverse(modules)
+
+	order, err := modules.SerialOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g", "h"}, modulePaths(order))
+
+	position := map[string]int{}
+	for i, module := range order {
+		position[module.Path] = i
 	}
 
-	opts, err := options.NewTerragruntOptionsForTest("")
+	for _, module := range order {
+		for _, dependency := range module.Dependencies {
+			assert.Less(t, position[dependency.Path], position[module.Path], "%s must come after its dependency %s", module.Path, dependency.Path)
+		}
+	}
+
+	j := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "j"}
+	k := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "k", Dependencies: configstack.TerraformModules{j}}
+	j.Dependencies = configstack.TerraformModules{k}
+
+	_, err = configstack.TerraformModules{j, k}.SerialOrder()
+
+	var cycleErr configstack.DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
+}
+
+func TestReversed(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+	reversed := modules.Reversed()
+
+	assert.Equal(t, map[string][]string{
+		"a": {"e", "f"},
+		"b": {"f"},
+		"c": {"h"},
+		"d": {},
+		"e": {"g"},
+		"f": {"h"},
+		"g": {"h"},
+		"h": {},
+	}, dependencyEdges(reversed))
+
+	assert.Equal(t, dependencyEdges(modules), dependencyEdges(reversed.Reversed()))
+
+	// the original modules must not be modified
+	assert.Equal(t, []string{"a"}, dependencyEdges(modules)["e"])
+
+	// the modules that run last get a phase of their own ahead of all the others
+	network := &configstack.TerraformModule{Path: "network", Phase: 1}
+	validation := &configstack.TerraformModule{Path: "validation", RunLast: true, Dependencies: configstack.TerraformModules{network}}
+
+	reversed = configstack.TerraformModules{network, validation}.Reversed()
+	assert.Equal(t, -1, reversed[0].Phase)
+	assert.False(t, reversed[1].RunLast)
+	assert.Equal(t, -2, reversed[1].Phase)
+}
+
+func TestSubgraph(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+
+	subgraph, err := modules.Subgraph("f", "g")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA}
-	err = modules.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
-	assert.True(t, aRan)
+	assert.Equal(t, map[string][]string{
+		"a": {},
+		"b": {},
+		"e": {"a"},
+		"f": {"a", "b"},
+		"g": {"e"},
+	}, dependencyEdges(subgraph))
 }
 
-func TestRunModulesIgnoreOrderOneModuleSuccess(t *testing.T) {
+func TestBlastRadius(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
-	}
+	modules := createGraphTestModules()
 
-	opts, err := options.NewTerragruntOptionsForTest("")
+	blastRadius, err := modules.BlastRadius("a")
 	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "e", "f", "g", "h"}, modulePaths(blastRadius))
 
-	modules := configstack.TerraformModules{moduleA}
-	err = modules.RunModulesIgnoreOrder(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
-	assert.True(t, aRan)
+	blastRadius, err = modules.BlastRadius("h")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h"}, modulePaths(blastRadius))
+
+	_, err = modules.BlastRadius("z")
+
+	var unrecognizedErr configstack.UnrecognizedModuleError
+	require.ErrorAs(t, err, &unrecognizedErr)
 }
 
-func TestRunModulesOneModuleError(t *testing.T) {
+func TestUnreachableFrom(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	expectedErrA := errors.New("Expected error for module a")
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	modules := createGraphTestModules()
+
+	unreachable := modules.UnreachableFrom([]string{"g"})
+	assert.Equal(t, []string{"b", "c", "d", "f", "h"}, modulePaths(unreachable))
+
+	assert.Empty(t, modules.UnreachableFrom([]string{"h", "d"}))
+	assert.Len(t, modules.UnreachableFrom(nil), len(modules))
+}
+
+func TestWouldCreateCycle(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+	before := dependencyEdges(modules)
+
+	for _, edge := range []struct {
+		from, to string
+		cycle    bool
+	}{
+		{from: "a", to: "b"},
+		{from: "a", to: "d"},
+		{from: "h", to: "a"},
+		{from: "g", to: "f"},
+		// e depends on a
+		{from: "a", to: "e", cycle: true},
+		// h depends on a through g and e
+		{from: "a", to: "h", cycle: true},
+		{from: "b", to: "h", cycle: true},
+		{from: "d", to: "d", cycle: true},
+	} {
+		cycle, err := modules.WouldCreateCycle(edge.from, edge.to)
+		require.NoError(t, err)
+		assert.Equal(t, edge.cycle, cycle, "%s -> %s", edge.from, edge.to)
 	}
 
+	// the modules are left untouched
+	assert.Equal(t, before, dependencyEdges(modules))
+
+	var unrecognizedErr configstack.UnrecognizedModuleError
+
+	_, err := modules.WouldCreateCycle("a", "z")
+	require.ErrorAs(t, err, &unrecognizedErr)
+	assert.Equal(t, configstack.UnrecognizedModuleError("z"), unrecognizedErr)
+
+	_, err = modules.WouldCreateCycle("z", "a")
+	require.ErrorAs(t, err, &unrecognizedErr)
+	assert.Equal(t, configstack.UnrecognizedModuleError("z"), unrecognizedErr)
+}
+
+func TestValidateRunnable(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+	require.NoError(t, modules.ValidateRunnable())
+
+	// f is excluded, but h still depends on it
+	modules[5].FlagExcluded = true
+
+	err := modules.ValidateRunnable()
+
+	var excludedErr configstack.ExcludedDependencyError
+	require.ErrorAs(t, err, &excludedErr)
+	assert.Equal(t, "h", excludedErr.Module.Path)
+	assert.Equal(t, "f", excludedErr.Dependency.Path)
+
+	// excluding h as well leaves nothing dangling, as the dependencies of excluded modules don't matter
+	modules[7].FlagExcluded = true
+	require.NoError(t, modules.ValidateRunnable())
+
+	// a dependency that is not part of the list at all, and a cycle among the runnable modules
+	outside := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "z"}
+	modules[3].Dependencies = configstack.TerraformModules{outside}
+	modules[0].Dependencies = configstack.TerraformModules{modules[6]}
+
+	err = modules.ValidateRunnable()
+
+	var (
+		missingErr configstack.MissingDependencyError
+		cycleErr   configstack.DependencyCycleError
+	)
+
+	require.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, "d", missingErr.Module.Path)
+	assert.Equal(t, "z", missingErr.Dependency.Path)
+	require.ErrorAs(t, err, &cycleErr)
+}
+
+func TestRunPlanFor(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+
+	levels, err := modules.RunPlanFor("g")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a"}, {"e"}, {"g"}}, levelPaths(levels))
+
+	levels, err = modules.RunPlanFor("h")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"e", "f"}, {"g"}, {"h"}}, levelPaths(levels))
+}
+
+func TestRunPlanForUnknownTarget(t *testing.T) {
+	t.Parallel()
+
+	_, err := createGraphTestModules().RunPlanFor("unknown")
+
+	var unrecognizedErr configstack.UnrecognizedModuleError
+	require.ErrorAs(t, err, &unrecognizedErr)
+}
+
+func TestRunPlanForCycle(t *testing.T) {
+	t.Parallel()
+
+	a := &configstack.TerraformModule{Path: "a"}
+	b := &configstack.TerraformModule{Path: "b", Dependencies: configstack.TerraformModules{a}}
+	a.Dependencies = configstack.TerraformModules{b}
+
+	_, err := configstack.TerraformModules{a, b}.RunPlanFor("a")
+
+	var cycleErr configstack.DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
+}
+
+func TestRunModulesNoModules(t *testing.T) {
+	t.Parallel()
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA}
+	modules := configstack.TerraformModules{}
 	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	assertMultiErrorContains(t, err, expectedErrA)
-	assert.True(t, aRan)
+	require.NoError(t, err, "Unexpected error: %v", err)
 }
 
-func TestRunModulesReverseOrderOneModuleError(t *testing.T) {
+func TestRunModulesOneModuleSuccess(t *testing.T) {
 	t.Parallel()
 
 	aRan := false
-	expectedErrA := errors.New("Expected error for module a")
 	moduleA := &configstack.TerraformModule{
 		Stack:             &configstack.Stack{},
 		Path:              "a",
 		Dependencies:      configstack.TerraformModules{},
 		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
 	}
 
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
 	modules := configstack.TerraformModules{moduleA}
-	err = modules.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
-	assertMultiErrorContains(t, err, expectedErrA)
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
 	assert.True(t, aRan)
 }
 
-func TestRunModulesIgnoreOrderOneModuleError(t *testing.T) {
+func TestRunModulesOneModuleTimeout(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	expectedErrA := errors.New("Expected error for module a")
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	clock := newFakeClock()
+
+	moduleOpts, err := options.NewTerragruntOptionsForTest("a")
+	require.NoError(t, err)
+
+	moduleOpts.Clock = clock
+	moduleOpts.ModuleTimeout = time.Hour
+	moduleOpts.RunTerragrunt = func(ctx context.Context, _ *options.TerragruntOptions) error {
+		<-ctx.Done()
+		return ctx.Err()
 	}
 
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: moduleOpts}
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA}
-	err = modules.RunModulesIgnoreOrder(context.Background(), opts, options.DefaultParallelism)
-	assertMultiErrorContains(t, err, expectedErrA)
-	assert.True(t, aRan)
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- configstack.TerraformModules{moduleA}.RunModules(context.Background(), opts, options.DefaultParallelism)
+	}()
+
+	<-clock.timerCreated
+	clock.Advance(time.Minute)
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("Module finished before its timeout: %v", err)
+	default:
+	}
+
+	clock.Advance(time.Hour)
+
+	err = <-errCh
+
+	var timeoutErr configstack.ModuleTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, "a", timeoutErr.Module.Path)
+	assert.Equal(t, time.Hour, timeoutErr.Timeout)
 }
 
-func TestRunModulesMultipleModulesNoDependenciesSuccess(t *testing.T) {
+func TestRunModulesModuleTimeoutWaitsForExit(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
+	run := func(t *testing.T, exited chan struct{}) (*fakeClock, *executionLog, chan error) {
+		t.Helper()
+
+		clock := newFakeClock()
+		log := &executionLog{}
+
+		// a ignores the cancellation until exited is closed
+		optsA, err := options.NewTerragruntOptionsForTest("a")
+		require.NoError(t, err)
+
+		optsA.Clock = clock
+		optsA.ModuleTimeout = time.Hour
+		optsA.RunTerragrunt = func(ctx context.Context, _ *options.TerragruntOptions) error {
+			<-ctx.Done()
+			<-exited
+
+			return ctx.Err()
+		}
+
+		moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optsA}
+		// b runs once a is done, despite its timeout
+		optsB := optionsWithMockTerragruntCommandLog(t, "b", nil, log)
+		optsB.IgnoreDependencyErrors = true
+
+		moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optsB}
+
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			errCh <- configstack.TerraformModules{moduleA, moduleB}.RunModules(context.Background(), opts, options.DefaultParallelism)
+		}()
+
+		<-clock.timerCreated
+		clock.Advance(time.Hour)
+
+		// the grace period starts
+		<-clock.timerCreated
+
+		return clock, log, errCh
+	}
+
+	t.Run("exits", func(t *testing.T) {
+		t.Parallel()
+
+		exited := make(chan struct{})
+		_, log, errCh := run(t, exited)
+
+		select {
+		case err := <-errCh:
+			t.Fatalf("Run finished before the module that timed out exited: %v", err)
+		default:
+		}
+
+		assert.Empty(t, log.paths)
+
+		close(exited)
+
+		var timeoutErr configstack.ModuleTimeoutError
+		require.ErrorAs(t, <-errCh, &timeoutErr)
+		assert.Equal(t, []string{"b"}, log.paths)
+	})
+
+	t.Run("does not exit", func(t *testing.T) {
+		t.Parallel()
+
+		exited := make(chan struct{})
+		defer close(exited)
+
+		clock, log, errCh := run(t, exited)
+		clock.Advance(time.Minute)
+
+		var timeoutErr configstack.ModuleTimeoutError
+		require.ErrorAs(t, <-errCh, &timeoutErr)
+		assert.Equal(t, []string{"b"}, log.paths)
+	})
+}
+
+func TestRunModulesRampUp(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+
+	var (
+		running    atomic.Int32
+		started    = make(chan struct{}, 8)
+		release    = make(chan struct{})
+		modules    configstack.TerraformModules
+		rampUpTime = 10 * time.Minute
+	)
+
+	for i := range 8 {
+		path := fmt.Sprintf("module-%d", i)
+
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			running.Add(1)
+			defer running.Add(-1)
+
+			started <- struct{}{}
+			<-release
+
+			return nil
+		}
+
+		modules = append(modules, &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, TerragruntOptions: opts})
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.Clock = clock
+	opts.RampUp = rampUpTime
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- modules.RunModules(context.Background(), opts, 4)
+	}()
+
+	// at the start of the run, a single module runs while the scheduler waits for the concurrency to ramp up
+	<-started
+	<-clock.timerCreated
+	assert.Equal(t, int32(1), running.Load())
+
+	// halfway through the window, 1 + 3/2 modules may run
+	clock.Advance(rampUpTime / 2)
+	<-started
+	<-clock.timerCreated
+	assert.Equal(t, int32(2), running.Load())
+
+	// once the window has passed, the full parallelism applies
+	clock.Advance(rampUpTime / 2)
+	<-started
+	<-started
+	assert.Equal(t, int32(4), running.Load())
+
+	close(release)
+	require.NoError(t, <-errCh)
+}
+
+func TestRunModulesPauseResume(t *testing.T) {
+	t.Parallel()
+
+	control := options.NewRunControl()
+
+	var (
+		executed []string
+		mu       sync.Mutex
+	)
+
+	newModule := func(path string, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			executed = append(executed, path)
+
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	moduleA := newModule("a")
+	moduleB := newModule("b")
+	moduleC := newModule("c", moduleA, moduleB)
+	moduleD := newModule("d", moduleC)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	firstBatchDone := make(chan struct{})
+	finished := 0
+
+	opts.RunControl = control
+	opts.RunEventHandler = func(event options.RunEvent) {
+		// pause once the first batch is dispatched
+		if event.Type == options.RunEventModuleStarted && event.Path == "b" {
+			control.Pause()
+		}
+
+		if event.Type == options.RunEventModuleFinished {
+			finished++
+			if finished == 2 {
+				close(firstBatchDone)
+			}
+		}
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}.RunModules(context.Background(), opts, options.DefaultParallelism)
+	}()
+
+	<-firstBatchDone
+	assert.True(t, control.Paused())
+
+	ran := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return slices.Clone(executed)
+	}
+
+	assert.Never(t, func() bool { return len(ran()) > 2 }, 100*time.Millisecond, 10*time.Millisecond, "no module should start while paused")
+	assert.ElementsMatch(t, []string{"a", "b"}, ran())
+
+	control.Resume()
+
+	require.NoError(t, <-errCh)
+	assert.Equal(t, []string{"c", "d"}, ran()[2:])
+}
+
+func TestRunModulesPropagateChangesToDependents(t *testing.T) {
+	t.Parallel()
+
+	var (
+		outputValue  = "v1"
+		appliedValue = "v1"
+		bRuns        = 0
+	)
+
+	optsA, err := options.NewTerragruntOptionsForTest("a")
+	require.NoError(t, err)
+
+	optsA.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+		if opts.TerraformCommand == terraform.CommandNameOutput {
+			_, err := fmt.Fprintf(opts.Writer, `{"id": {"sensitive": false, "type": "string", "value": %q}}`, outputValue)
+			return err
+		}
+
+		outputValue = appliedValue
+
+		return nil
+	}
+
+	optsB, err := options.NewTerragruntOptionsForTest("b")
+	require.NoError(t, err)
+
+	optsB.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+		bRuns++
+		return nil
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optsA}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optsB, AssumeAlreadyApplied: true}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.PropagateChangesToDependents = true
+
+	// a run that leaves the outputs of a unchanged leaves b assumed applied
+	require.NoError(t, configstack.TerraformModules{moduleA, moduleB}.RunModules(context.Background(), opts, options.DefaultParallelism))
+	assert.NotEmpty(t, moduleA.OutputHash)
+	assert.Equal(t, 0, bRuns)
+
+	// a run that changes them makes b run, the outputs being read before a runs, without any hash from a previous run
+	moduleA.OutputHash = ""
+	appliedValue = "v2"
+
+	require.NoError(t, configstack.TerraformModules{moduleA, moduleB}.RunModules(context.Background(), opts, options.DefaultParallelism))
+	assert.Equal(t, 1, bRuns)
+	assert.False(t, moduleB.AssumeAlreadyApplied)
+}
+
+func TestCheckForIncludeCycles(t *testing.T) {
+	t.Parallel()
+
+	newModule := func(path string, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(path, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	a := newModule("/stack/a")
+	b := newModule("/stack/b", a)
+	c := newModule("/stack/c", b)
+
+	// no cycle without includes
+	require.NoError(t, configstack.TerraformModules{a, b, c}.CheckForIncludeCycles())
+
+	// a includes the config of c, which depends on a through b
+	a.Config.ProcessedIncludes = config.IncludeConfigsMap{"c": {Name: "c", Path: "/stack/c/terragrunt.hcl"}}
+
+	err := configstack.TerraformModules{a, b, c}.CheckForIncludeCycles()
+
+	var cycleErr configstack.IncludeCycleError
+	require.ErrorAs(t, err, &cycleErr)
+	assert.Equal(t, configstack.IncludeCycleError{
+		Cycle:   configstack.DependencyCycleError{"/stack/a", "/stack/c", "/stack/b", "/stack/a"},
+		Module:  "/stack/a",
+		Include: "/stack/c/terragrunt.hcl",
+	}, cycleErr)
+	assert.ErrorAs(t, err, new(configstack.DependencyCycleError))
+
+	// the cycles of explicit dependencies only are left to CheckForCycles, as are those whose dependencies can't be
+	// attributed to an include that can't be read
+	a.Config.ProcessedIncludes = config.IncludeConfigsMap{"root": {Name: "root", Path: "/stack/does-not-exist.hcl"}}
+	a.Dependencies = configstack.TerraformModules{c}
+
+	require.NoError(t, configstack.TerraformModules{a, b, c}.CheckForIncludeCycles())
+	require.Error(t, configstack.TerraformModules{a, b, c}.CheckForCycles())
+}
+
+func TestRunModulesDependencyOutputsAsEnv(t *testing.T) {
+	t.Parallel()
+
+	newDependency := func(path string) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			if opts.TerraformCommand == terraform.CommandNameOutput {
+				_, err := fmt.Fprint(opts.Writer, `{"id": {"type": "string", "value": "vpc-123"}, "cidr-blocks": {"type": ["list", "string"], "value": ["10.0.0.0/16"]}}`)
+				return err
+			}
+
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, TerragruntOptions: opts}
+	}
+
+	var env map[string]string
+
+	newDependent := func(dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest("app")
+		require.NoError(t, err)
+
+		opts.Env = map[string]string{"TF_VAR_vpc_main_region": "us-east-1"}
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			env = opts.Env
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.DependencyOutputsAsEnv = true
+
+	vpc := newDependency("live/vpc-main")
+	app := newDependent(vpc)
+
+	require.NoError(t, configstack.TerraformModules{vpc, app}.RunModules(context.Background(), opts, options.DefaultParallelism))
+	assert.Equal(t, map[string]string{
+		"TF_VAR_vpc_main_region":      "us-east-1",
+		"TF_VAR_vpc_main_id":          "vpc-123",
+		"TF_VAR_vpc_main_cidr_blocks": `["10.0.0.0/16"]`,
+	}, env)
+
+	// the options of the module are left as is
+	assert.Equal(t, map[string]string{"TF_VAR_vpc_main_region": "us-east-1"}, app.TerragruntOptions.Env)
+
+	// dependencies in directories of the same name pass their outputs as the same env vars
+	east := newDependency("us-east-1/vpc-main")
+	west := newDependency("us-west-2/vpc-main")
+	app = newDependent(east, west)
+
+	err = configstack.TerraformModules{east, west, app}.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var collisionErr configstack.DependencyOutputEnvCollisionError
+	require.ErrorAs(t, err, &collisionErr)
+	assert.Equal(t, "TF_VAR_vpc_main_cidr_blocks", collisionErr.Name)
+	assert.Equal(t, []string{"us-east-1/vpc-main", "us-west-2/vpc-main"}, collisionErr.Dependencies)
+}
+
+func TestRunModulesFenceByBackend(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	newModule := func(path, bucket string) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			if path == "a" {
+				<-release
+			}
+
+			return nil
+		}
+
+		remoteState := &remote.RemoteState{Backend: "s3", Config: map[string]interface{}{"bucket": bucket, "key": path + "/terraform.tfstate"}}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Config: config.TerragruntConfig{RemoteState: remoteState}, TerragruntOptions: opts}
+	}
+
+	moduleA := newModule("a", "shared-state")
+	moduleB := newModule("b", "shared-state")
+	moduleC := newModule("c", "other-state")
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	var (
+		events []string
+		mu     sync.Mutex
+		cDone  = make(chan struct{})
+	)
+
+	opts.FenceByBackend = true
+	opts.RunEventHandler = func(event options.RunEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, string(event.Type)+" "+event.Path)
+
+		if event.Type == options.RunEventModuleFinished && event.Path == "c" {
+			close(cDone)
+		}
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 3)
+	}()
+
+	// c doesn't share the backend of a and runs alongside it, while b waits for a despite the free parallelism slot
+	<-cDone
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"module_started a", "module_started c", "module_finished c"}, events)
+	mu.Unlock()
+
+	close(release)
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, []string{"module_finished a", "module_started b", "module_finished b"}, events[3:])
+}
+
+func TestRunModulesPauseBetweenLevels(t *testing.T) {
+	t.Parallel()
+
+	run := func(continueTo int) ([]int, map[int][]string, error) {
+		var (
+			mu        sync.Mutex
+			ran       []string
+			levels    []int
+			ranBefore = map[int][]string{}
+		)
+
+		modules := createGraphTestModules()
+		for _, module := range modules {
+			path := module.Path
+
+			opts, err := options.NewTerragruntOptionsForTest(path)
+			require.NoError(t, err)
+
+			opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				ran = append(ran, path)
+
+				return nil
+			}
+
+			module.TerragruntOptions = opts
+		}
+
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		opts.PauseBetweenLevels = true
+		opts.ContinueToLevel = func(_ context.Context, level int) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			levels = append(levels, level)
+			ranBefore[level] = slices.Sorted(slices.Values(ran))
+
+			return level <= continueTo, nil
+		}
+
+		err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+		return levels, ranBefore, err
+	}
+
+	// a, b, c and d are in level 0, e and f in level 1, g in level 2 and h in level 3
+	levels, ranBefore, err := run(3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, levels)
+	assert.Equal(t, map[int][]string{
+		1: {"a", "b", "c", "d"},
+		2: {"a", "b", "c", "d", "e", "f"},
+		3: {"a", "b", "c", "d", "e", "f", "g"},
+	}, ranBefore)
+
+	// the modules of the levels the run is not continued to are skipped
+	levels, ranBefore, err = run(1)
+	require.Error(t, err)
+	assert.Equal(t, []int{1, 2}, levels)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, ranBefore[2])
+
+	var notContinuedErr configstack.LevelNotContinuedError
+	require.ErrorAs(t, err, &notContinuedErr)
+	assert.Equal(t, 2, notContinuedErr.Level)
+}
+
+func TestRunModulesAdaptiveConcurrency(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	newModule := func(path string, err error, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, optsErr := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, optsErr)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			if path == "gate" {
+				<-release
+			}
+
+			return err
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	// a burst of failures while the gate runs, then modules that succeed once the gate is done
+	gate := newModule("gate", nil)
+	modules := configstack.TerraformModules{gate}
+
+	for i := range 5 {
+		modules = append(modules, newModule(fmt.Sprintf("fail-%d", i), errors.New("systemic failure")))
+	}
+
+	for i := range 8 {
+		modules = append(modules, newModule(fmt.Sprintf("succeed-%d", i), nil, gate))
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	var (
+		limits  []string
+		failed  int
+		running int
+		// number of modules running when each module that succeeds started, itself included, in the order in which
+		// they started
+		runningAtStart []int
+	)
+
+	opts.AdaptiveConcurrency = true
+	opts.RunEventHandler = func(event options.RunEvent) {
+		switch event.Type {
+		case options.RunEventConcurrencyChanged:
+			limits = append(limits, event.Message)
+		case options.RunEventModuleStarted:
+			running++
+
+			if strings.HasPrefix(event.Path, "succeed-") {
+				runningAtStart = append(runningAtStart, running)
+			}
+		case options.RunEventModuleFinished:
+			running--
+
+			if strings.HasPrefix(event.Path, "fail-") {
+				if failed++; failed == 5 {
+					close(release)
+				}
+			}
+		}
+	}
+
+	// all the failing modules and the gate start at once
+	err = modules.RunModules(context.Background(), opts, 6)
+	require.Error(t, err)
+
+	// halved twice by the failures, then one more per success once they are no more than half of the last 10
+	assert.Equal(t, []string{"3", "1", "2", "3", "4", "5", "6"}, limits)
+
+	// the first modules after the burst run one at a time
+	assert.Equal(t, []int{1, 1, 1, 1}, runningAtStart[:4])
+}
+
+func TestRunModulesParallelismPerAccount(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	var (
+		mu      sync.Mutex
+		running = map[string]int{}
+		peak    = map[string]int{}
+		started int
+	)
+
+	newModule := func(path, account string, labels map[string]string, env map[string]string) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.Env = env
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			mu.Lock()
+			running[account]++
+			peak[account] = max(peak[account], running[account])
+			started++
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			running[account]--
+			mu.Unlock()
+
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Labels: labels, TerragruntOptions: opts}
+	}
+
+	prod := map[string]string{"account_id": "111111111111"}
+	dev := map[string]string{"AWS_PROFILE": "dev"}
+
+	modules := configstack.TerraformModules{
+		newModule("prod-a", "prod", prod, nil),
+		newModule("prod-b", "prod", prod, nil),
+		newModule("prod-c", "prod", prod, nil),
+		newModule("dev-a", "dev", nil, dev),
+		newModule("dev-b", "dev", nil, dev),
+		newModule("dev-c", "dev", nil, dev),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.ParallelismPerAccount = 2
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- modules.RunModules(context.Background(), opts, 10)
+	}()
+
+	// each account runs as many modules as its own cap allows, regardless of the other account
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return started == 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, map[string]int{"prod": 2, "dev": 2}, running)
+	mu.Unlock()
+
+	close(release)
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, 6, started)
+	assert.Equal(t, map[string]int{"prod": 2, "dev": 2}, peak)
+}
+
+func TestRunModulesResourceCapacities(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	newModule := func(path string, resourceTags map[string]int) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			if path == "a" {
+				<-release
+			}
+
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, ResourceTags: resourceTags, TerragruntOptions: opts}
+	}
+
+	moduleA := newModule("a", map[string]int{"db": 2, "api": 1})
+	moduleB := newModule("b", map[string]int{"db": 2})
+	moduleC := newModule("c", map[string]int{"api": 1, "unlimited": 100})
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	var (
+		events []string
+		mu     sync.Mutex
+		cDone  = make(chan struct{})
+	)
+
+	opts.ResourceCapacities = map[string]int{"db": 2, "api": 2}
+	opts.RunEventHandler = func(event options.RunEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, string(event.Type)+" "+event.Path)
+
+		if event.Type == options.RunEventModuleFinished && event.Path == "c" {
+			close(cDone)
+		}
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 3)
+	}()
+
+	// c fits alongside a, while b waits for a to release the whole db capacity despite the free parallelism slot
+	<-cDone
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"module_started a", "module_started c", "module_finished c"}, events)
+	mu.Unlock()
+
+	close(release)
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, []string{"module_finished a", "module_started b", "module_finished b"}, events[3:])
+
+	// a module using more of a resource than its capacity could never run
+	opts.ResourceCapacities = map[string]int{"db": 1}
+
+	err = configstack.TerraformModules{newModule("d", nil), newModule("e", map[string]int{"db": 2})}.RunModules(context.Background(), opts, 3)
+
+	var capacityErr configstack.ResourceCapacityExceededError
+	require.ErrorAs(t, err, &capacityErr)
+	assert.Equal(t, "e", capacityErr.Module.Path)
+	assert.Equal(t, "db", capacityErr.Tag)
+}
+
+func TestRunModulesSeeded(t *testing.T) {
+	t.Parallel()
+
+	run := func(seed int64) []string {
+		clock := newFakeClock()
+
+		// six roots, five modules depending on two neighbouring roots each, and a module depending on all of those
+		modules := configstack.TerraformModules{}
+		roots := configstack.TerraformModules{}
+		mids := configstack.TerraformModules{}
+
+		newModule := func(path string, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+			module := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: optionsWithMockTerragruntCommand(t, path, nil, new(bool))}
+			modules = append(modules, module)
+
+			return module
+		}
+
+		for i := range 6 {
+			roots = append(roots, newModule(fmt.Sprintf("root-%d", i)))
+		}
+
+		for i := range 5 {
+			mids = append(mids, newModule(fmt.Sprintf("mid-%d", i), roots[i], roots[i+1]))
+		}
+
+		newModule("top", mids...)
+
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		var events []string
+
+		opts.Clock = clock
+		opts.RunSeed = seed
+		opts.RunEventHandler = func(event options.RunEvent) {
+			events = append(events, fmt.Sprintf("%s %s %s", event.Time.Format(time.TimeOnly), event.Type, event.Path))
+
+			// the events are emitted by the dispatcher, so the time advances deterministically
+			clock.Advance(time.Second)
+		}
+
+		require.NoError(t, modules.RunModules(context.Background(), opts, 3))
+
+		return events
+	}
+
+	first := run(42)
+
+	assert.Len(t, first, 24)
+
+	for range 5 {
+		assert.Equal(t, first, run(42))
+	}
+}
+
+func TestRunModulesUnreachableSources(t *testing.T) {
+	t.Parallel()
+
+	const unreachableSource = "git::https://git.example.com/unreachable-modules.git//vpc?ref=v1.0.0"
+
+	newModules := func() (configstack.TerraformModules, map[string]*bool) {
+		ran := map[string]*bool{"a": new(bool), "b": new(bool), "c": new(bool), "d": new(bool)}
+
+		newModule := func(path, source string, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+			opts := optionsWithMockTerragruntCommand(t, path, nil, ran[path])
+			opts.Source = source
+
+			return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+		}
+
+		moduleA := newModule("a", unreachableSource)
+		moduleB := newModule("b", "", moduleA)
+		moduleC := newModule("c", "git::https://git.example.com/modules.git//app?ref=v1.0.0", moduleB)
+		moduleD := newModule("d", "git::https://git.example.com/modules.git//dns?ref=v1.0.0")
+
+		return configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}, ran
+	}
+
+	var checked []string
+
+	checker := func(_ context.Context, source string) error {
+		checked = append(checked, source)
+
+		if source == unreachableSource {
+			return errors.New("connection refused")
+		}
+
+		return nil
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.SourceChecker = checker
+	opts.PreflightSources = true
+
+	// by default, the run fails before anything runs
+	modules, ran := newModules()
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var sourceErr configstack.UnreachableSourceError
+	require.ErrorAs(t, err, &sourceErr)
+	assert.Equal(t, "a", sourceErr.ModulePath)
+	assert.Equal(t, unreachableSource, sourceErr.Source)
+
+	for path, moduleRan := range ran {
+		assert.False(t, *moduleRan, "module %s should not run", path)
+	}
+
+	// with SkipUnreachableSources, the module with the unreachable source and its dependents are skipped
+	checked = nil
+	opts.SkipUnreachableSources = true
+
+	modules, ran = newModules()
+	require.NoError(t, modules.RunModules(context.Background(), opts, options.DefaultParallelism))
+
+	assert.False(t, *ran["a"])
+	assert.False(t, *ran["b"])
+	assert.False(t, *ran["c"])
+	assert.True(t, *ran["d"])
+	assert.ElementsMatch(t, []string{unreachableSource, "git::https://git.example.com/modules.git//app?ref=v1.0.0", "git::https://git.example.com/modules.git//dns?ref=v1.0.0"}, checked)
+}
+
+func TestRunModulesRetryOverrides(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls = map[string]int{}
+		mu    sync.Mutex
+	)
+
+	// terraform fails with a flaky error twice in every module, then succeeds
+	ctx := shell.ContextWithTerraformCommandHook(context.Background(), func(_ context.Context, opts *options.TerragruntOptions, _ cli.Args) (*util.CmdOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls[opts.TerragruntConfigPath]++
+		if calls[opts.TerragruntConfigPath] <= 2 {
+			out := &util.CmdOutput{}
+			out.Stderr.WriteString("Error: flaky API call")
+
+			return out, errors.New("terraform failed")
+		}
+
+		return &util.CmdOutput{}, nil
+	})
+
+	newModule := func(path string) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(ctx context.Context, opts *options.TerragruntOptions) error {
+			return terraformcmd.RunTerraformWithRetry(ctx, opts)
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, TerragruntOptions: opts}
+	}
+
+	moduleFlaky := newModule("flaky")
+	moduleFlaky.RetryAttempts = 3
+	moduleFlaky.RetrySleep = time.Millisecond
+	moduleFlaky.RetryableErrors = []string{"(?s).*flaky API call.*"}
+
+	moduleStable := newModule("stable")
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{moduleFlaky, moduleStable}.RunModules(ctx, opts, options.DefaultParallelism)
+	require.ErrorContains(t, err, "terraform failed")
+
+	assert.Equal(t, map[string]int{"flaky": 3, "stable": 1}, calls)
+}
+
+func TestRunModulesTerraformParallelism(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		args = map[string][]string{}
+	)
+
+	ctx := shell.ContextWithTerraformCommandHook(context.Background(), func(_ context.Context, opts *options.TerragruntOptions, cmdArgs cli.Args) (*util.CmdOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		args[opts.TerragruntConfigPath] = cmdArgs
+
+		return &util.CmdOutput{}, nil
+	})
+
+	newModule := func(path string, terraformParallelism int, terraformArgs ...string) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.TerraformCommand = terraformArgs[0]
+		opts.TerraformCliArgs = terraformArgs
+		opts.RunTerragrunt = func(ctx context.Context, opts *options.TerragruntOptions) error {
+			return terraformcmd.RunTerraformWithRetry(ctx, opts)
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, TerraformParallelism: terraformParallelism, TerragruntOptions: opts}
+	}
+
+	heavy := newModule("heavy", 2, "apply", "-input=false", "-parallelism=10")
+	light := newModule("light", 0, "apply", "-input=false")
+	outputs := newModule("outputs", 2, "output", "-json")
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{heavy, light, outputs}.RunModules(ctx, opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	// the flag of the module replaces the one of the options, and is left out of the commands that don't accept it
+	assert.Equal(t, []string{"apply", "-parallelism=2", "-input=false"}, args["heavy"])
+	assert.Equal(t, []string{"apply", "-input=false"}, args["light"])
+	assert.Equal(t, []string{"output", "-json"}, args["outputs"])
+
+	// the options of the module are left untouched
+	assert.Equal(t, []string{"apply", "-input=false", "-parallelism=10"}, heavy.TerragruntOptions.TerraformCliArgs)
+}
+
+func TestRunModulesBeforeModuleHookSkipsModule(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	optsA := optionsWithMockTerragruntCommandLog(t, "a", nil, log)
+	optsA.BeforeModuleHook = func(_ context.Context, _ *options.TerragruntOptions) error {
+		return fmt.Errorf("feature flag disabled: %w", configstack.SkipModule)
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optsA}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"b", "c"}, log.paths)
+}
+
+func TestRunModulesAlreadyAppliedFunc(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+	errMarker := errors.New("Expected error reading the marker of module c")
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	// the dependents of an already applied module run even when the dependents of skipped modules are blocked
+	opts.RunDependentsOnSkippedDependency = false
+
+	stack := configstack.NewStack(opts, configstack.WithAlreadyAppliedFunc(func(module *configstack.TerraformModule) (bool, error) {
+		switch module.Path {
+		case "a":
+			return true, nil
+		case "c":
+			return false, errMarker
+		}
+
+		return false, nil
+	}))
+
+	moduleA := &configstack.TerraformModule{Stack: stack, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+	moduleB := &configstack.TerraformModule{Stack: stack, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: stack, Path: "c", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+
+	summary, err := configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModulesWithSummary(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, errMarker)
+
+	assert.Equal(t, []string{"b"}, log.paths)
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["a"].Status)
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["b"].Status)
+	assert.Equal(t, configstack.ModuleFailed, summary.Modules["c"].Status)
+}
+
+// skippedDependencyModules returns a stack in which a is skipped by its before-module hook and x is excluded, with b
+// depending on a, c on b, d on x and e on nothing.
+func skippedDependencyModules(t *testing.T, log *executionLog) configstack.TerraformModules {
+	t.Helper()
+
+	optsA := optionsWithMockTerragruntCommandLog(t, "a", nil, log)
+	optsA.BeforeModuleHook = func(_ context.Context, _ *options.TerragruntOptions) error {
+		return configstack.SkipModule
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optsA}
+	moduleX := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "x", FlagExcluded: true, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "x", nil, log)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+	moduleD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", Dependencies: configstack.TerraformModules{moduleX}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "d", nil, log)}
+	moduleE := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "e", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "e", nil, log)}
+
+	return configstack.TerraformModules{moduleA, moduleX, moduleB, moduleC, moduleD, moduleE}
+}
+
+func TestRunModulesSkippedDependencyRunsDependents(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+	modules := skippedDependencyModules(t, log)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+	require.True(t, opts.RunDependentsOnSkippedDependency, "enabled by default")
+
+	summary, err := modules.RunModulesWithSummary(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"b", "c", "d", "e"}, log.paths)
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["a"].Status)
+}
+
+func TestRunModulesSkippedDependencyBlocksDependents(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+	modules := skippedDependencyModules(t, log)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.RunDependentsOnSkippedDependency = false
+
+	summary, err := modules.RunModulesWithSummary(context.Background(), opts, options.DefaultParallelism)
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"e"}, log.paths)
+
+	// the skipped module itself is not an error, only its dependents are blocked
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["a"].Status)
+
+	for _, path := range []string{"b", "c", "d"} {
+		assert.Equal(t, configstack.ModuleBlocked, summary.Modules[path].Status, path)
+	}
+
+	var skippedErr configstack.SkippedDependencyError
+
+	require.ErrorAs(t, summary.Modules["b"].Err, &skippedErr)
+	assert.Equal(t, "a", skippedErr.Dependency.Path)
+
+	require.ErrorAs(t, summary.Modules["c"].Err, &skippedErr)
+	assert.Equal(t, "a", skippedErr.Dependency.Path)
+
+	require.ErrorAs(t, summary.Modules["d"].Err, &skippedErr)
+	assert.Equal(t, "x", skippedErr.Dependency.Path)
+}
+
+func TestRunModulesGroupMemberFails(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+	errA := errors.New("Expected error for module a")
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", Group: "database", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", errA, log)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Group: "database", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	// with a parallelism of 1, b is still queued when a fails
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 1)
+	assertMultiErrorContains(t, err, errA, configstack.GroupMemberFailedError{Module: moduleB, FailedMember: moduleA})
+
+	assert.Equal(t, []string{"a", "c"}, log.paths)
+}
+
+func TestRunModulesDumpEffectiveOptions(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	formatter := format.NewFormatter()
+	formatter.DisableColors = true
+
+	moduleOpts := optionsWithMockTerragruntCommand(t, "a", nil, new(bool))
+	moduleOpts.Logger = log.New(log.WithOutput(&output), log.WithLevel(log.InfoLevel), log.WithFormatter(formatter))
+	moduleOpts.ExcludeDirs = []string{"legacy"}
+	moduleOpts.Env = map[string]string{
+		"AWS_REGION":            "eu-west-1",
+		"AWS_SECRET_ACCESS_KEY": "do-not-print-me",
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: moduleOpts}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.DumpEffectiveOptions = true
+
+	err = configstack.TerraformModules{moduleA}.RunModules(context.Background(), opts, 3)
+	require.NoError(t, err)
+
+	assert.Contains(t, output.String(), "Effective options of module a")
+	assert.Contains(t, output.String(), "parallelism=3")
+	assert.Contains(t, output.String(), "exclude-dirs=[legacy]")
+	assert.Contains(t, output.String(), "AWS_REGION=eu-west-1")
+	assert.Contains(t, output.String(), "AWS_SECRET_ACCESS_KEY=<redacted>")
+	assert.NotContains(t, output.String(), "do-not-print-me")
+}
+
+func TestRunModulesPrefixIncludeLevel(t *testing.T) {
+	t.Parallel()
+
+	var (
+		output   bytes.Buffer
+		outputMu sync.Mutex
+	)
+
+	formatter := format.NewFormatter()
+	formatter.DisableColors = true
+
+	logger := log.New(log.WithOutput(&output), log.WithLevel(log.InfoLevel), log.WithFormatter(formatter))
+
+	moduleOptions := func(path string) *options.TerragruntOptions {
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join("/stack", path, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.Logger = logger
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			outputMu.Lock()
+			defer outputMu.Unlock()
+
+			opts.Logger.Infof("Running %s", path)
+
+			return nil
+		}
+
+		return opts
+	}
+
+	// g -> b -> a, f -> a
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "alpha/a", TerragruntOptions: moduleOptions("alpha/a")}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "alpha/b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: moduleOptions("alpha/b")}
+	moduleF := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "alpha/f", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: moduleOptions("alpha/f")}
+	moduleG := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "alpha/g", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: moduleOptions("alpha/g")}
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleF, moduleG}
+	assert.Equal(t, map[string]int{"alpha/a": 0, "alpha/b": 1, "alpha/f": 1, "alpha/g": 2}, modules.Levels())
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.PrefixIncludeLevel = true
+
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	assert.Contains(t, output.String(), "[L0 /stack/alpha/a] Running alpha/a")
+	assert.Contains(t, output.String(), "[L1 /stack/alpha/b] Running alpha/b")
+	assert.Contains(t, output.String(), "[L1 /stack/alpha/f] Running alpha/f")
+	assert.Contains(t, output.String(), "[L2 /stack/alpha/g] Running alpha/g")
+}
+
+func TestRunModulesInvalidParallelism(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{moduleA}.RunModules(context.Background(), opts, 0)
+
+	var parallelismErr configstack.InvalidParallelismError
+	require.ErrorAs(t, err, &parallelismErr)
+	assert.Equal(t, configstack.InvalidParallelismError(0), parallelismErr)
+	assert.False(t, aRan)
+}
+
+func TestRunModulesExcessiveParallelism(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	formatter := format.NewFormatter()
+	formatter.DisableColors = true
+
+	aRan, bRan, cRan := false, false, false
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.Logger = log.New(log.WithOutput(&output), log.WithLevel(log.InfoLevel), log.WithFormatter(formatter))
+
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 1000)
+	require.NoError(t, err)
+
+	assert.Contains(t, output.String(), "The parallelism of 1000 is far above the 3 modules to run")
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesMaxModules(t *testing.T) {
+	t.Parallel()
+
+	aRan, bRan, cRan := false, false, false
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.MaxModules = 2
+
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var tooManyErr configstack.TooManyModulesError
+	require.ErrorAs(t, err, &tooManyErr)
+	assert.Equal(t, configstack.TooManyModulesError{Count: 3, Max: 2}, tooManyErr)
+	assert.False(t, aRan)
+	assert.False(t, bRan)
+	assert.False(t, cRan)
+
+	// modules assumed already applied don't count
+	moduleB.AssumeAlreadyApplied = true
+
+	require.NoError(t, configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism))
+	assert.True(t, aRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesValidateBeforeRun(t *testing.T) {
+	t.Parallel()
+
+	var (
+		commands []string
+		mu       sync.Mutex
+	)
+
+	newModule := func(path string, validateErr error, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.TerraformCommand = terraform.CommandNameApply
+		opts.TerraformCliArgs = []string{terraform.CommandNameApply}
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			commands = append(commands, opts.TerraformCommand+" "+path)
+
+			if opts.TerraformCommand == terraform.CommandNameValidate {
+				return validateErr
+			}
+
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	moduleA := newModule("a", nil)
+	moduleB := newModule("b", errors.New("Error: Unsupported argument"))
+	moduleC := newModule("c", nil, moduleA)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.ValidateBeforeRun = true
+
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var validationErr configstack.ModuleValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "b", validationErr.ModulePath)
+
+	// every module is validated, and none is applied
+	assert.ElementsMatch(t, []string{"validate a", "validate b", "validate c"}, commands)
+
+	// once the validation passes, the modules are applied in order
+	commands = nil
+	moduleB.TerragruntOptions = newModule("b", nil).TerragruntOptions
+
+	require.NoError(t, configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism))
+	assert.ElementsMatch(t, []string{"validate a", "validate b", "validate c"}, commands[:3])
+	assert.ElementsMatch(t, []string{"apply a", "apply b", "apply c"}, commands[3:])
+}
+
+func TestRunModulesMaxErrorEntries(t *testing.T) {
+	t.Parallel()
+
+	modules := configstack.TerraformModules{}
+	moduleErrs := []error{}
+
+	for i := range 10 {
+		path := fmt.Sprintf("module-%d", i)
+		moduleErr := fmt.Errorf("Expected error for module %s", path)
+
+		modules = append(modules, &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, TerragruntOptions: optionsWithMockTerragruntCommand(t, path, moduleErr, new(bool))})
+		moduleErrs = append(moduleErrs, moduleErr)
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.MaxErrorEntries = 3
+
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var truncatedErr configstack.TruncatedErrors
+	require.ErrorAs(t, err, &truncatedErr)
+
+	expected := `10 errors occurred:
+
+* Expected error for module module-0
+
+* Expected error for module module-1
+
+* Expected error for module module-2
+
+... and 7 more
+`
+	assert.Equal(t, expected, err.Error())
+
+	require.Len(t, truncatedErr.All(), 10)
+
+	for _, moduleErr := range moduleErrs {
+		assert.ErrorIs(t, err, moduleErr)
+	}
+}
+
+func TestRunModulesEventSocket(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "events.sock")
+
+	var clients []net.Conn
+
+	optsA := optionsWithMockTerragruntCommand(t, "a", nil, new(bool))
+	optsA.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+		for range 2 {
+			client, err := net.Dial("unix", socketPath)
+			if err != nil {
+				return err
+			}
+
+			clients = append(clients, client)
+		}
+
+		// let the socket accept the clients before a finishes
+		time.Sleep(100 * time.Millisecond)
+
+		return nil
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optsA}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, new(bool))}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", errors.New("Expected error for module c"), new(bool))}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.EventSocket = socketPath
+
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.Error(t, err)
+
+	require.Len(t, clients, 2)
+
+	for _, client := range clients {
+		// the socket closes the connection once the run is done
+		var (
+			events   []string
+			messages = map[string]string{}
+		)
+
+		scanner := bufio.NewScanner(client)
+		for scanner.Scan() {
+			var event struct {
+				Type    string `json:"type"`
+				Path    string `json:"path"`
+				Message string `json:"message"`
+			}
+
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+
+			events = append(events, event.Type+" "+event.Path)
+
+			if event.Message != "" {
+				messages[event.Path] = event.Message
+			}
+		}
+
+		require.NoError(t, scanner.Err())
+		require.NoError(t, client.Close())
+
+		assert.Equal(t, []string{"module_finished a", "module_started b", "module_finished b", "module_started c", "module_finished c"}, events)
+		assert.Equal(t, map[string]string{"c": "Expected error for module c"}, messages)
+	}
+
+	assert.NoFileExists(t, socketPath)
+}
+
+func TestRunModulesIsolateWorkdir(t *testing.T) {
+	t.Parallel()
+
+	moduleDir := t.TempDir()
+
+	for path, contents := range map[string]string{
+		config.DefaultTerragruntConfigPath:             "terraform {}\n",
+		"main.tf":                                      "resource \"null_resource\" \"test\" {}\n",
+		"modules/network/main.tf":                      "variable \"cidr\" {}\n",
+		"secrets.auto.tfvars":                          "token = \"do-not-copy\"\n",
+		filepath.Join(".terragrunt-cache", "leftover"): "stale\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(moduleDir, path)), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(moduleDir, path), []byte(contents), 0600))
+	}
+
+	var (
+		workingDir string
+		configPath string
+		files      []string
+	)
+
+	moduleOpts, err := options.NewTerragruntOptionsForTest(filepath.Join(moduleDir, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	moduleOpts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+		workingDir = opts.WorkingDir
+		configPath = opts.TerragruntConfigPath
+
+		err := filepath.WalkDir(workingDir, func(path string, entry os.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && !strings.HasSuffix(path, "-manifest") {
+				relPath, _ := filepath.Rel(workingDir, path)
+				files = append(files, filepath.ToSlash(relPath))
+			}
+
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// terraform writes to the directory it runs in
+		return os.WriteFile(filepath.Join(workingDir, "crash.log"), []byte("boom"), 0600)
+	}
+
+	module := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: moduleDir, TerragruntOptions: moduleOpts}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.IsolateWorkdir = true
+	opts.IsolateWorkdirIgnore = []string{"*.auto.tfvars"}
+
+	require.NoError(t, configstack.TerraformModules{module}.RunModules(context.Background(), opts, options.DefaultParallelism))
+
+	assert.NotEqual(t, moduleDir, workingDir)
+	assert.Equal(t, filepath.Join(moduleDir, config.DefaultTerragruntConfigPath), configPath, "the config is read in place")
+	assert.ElementsMatch(t, []string{config.DefaultTerragruntConfigPath, "main.tf", "modules/network/main.tf"}, files)
+
+	assert.NoDirExists(t, workingDir, "the isolated working directory is removed")
+	assert.NoFileExists(t, filepath.Join(moduleDir, "crash.log"), "the original directory is untouched")
+	assert.FileExists(t, filepath.Join(moduleDir, "secrets.auto.tfvars"))
+}
+
+func TestRunModulesIsolateWorkdirLocalBackend(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := t.TempDir()
+
+	newModule := func(path string, run func(opts *options.TerragruntOptions) error, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		moduleDir := filepath.Join(tempFolder, path)
+		require.NoError(t, os.MkdirAll(moduleDir, os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(moduleDir, config.DefaultTerragruntConfigPath), []byte("terraform {}\n"), 0600))
+
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(moduleDir, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			return run(opts)
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: moduleDir, Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	// the local backend writes the state in the directory terraform runs in, along with the states of the workspaces
+	vpc := newModule("vpc", func(opts *options.TerragruntOptions) error {
+		if err := os.MkdirAll(filepath.Join(opts.WorkingDir, "terraform.tfstate.d", "dev"), os.ModePerm); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(opts.WorkingDir, "terraform.tfstate.d", "dev", "terraform.tfstate"), []byte(`{"serial": 1}`), 0600); err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(opts.WorkingDir, "terraform.tfstate"), []byte(`{"serial": 1}`), 0600)
+	})
+
+	var vpcState []byte
+
+	// the outputs of the dependencies are read from their original directory
+	app := newModule("app", func(_ *options.TerragruntOptions) error {
+		var err error
+
+		vpcState, err = os.ReadFile(filepath.Join(vpc.Path, "terraform.tfstate"))
+
+		return err
+	}, vpc)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.IsolateWorkdir = true
+
+	require.NoError(t, configstack.TerraformModules{vpc, app}.RunModules(context.Background(), opts, options.DefaultParallelism))
+
+	assert.JSONEq(t, `{"serial": 1}`, string(vpcState))
+	assert.FileExists(t, filepath.Join(vpc.Path, "terraform.tfstate.d", "dev", "terraform.tfstate"))
+}
+func TestRunModulesFeatures(t *testing.T) {
+	t.Parallel()
+
+	var (
+		workingDirs = map[string]string{}
+		mu          sync.Mutex
+	)
+
+	newModule := func(features map[string]bool) *configstack.TerraformModule {
+		moduleDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(moduleDir, config.DefaultTerragruntConfigPath), []byte("terraform {}\n"), 0600))
+
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(moduleDir, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			workingDirs[moduleDir] = opts.WorkingDir
+
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: moduleDir, TerragruntOptions: opts, Features: features}
+	}
+
+	isolated := newModule(map[string]bool{configstack.FeatureIsolateWorkdir: true})
+	disabled := newModule(map[string]bool{configstack.FeatureIsolateWorkdir: false})
+	plain := newModule(nil)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	require.NoError(t, configstack.TerraformModules{isolated, disabled, plain}.RunModules(context.Background(), opts, options.DefaultParallelism))
+
+	assert.NotEqual(t, isolated.Path, workingDirs[isolated.Path], "the module enabling the feature runs in an isolated directory")
+	assert.Equal(t, disabled.Path, workingDirs[disabled.Path])
+	assert.Equal(t, plain.Path, workingDirs[plain.Path])
+}
+
+func TestRunModulesQueueWarnAfter(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	warnings := make(chan options.RunEvent, 1)
+
+	optsA, err := options.NewTerragruntOptionsForTest("a")
+	require.NoError(t, err)
+
+	// a is slow: it only finishes once b, queued behind it, has been reported
+	optsA.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+		<-clock.timerCreated
+		clock.Advance(2 * time.Minute)
+
+		select {
+		case <-warnings:
+		case <-time.After(10 * time.Second):
+			return errors.New("no queue warning emitted")
+		}
+
+		return nil
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optsA}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, new(bool))}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.Clock = clock
+	opts.QueueWarnAfter = time.Minute
+
+	var events []options.RunEvent
+
+	opts.RunEventHandler = func(event options.RunEvent) {
+		events = append(events, event)
+
+		if event.Type == options.RunEventQueueSaturated {
+			warnings <- event
+		}
+	}
+
+	err = configstack.TerraformModules{moduleA, moduleB}.RunModules(context.Background(), opts, 1)
+	require.NoError(t, err)
+
+	var saturated []options.RunEvent
+
+	for _, event := range events {
+		if event.Type == options.RunEventQueueSaturated {
+			saturated = append(saturated, event)
+		}
+	}
+
+	require.Len(t, saturated, 1)
+	assert.Equal(t, "b", saturated[0].Path)
+	assert.Equal(t, 2*time.Minute, saturated[0].Duration)
+}
+
+func TestRunModulesMarkApplied(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+
+	err := modules.MarkApplied([]string{"a", "b"})
+	require.NoError(t, err)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.Equal(t, []string{"c"}, log.paths)
+}
+
+func TestMarkAppliedUnknownPath(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+
+	err := modules.MarkApplied([]string{"a", "unknown"})
+
+	var unrecognizedErr configstack.UnrecognizedModuleError
+	require.ErrorAs(t, err, &unrecognizedErr)
+
+	for _, module := range modules {
+		assert.False(t, module.AssumeAlreadyApplied, "Module %s must not be flagged", module.Path)
+	}
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	newModules := func() (*configstack.TerraformModule, *configstack.TerraformModule, *configstack.TerraformModule) {
+		moduleA := &configstack.TerraformModule{Path: "a", Config: config.TerragruntConfig{Inputs: map[string]interface{}{"name": "a"}}}
+		moduleB := &configstack.TerraformModule{Path: "b", Dependencies: configstack.TerraformModules{moduleA}}
+		moduleC := &configstack.TerraformModule{Path: "c", Dependencies: configstack.TerraformModules{moduleB}}
+
+		return moduleA, moduleB, moduleC
+	}
+
+	moduleA, moduleB, moduleC := newModules()
+
+	hash, err := configstack.TerraformModules{moduleA, moduleB, moduleC}.Hash()
+	require.NoError(t, err)
+
+	reorderedHash, err := configstack.TerraformModules{moduleC, moduleA, moduleB}.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, reorderedHash)
+
+	moduleA, moduleB, moduleC = newModules()
+	moduleC.Dependencies = configstack.TerraformModules{moduleA}
+
+	edgeChangedHash, err := configstack.TerraformModules{moduleA, moduleB, moduleC}.Hash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, edgeChangedHash)
+
+	moduleA, moduleB, moduleC = newModules()
+	moduleA.Config.Inputs["name"] = "changed"
+
+	configChangedHash, err := configstack.TerraformModules{moduleA, moduleB, moduleC}.Hash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, configChangedHash)
+}
+
+func TestSimilarModules(t *testing.T) {
+	t.Parallel()
+
+	vpc := &configstack.TerraformModule{Path: "vpc"}
+	dns := &configstack.TerraformModule{Path: "dns"}
+	appEU := &configstack.TerraformModule{Path: "app-eu", Dependencies: configstack.TerraformModules{vpc, dns}, Config: config.TerragruntConfig{Inputs: map[string]interface{}{"region": "eu-west-1"}}}
+	appUS := &configstack.TerraformModule{Path: "app-us", Dependencies: configstack.TerraformModules{dns, vpc}, Config: config.TerragruntConfig{Inputs: map[string]interface{}{"region": "us-east-1"}}}
+	// same dependencies, but a different config shape
+	worker := &configstack.TerraformModule{Path: "worker", Dependencies: configstack.TerraformModules{vpc, dns}, Config: config.TerragruntConfig{Inputs: map[string]interface{}{"queue": "jobs"}}}
+	// same config shape, but different dependencies
+	appAP := &configstack.TerraformModule{Path: "app-ap", Dependencies: configstack.TerraformModules{vpc}, Config: config.TerragruntConfig{Inputs: map[string]interface{}{"region": "ap-south-1"}}}
+
+	groups := configstack.TerraformModules{vpc, dns, appEU, appUS, worker, appAP}.SimilarModules()
+
+	assert.Equal(t, [][]string{{"app-eu", "app-us"}, {"dns", "vpc"}}, groups)
+}
+
+func TestRunnableDiff(t *testing.T) {
+	t.Parallel()
+
+	stackDir := t.TempDir()
+
+	modules := configstack.TerraformModules{}
+	modulesByName := map[string]*configstack.TerraformModule{}
+
+	for _, name := range []string{"app", "legacy-db", "legacy-queue"} {
+		path := filepath.Join(stackDir, name)
+		require.NoError(t, os.MkdirAll(path, os.ModePerm))
+
+		module := &configstack.TerraformModule{Path: path}
+		modules = append(modules, module)
+		modulesByName[name] = module
+	}
+
+	modulesByName["app"].Dependencies = configstack.TerraformModules{modulesByName["legacy-db"]}
+
+	before, err := options.NewTerragruntOptionsForTest(filepath.Join(stackDir, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	after, err := before.Clone(before.TerragruntConfigPath)
+	require.NoError(t, err)
+
+	after.ExcludeDirs, err = util.GlobCanonicalPath(stackDir, "legacy-*")
+	require.NoError(t, err)
+
+	added, removed, err := modules.RunnableDiff(before, after)
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Equal(t, configstack.TerraformModules{modulesByName["legacy-db"], modulesByName["legacy-queue"]}, removed)
+
+	added, removed, err = modules.RunnableDiff(after, before)
+	require.NoError(t, err)
+	assert.Equal(t, configstack.TerraformModules{modulesByName["legacy-db"], modulesByName["legacy-queue"]}, added)
+	assert.Empty(t, removed)
+
+	for _, module := range modules {
+		assert.False(t, module.FlagExcluded, "module %s should be left untouched", module.Path)
+	}
+}
+
+func TestRunModulesOneModuleAssumeAlreadyRan(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:                &configstack.Stack{},
+		Path:                 "a",
+		Dependencies:         configstack.TerraformModules{},
+		Config:               config.TerragruntConfig{},
+		TerragruntOptions:    optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+		AssumeAlreadyApplied: true,
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+	assert.False(t, aRan)
+}
+
+func TestRunModulesReverseOrderOneModuleSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+	assert.True(t, aRan)
+}
+
+func TestRunModulesIgnoreOrderOneModuleSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModulesIgnoreOrder(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+	assert.True(t, aRan)
+}
+
+func TestRunModulesOneModuleError(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	expectedErrA := errors.New("Expected error for module a")
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, expectedErrA)
+	assert.True(t, aRan)
+}
+
+func TestRunModulesReverseOrderOneModuleError(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	expectedErrA := errors.New("Expected error for module a")
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, expectedErrA)
+	assert.True(t, aRan)
+}
+
+func TestRunModulesIgnoreOrderOneModuleError(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	expectedErrA := errors.New("Expected error for module a")
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModulesIgnoreOrder(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, expectedErrA)
+	assert.True(t, aRan)
+}
+
+func TestRunModulesOneModuleSuccessExitCode(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	terragruntOptionsA := optionsWithMockTerragruntCommand(t, "a", newExitCodeError(2), &aRan)
+	terragruntOptionsA.SuccessExitCodes = []int{0, 2}
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: terragruntOptionsA,
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{moduleA},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+}
+
+func TestRunModulesOneModuleUnexpectedExitCode(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	expectedErrA := newExitCodeError(2)
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.Error(t, err)
+
+	var exitErr configstack.ModuleExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.ExitCode())
+	assert.Equal(t, expectedErrA, exitErr.Err)
+	assert.True(t, aRan)
+}
+
+func TestRunModulesExitCodeThroughDependencyErrors(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", newExitCodeError(3), &aRan),
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{moduleA},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{moduleB},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var multiErr interface{ Unwrap() []error }
+	require.ErrorAs(t, err, &multiErr)
+	require.Len(t, multiErr.Unwrap(), 3)
+
+	for _, moduleErr := range multiErr.Unwrap() {
+		var exitCoder configstack.ExitCoder
+		if assert.ErrorAs(t, moduleErr, &exitCoder) {
+			assert.Equal(t, 3, exitCoder.ExitCode(), "Unexpected exit code for %v", moduleErr)
+		}
+	}
+
+	var dependencyErr configstack.ProcessingModuleDependencyError
+	require.ErrorAs(t, err, &dependencyErr)
+	assert.Equal(t, 3, dependencyErr.ExitCode())
+
+	assert.True(t, aRan)
+	assert.False(t, bRan)
+	assert.False(t, cRan)
+}
+
+func TestRunModulesMultipleModulesNoDependenciesSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesMultipleModulesNoDependenciesSuccessNoParallelism(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModules(context.Background(), opts, 1)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesReverseOrderMultipleModulesNoDependenciesSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesIgnoreOrderMultipleModulesNoDependenciesSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModulesIgnoreOrder(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesMultipleModulesNoDependenciesOneFailure(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	bRan := false
+	expectedErrB := errors.New("Expected error for module b")
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", expectedErrB, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, optsErr := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, optsErr)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err := modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, expectedErrB)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesMultipleModulesNoDependenciesMultipleFailures(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	expectedErrA := errors.New("Expected error for module a")
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
+	}
+
+	bRan := false
+	expectedErrB := errors.New("Expected error for module b")
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", expectedErrB, &bRan),
+	}
+
+	cRan := false
+	expectedErrC := errors.New("Expected error for module c")
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", expectedErrC, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, expectedErrA, expectedErrB, expectedErrC)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesMultipleModulesWithDependenciesSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "a",
+		Dependencies:      configstack.TerraformModules{},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{moduleA},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "c",
+		Dependencies:      configstack.TerraformModules{moduleB},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.True(t, cRan)
+}
+
+func TestRunModulesMultipleModulesWithDependenciesWithAssumeAlreadyRanSuccess(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{
 		Stack:             &configstack.Stack{},
 		Path:              "a",
 		Dependencies:      configstack.TerraformModules{},
@@ -385,343 +3111,965 @@ func TestRunModulesMultipleModulesNoDependenciesSuccess(t *testing.T) {
 		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
 	}
 
-	bRan := false
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	bRan := false
+	moduleB := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "b",
+		Dependencies:      configstack.TerraformModules{moduleA},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{
+		Stack:                &configstack.Stack{},
+		Path:                 "c",
+		Dependencies:         configstack.TerraformModules{moduleB},
+		Config:               config.TerragruntConfig{},
+		TerragruntOptions:    optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+		AssumeAlreadyApplied: true,
+	}
+
+	dRan := false
+	moduleD := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "d",
+		Dependencies:      configstack.TerraformModules{moduleC},
+		Config:            config.TerragruntConfig{},
+		TerragruntOptions: optionsWithMockTerragruntCommand(t, "d", nil, &dRan),
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.True(t, aRan)
+	assert.True(t, bRan)
+	assert.False(t, cRan)
+	assert.True(t, dRan)
+}
+
+func TestRunModulesWithBarrier(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+
+	barrier := &configstack.TerraformModule{
+		Stack:             &configstack.Stack{},
+		Path:              "barrier",
+		Dependencies:      configstack.TerraformModules{moduleA, moduleB},
+		TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "barrier", nil, log),
+		Barrier:           true,
+	}
+
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{barrier}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+	moduleD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", Dependencies: configstack.TerraformModules{barrier}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "d", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, barrier, moduleC, moduleD}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	assert.Equal(t, -1, log.index("barrier"), "A barrier must not run any command")
+
+	for _, before := range []string{"a", "b"} {
+		for _, after := range []string{"c", "d"} {
+			assert.Less(t, log.index(before), log.index(after), "%s must run before %s", before, after)
+		}
+	}
+}
+
+func TestRunModulesWithPhases(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	network := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "network", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "network", nil, log)}
+	dns := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "dns", Dependencies: configstack.TerraformModules{network}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "dns", nil, log)}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", Phase: 1, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "app", nil, log)}
+	worker := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "worker", Phase: 1, Dependencies: configstack.TerraformModules{app}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "worker", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	modules := configstack.TerraformModules{network, dns, app, worker}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	for _, before := range []string{"network", "dns"} {
+		for _, after := range []string{"app", "worker"} {
+			assert.Less(t, log.index(before), log.index(after), "%s must run before %s", before, after)
+		}
+	}
+
+	assert.Less(t, log.index("network"), log.index("dns"))
+	assert.Less(t, log.index("app"), log.index("worker"))
+
+	stack := configstack.NewStack(opts)
+	stack.Modules = modules
+
+	runGraph, err := stack.GetModuleRunGraph(terraform.CommandNameDestroy)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"worker"}, {"app"}, {"dns"}, {"network"}}, levelPaths(moduleGroups(runGraph)))
+}
+
+func TestRunModulesPhaseDependencyOnLaterPhase(t *testing.T) {
+	t.Parallel()
+
+	ran := false
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", Phase: 1, TerragruntOptions: optionsWithMockTerragruntCommand(t, "app", nil, &ran)}
+	network := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "network", Dependencies: configstack.TerraformModules{app}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "network", nil, &ran)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{app, network}.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var phaseErr configstack.PhaseDependencyError
+	require.ErrorAs(t, err, &phaseErr)
+	assert.Equal(t, "network", phaseErr.Module.Path)
+	assert.Equal(t, "app", phaseErr.Dependency.Path)
+	assert.False(t, ran)
+}
+
+func TestRunModulesRunLast(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	network := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "network", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "network", nil, log)}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", Dependencies: configstack.TerraformModules{network}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "app", nil, log)}
+	dns := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "dns", Phase: 1, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "dns", nil, log)}
+	validation := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "validation", RunLast: true, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "validation", nil, log)}
+	report := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "report", RunLast: true, Dependencies: configstack.TerraformModules{validation}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "report", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{report, validation, network, app, dns}.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	require.Len(t, log.paths, 5)
+	assert.ElementsMatch(t, []string{"network", "app", "dns"}, log.paths[:3])
+	assert.Equal(t, []string{"validation", "report"}, log.paths[3:])
+}
+
+func TestRunModulesApplyLimit(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	// d -> c -> b -> a
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+	moduleD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", Dependencies: configstack.TerraformModules{moduleC}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "d", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.ApplyLimit = 2
+
+	summary, err := configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}.RunModulesWithSummary(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "b"}, log.paths)
+
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["a"].Status)
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["b"].Status)
+
+	for _, module := range []*configstack.TerraformModule{moduleC, moduleD} {
+		assert.Equal(t, configstack.ModuleSkipped, summary.Modules[module.Path].Status)
+		assert.Equal(t, configstack.ApplyLimitReachedError{Module: module, Limit: 2}, summary.Modules[module.Path].Err)
 	}
+}
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+func TestRunModulesApplyLimitReplacesFailedModules(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+	errB := errors.New("Expected error for module b")
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", errB, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+	moduleD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "d", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.ApplyLimit = 2
+
+	// with a parallelism of 1, the modules run in the order of their paths, and the failure of b lets c run in its place
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}.RunModules(context.Background(), opts, 1)
+	assertMultiErrorContains(t, err, errB)
+
+	assert.Equal(t, []string{"a", "b", "c"}, log.paths)
+}
+
+func TestRunModulesSkipsWhileModulesRun(t *testing.T) {
+	t.Parallel()
+
+	// slow runs until the given module has finished, so that the modules are skipped while it runs or right after it
+	// ran: meant to be run with -race
+	run := func(t *testing.T, finishedFirst string, modules configstack.TerraformModules, opts *options.TerragruntOptions) (configstack.RunSummary, error) {
+		t.Helper()
+
+		release := make(chan struct{})
+
+		slowOpts, err := options.NewTerragruntOptionsForTest("slow")
+		require.NoError(t, err)
+
+		slowOpts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			<-release
+			return nil
+		}
+
+		slow := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "slow", Group: "database", TerragruntOptions: slowOpts}
+
+		opts.RunEventHandler = func(event options.RunEvent) {
+			if event.Type == options.RunEventModuleFinished && event.Path == finishedFirst {
+				close(release)
+			}
+		}
+
+		return append(modules, slow).RunModulesWithSummary(context.Background(), opts, options.DefaultParallelism)
 	}
 
+	t.Run("group", func(t *testing.T) {
+		t.Parallel()
+
+		log := &executionLog{}
+		errA := errors.New("Expected error for module a")
+
+		moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", Group: "database", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", errA, log)}
+
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		summary, err := run(t, "a", configstack.TerraformModules{moduleA}, opts)
+		assertMultiErrorContains(t, err, errA)
+
+		// slow had already started when a failed, so it is not skipped
+		assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["slow"].Status)
+	})
+
+	t.Run("apply limit", func(t *testing.T) {
+		t.Parallel()
+
+		log := &executionLog{}
+
+		moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+		moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		opts.ApplyLimit = 2
+
+		// the limit is reached once slow, which runs along with a, has finished
+		summary, err := run(t, "a", configstack.TerraformModules{moduleA, moduleB}, opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, configstack.ModuleSkipped, summary.Modules["b"].Status)
+		assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["slow"].Status)
+	})
+
+	t.Run("levels", func(t *testing.T) {
+		t.Parallel()
+
+		log := &executionLog{}
+
+		moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "a", nil, log)}
+		moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		opts.PauseBetweenLevels = true
+		opts.ContinueToLevel = func(_ context.Context, _ int) (bool, error) {
+			return false, nil
+		}
+
+		// slow is in level 0 along with a, so it has finished when the run is not continued to level 1
+		summary, err := run(t, "a", configstack.TerraformModules{moduleA, moduleB}, opts)
+		require.Error(t, err)
+
+		assert.Equal(t, configstack.ModuleSkipped, summary.Modules["b"].Status)
+		assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["slow"].Status)
+	})
+}
+
+func TestRunModulesReverseOrderRunLast(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+
+	network := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "network", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "network", nil, log)}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", Dependencies: configstack.TerraformModules{network}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "app", nil, log)}
+	dns := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "dns", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "dns", nil, log)}
+	validation := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "validation", RunLast: true, Dependencies: configstack.TerraformModules{app}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "validation", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{validation, network, app, dns}.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err)
+
+	// the module that runs last runs first, ahead of the modules it doesn't depend on as well
+	require.Len(t, log.paths, 4)
+	assert.Equal(t, "validation", log.paths[0])
+	assert.Less(t, slices.Index(log.paths, "app"), slices.Index(log.paths, "network"))
+	assert.Contains(t, log.paths, "dns")
+}
+
+func TestRunModulesRunLastBlockedByFailure(t *testing.T) {
+	t.Parallel()
+
+	log := &executionLog{}
+	errApp := errors.New("Expected error for module app")
+
+	network := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "network", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "network", nil, log)}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "app", errApp, log)}
+	validation := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "validation", RunLast: true, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "validation", nil, log)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{network, app, validation}.RunModules(context.Background(), opts, options.DefaultParallelism)
+	assertMultiErrorContains(t, err, errApp, configstack.ProcessingModuleDependencyError{Module: validation, Dependency: app, Err: errApp})
+
+	assert.ElementsMatch(t, []string{"network", "app"}, log.paths)
+}
+
+func TestRunModulesDependencyOnRunLast(t *testing.T) {
+	t.Parallel()
+
+	ran := false
+	validation := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "validation", RunLast: true, TerragruntOptions: optionsWithMockTerragruntCommand(t, "validation", nil, &ran)}
+	app := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "app", Dependencies: configstack.TerraformModules{validation}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "app", nil, &ran)}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	err = configstack.TerraformModules{validation, app}.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+	var runLastErr configstack.RunLastDependencyError
+	require.ErrorAs(t, err, &runLastErr)
+	assert.Equal(t, "app", runLastErr.Module.Path)
+	assert.Equal(t, "validation", runLastErr.Dependency.Path)
+
+	err = configstack.TerraformModules{validation, app}.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
+
+	require.ErrorAs(t, err, &runLastErr)
+	assert.Equal(t, "app", runLastErr.Module.Path)
+	assert.Equal(t, "validation", runLastErr.Dependency.Path)
+	assert.False(t, ran)
+}
+
+func TestRunModulesSchedulerTrace(t *testing.T) {
+	t.Parallel()
+
+	aRan := false
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan)}
+
+	bRan := false
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan)}
+
+	cRan := false
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleA, moduleB}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan)}
+
+	var trace bytes.Buffer
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
+	opts.Clock = newFakeClock()
+	opts.SchedulerTrace = &trace
+
 	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	err = modules.RunModules(context.Background(), opts, 1)
 	require.NoError(t, err, "Unexpected error: %v", err)
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+
+	// Strip the timestamps, which are all the same with the fake clock
+	events := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		event, found := strings.CutPrefix(line, "2024-01-01T00:00:00Z ")
+		require.True(t, found, "Trace line without timestamp: %s", line)
+
+		events = append(events, event)
+	}
+
+	for _, expected := range []string{
+		"blocked c waiting for dependencies: a, b",
+		"ready a",
+		"dispatched a",
+		"finished a successfully",
+		"ready b",
+		"dispatched b",
+		"finished b successfully",
+		"ready c",
+		"dispatched c",
+		"finished c successfully",
+	} {
+		assert.Contains(t, events, expected)
+	}
+
+	assert.Less(t, slices.Index(events, "blocked c waiting for dependencies: a, b"), slices.Index(events, "ready c"))
+
+	for _, dependency := range []string{"a", "b"} {
+		assert.Less(t, slices.Index(events, "finished "+dependency+" successfully"), slices.Index(events, "ready c"))
+	}
+
+	assert.Less(t, slices.Index(events, "ready c"), slices.Index(events, "dispatched c"))
+	assert.Less(t, slices.Index(events, "dispatched c"), slices.Index(events, "finished c successfully"))
 }
 
-func TestRunModulesMultipleModulesNoDependenciesSuccessNoParallelism(t *testing.T) {
+//nolint:paralleltest
+func TestRunModulesWideGraphBoundedGoroutines(t *testing.T) {
+	// Not run in parallel with the other tests, so that their goroutines don't affect the count.
+	const (
+		moduleCount = 10000
+		parallelism = 8
+		// Goroutines that may be started besides the workers, e.g. by the test framework.
+		slack = 10
+	)
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	var maxGoroutines atomic.Int64
+
+	opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+		goroutines := int64(runtime.NumGoroutine())
+
+		for {
+			current := maxGoroutines.Load()
+			if goroutines <= current || maxGoroutines.CompareAndSwap(current, goroutines) {
+				return nil
+			}
+		}
+	}
+
+	modules := make(configstack.TerraformModules, 0, moduleCount)
+
+	for i := range moduleCount {
+		moduleOpts, err := opts.Clone(fmt.Sprintf("module-%d/terragrunt.hcl", i))
+		require.NoError(t, err)
+
+		modules = append(modules, &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: fmt.Sprintf("module-%d", i), TerragruntOptions: moduleOpts})
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	err = modules.RunModules(context.Background(), opts, parallelism)
+	require.NoError(t, err)
+
+	assert.Positive(t, maxGoroutines.Load())
+	assert.LessOrEqual(t, maxGoroutines.Load(), int64(baseline+parallelism+slack))
+}
+
+func TestRunModulesTimeline(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	clock := newFakeClock()
+	start := clock.Now()
+
+	mockOptions := func(path string, duration time.Duration) *options.TerragruntOptions {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			clock.Advance(duration)
+			return nil
+		}
+
+		return opts
 	}
 
-	bRan := false
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: mockOptions("a", time.Second)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: mockOptions("b", 2*time.Second)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: mockOptions("c", 3*time.Second)}
+
+	timelineDir := t.TempDir()
+
+	for _, timelineFile := range []string{"timeline.json", "timeline.html"} {
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		opts.Clock = clock
+		opts.RunTimelineFile = filepath.Join(timelineDir, timelineFile)
+
+		modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+		err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+		require.NoError(t, err, "Unexpected error: %v", err)
 	}
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	content, err := os.ReadFile(filepath.Join(timelineDir, "timeline.json"))
+	require.NoError(t, err)
+
+	var timeline configstack.Timeline
+	require.NoError(t, json.Unmarshal(content, &timeline))
+
+	assert.True(t, start.Equal(timeline.Start))
+	assert.Equal(t, int64(6000), timeline.DurationMs)
+	assert.Equal(t, []configstack.TimelineEntry{
+		{Path: "a", Start: start, End: start.Add(time.Second), OffsetMs: 0, DurationMs: 1000, Status: configstack.TimelineStatusSucceeded},
+		{Path: "b", Start: start.Add(time.Second), End: start.Add(3 * time.Second), OffsetMs: 1000, DurationMs: 2000, Status: configstack.TimelineStatusSucceeded},
+		{Path: "c", Start: start.Add(3 * time.Second), End: start.Add(6 * time.Second), OffsetMs: 3000, DurationMs: 3000, Status: configstack.TimelineStatusSucceeded},
+	}, timeline.Modules)
+
+	html, err := os.ReadFile(filepath.Join(timelineDir, "timeline.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(html), `title="c: 3000ms, succeeded"`)
+}
+
+func TestRunModulesFoldedStacks(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+
+	mockOptions := func(path string, duration time.Duration) *options.TerragruntOptions {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			clock.Advance(duration)
+			return nil
+		}
+
+		return opts
 	}
 
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: mockOptions("a", time.Second)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: mockOptions("b", 2*time.Second)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: mockOptions("c", 3*time.Second)}
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
+	opts.Clock = clock
+	opts.FoldedStacksFile = filepath.Join(t.TempDir(), "stacks.folded")
+
 	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err = modules.RunModules(context.Background(), opts, 1)
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
 	require.NoError(t, err, "Unexpected error: %v", err)
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
+	content, err := os.ReadFile(opts.FoldedStacksFile)
+	require.NoError(t, err)
+	assert.Equal(t, "a 1000\na;b 2000\na;b;c 3000\n", string(content))
+}
+
+func TestRunModulesOnStallPoint(t *testing.T) {
+	t.Parallel()
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	var stalls [][]string
+
+	stack := configstack.NewStack(opts, configstack.WithOnStallPoint(func(blocked configstack.TerraformModules) {
+		var paths []string
+		for _, module := range blocked {
+			paths = append(paths, module.Path)
+		}
+
+		stalls = append(stalls, paths)
+	}))
+
+	newModule := func(path string, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			return nil
+		}
+
+		return &configstack.TerraformModule{Stack: stack, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+	}
+
+	// c -> b -> a
+	moduleA := newModule("a")
+	moduleB := newModule("b", moduleA)
+	moduleC := newModule("c", moduleB)
+
+	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
+	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
+	require.NoError(t, err, "Unexpected error: %v", err)
+
+	// the run stalls while a runs, then while b runs, but not while c runs, as no module is left waiting
+	assert.Equal(t, [][]string{{"b", "c"}, {"c"}}, stalls)
+}
+
+func TestRunModulesExitCodeFunc(t *testing.T) {
+	t.Parallel()
+
+	errA := errors.New("Expected error for module a")
+
+	for _, exitCode := range []int{0, 3} {
+		opts, err := options.NewTerragruntOptionsForTest("")
+		require.NoError(t, err)
+
+		var summary configstack.RunSummary
+
+		stack := configstack.NewStack(opts, configstack.WithExitCodeFunc(func(runSummary configstack.RunSummary) int {
+			summary = runSummary
+			return exitCode
+		}))
+
+		moduleA := &configstack.TerraformModule{Stack: stack, Path: "a", TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", errA, new(bool))}
+		moduleB := &configstack.TerraformModule{Stack: stack, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, new(bool))}
+		moduleC := &configstack.TerraformModule{Stack: stack, Path: "c", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, new(bool))}
+
+		err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, options.DefaultParallelism)
+
+		require.Len(t, summary.Modules, 3)
+		assert.Equal(t, configstack.ModuleFailed, summary.Modules["a"].Status)
+		assert.ErrorIs(t, summary.Modules["a"].Err, errA)
+		assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["b"].Status)
+		assert.Equal(t, configstack.ModuleBlocked, summary.Modules["c"].Status)
+
+		if exitCode == 0 {
+			require.NoError(t, err)
+			continue
+		}
+
+		actualExitCode, exitCodeErr := util.GetExitCode(err)
+		require.NoError(t, exitCodeErr)
+		assert.Equal(t, exitCode, actualExitCode)
+		assert.ErrorIs(t, err, errA)
+	}
 }
 
-func TestRunModulesReverseOrderMultipleModulesNoDependenciesSuccess(t *testing.T) {
+func TestRunModulesTracer(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
-	}
+	clock := newFakeClock()
+	failure := errors.New("boom")
 
-	bRan := false
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
-	}
+	newModule := func(path string, err error, duration time.Duration, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		opts, optsErr := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, optsErr)
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			clock.Advance(duration)
+			return err
+		}
+
+		return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
 	}
 
+	moduleA := newModule("a", nil, 2*time.Second)
+	moduleB := newModule("b", failure, 3*time.Second, moduleA)
+	moduleC := newModule("c", nil, time.Second, moduleB)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err = modules.RunModulesReverseOrder(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
+	opts.Clock = clock
+	opts.Tracer = provider.Tracer("test")
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
-}
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 1)
+	require.Error(t, err)
 
-func TestRunModulesIgnoreOrderMultipleModulesNoDependenciesSuccess(t *testing.T) {
-	t.Parallel()
+	spans := exporter.GetSpans()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
-	}
+	var runSpan tracetest.SpanStub
 
-	bRan := false
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
-	}
+	moduleSpans := map[string]tracetest.SpanStub{}
+	attributes := map[string]map[string]any{}
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+	for _, span := range spans {
+		if span.Name == "run_modules" {
+			runSpan = span
+			continue
+		}
+
+		require.Equal(t, "run_module", span.Name)
+
+		values := map[string]any{}
+		for _, attr := range span.Attributes {
+			values[string(attr.Key)] = attr.Value.AsInterface()
+		}
+
+		path, _ := values["path"].(string)
+		moduleSpans[path] = span
+		attributes[path] = values
 	}
 
-	opts, err := options.NewTerragruntOptionsForTest("")
-	require.NoError(t, err)
+	require.True(t, runSpan.SpanContext.IsValid())
+	require.Len(t, moduleSpans, 3)
 
-	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err = modules.RunModulesIgnoreOrder(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
+	assert.Equal(t, map[string]map[string]any{
+		"a": {"path": "a", "status": "succeeded", "duration_ms": int64(2000)},
+		"b": {"path": "b", "status": "failed", "duration_ms": int64(3000)},
+		"c": {"path": "c", "status": "blocked", "duration_ms": int64(0)},
+	}, attributes)
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
+	for path, span := range moduleSpans {
+		assert.Equal(t, runSpan.SpanContext.SpanID(), span.Parent.SpanID(), path)
+	}
+
+	assert.Equal(t, codes.Error, moduleSpans["b"].Status.Code)
+	assert.Equal(t, codes.Unset, moduleSpans["a"].Status.Code)
+
+	// the spans of the modules are linked to the spans of their dependencies
+	assert.Empty(t, moduleSpans["a"].Links)
+	require.Len(t, moduleSpans["b"].Links, 1)
+	assert.Equal(t, moduleSpans["a"].SpanContext.SpanID(), moduleSpans["b"].Links[0].SpanContext.SpanID())
+	require.Len(t, moduleSpans["c"].Links, 1)
+	assert.Equal(t, moduleSpans["b"].SpanContext.SpanID(), moduleSpans["c"].Links[0].SpanContext.SpanID())
 }
 
-func TestRunModulesMultipleModulesNoDependenciesOneFailure(t *testing.T) {
+func TestRunModulesWarmUpCommand(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
-	}
+	run := func(t *testing.T, warmUpCommand string) ([]string, error) {
+		t.Helper()
 
-	bRan := false
-	expectedErrB := errors.New("Expected error for module b")
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", expectedErrB, &bRan),
-	}
+		workingDir := t.TempDir()
+		warmUpLog := filepath.Join(workingDir, "warm-up.log")
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+		var (
+			mu       sync.Mutex
+			executed []string
+		)
+
+		newModule := func(path string, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+			opts, err := options.NewTerragruntOptionsForTest(path)
+			require.NoError(t, err)
+
+			opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+				// the modules see the warm-up, which ran once
+				contents, err := os.ReadFile(warmUpLog)
+				assert.NoError(t, err)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				executed = append(executed, path+" after "+strings.TrimSpace(string(contents)))
+
+				return nil
+			}
+
+			return &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: path, Dependencies: dependencies, TerragruntOptions: opts}
+		}
+
+		moduleA := newModule("a")
+		moduleB := newModule("b", moduleA)
+		moduleC := newModule("c")
+
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(workingDir, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.WarmUpCommand = []string{"sh", "-c", warmUpCommand}
+
+		err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 2)
+
+		slices.Sort(executed)
+
+		return executed, err
 	}
 
-	opts, optsErr := options.NewTerragruntOptionsForTest("")
-	require.NoError(t, optsErr)
+	executed, err := run(t, "echo warm-up >> warm-up.log")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a after warm-up", "b after warm-up", "c after warm-up"}, executed)
 
-	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err := modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	assertMultiErrorContains(t, err, expectedErrB)
+	executed, err = run(t, "exit 3")
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
+	var warmUpErr configstack.WarmUpCommandError
+	require.ErrorAs(t, err, &warmUpErr)
+	assert.Equal(t, []string{"sh", "-c", "exit 3"}, warmUpErr.Command)
+	assert.Empty(t, executed)
 }
 
-func TestRunModulesMultipleModulesNoDependenciesMultipleFailures(t *testing.T) {
+func TestRunModulesRecoversPanic(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	expectedErrA := errors.New("Expected error for module a")
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", expectedErrA, &aRan),
-	}
+	log := &executionLog{}
 
-	bRan := false
-	expectedErrB := errors.New("Expected error for module b")
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", expectedErrB, &bRan),
+	panickingOpts := optionsWithMockTerragruntCommandLog(t, "a", nil, log)
+	panickingOpts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+		panic("Expected panic for module a")
 	}
 
-	cRan := false
-	expectedErrC := errors.New("Expected error for module c")
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", expectedErrC, &cRan),
-	}
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: panickingOpts}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "b", nil, log)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "c", nil, log)}
+	moduleD := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: optionsWithMockTerragruntCommandLog(t, "d", nil, log)}
 
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	assertMultiErrorContains(t, err, expectedErrA, expectedErrB, expectedErrC)
+	summary, err := configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}.RunModulesWithSummary(context.Background(), opts, options.DefaultParallelism)
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
+	var panicErr configstack.ModulePanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, moduleA, panicErr.Module)
+	assert.Equal(t, "Expected panic for module a", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestRunModulesRecoversPanic")
+
+	assert.ElementsMatch(t, []string{"b", "d"}, log.paths)
+	assert.Equal(t, configstack.ModuleFailed, summary.Modules["a"].Status)
+	assert.Equal(t, configstack.ModuleBlocked, summary.Modules["c"].Status)
+	assert.Equal(t, configstack.ModuleSucceeded, summary.Modules["d"].Status)
 }
 
-func TestRunModulesMultipleModulesWithDependenciesSuccess(t *testing.T) {
+func TestRunModulesJUnitReport(t *testing.T) {
 	t.Parallel()
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
-	}
+	clock := newFakeClock()
+	errB := errors.New("Expected error for module b")
 
-	bRan := false
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{moduleA},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
-	}
+	mockOptions := func(path string, duration time.Duration, toReturn error) *options.TerragruntOptions {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "c",
-		Dependencies:      configstack.TerraformModules{moduleB},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			clock.Advance(duration)
+			return toReturn
+		}
+
+		return opts
 	}
 
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: mockOptions("a", time.Second, nil)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: mockOptions("b", 2*time.Second, errB)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: mockOptions("c", time.Second, nil)}
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA, moduleB, moduleC}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
+	opts.Clock = clock
+	opts.JUnitReportFile = filepath.Join(t.TempDir(), "reports", "junit.xml")
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.True(t, cRan)
-}
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 1)
+	require.Error(t, err)
 
-func TestRunModulesMultipleModulesWithDependenciesWithAssumeAlreadyRanSuccess(t *testing.T) {
-	t.Parallel()
+	content, err := os.ReadFile(opts.JUnitReportFile)
+	require.NoError(t, err)
 
-	aRan := false
-	moduleA := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "a",
-		Dependencies:      configstack.TerraformModules{},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "a", nil, &aRan),
+	type message struct {
+		Message string `xml:"message,attr"`
 	}
 
-	bRan := false
-	moduleB := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "b",
-		Dependencies:      configstack.TerraformModules{moduleA},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "b", nil, &bRan),
+	var report struct {
+		XMLName xml.Name `xml:"testsuites"`
+		Suites  []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Skipped  int    `xml:"skipped,attr"`
+			Time     string `xml:"time,attr"`
+			Cases    []struct {
+				Name    string   `xml:"name,attr"`
+				Time    string   `xml:"time,attr"`
+				Failure *message `xml:"failure"`
+				Skipped *message `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
 	}
 
-	cRan := false
-	moduleC := &configstack.TerraformModule{
-		Stack:                &configstack.Stack{},
-		Path:                 "c",
-		Dependencies:         configstack.TerraformModules{moduleB},
-		Config:               config.TerragruntConfig{},
-		TerragruntOptions:    optionsWithMockTerragruntCommand(t, "c", nil, &cRan),
-		AssumeAlreadyApplied: true,
-	}
+	require.NoError(t, xml.Unmarshal(content, &report))
+	require.Len(t, report.Suites, 1)
+
+	suite := report.Suites[0]
+	assert.Equal(t, "terragrunt", suite.Name)
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Skipped)
+	assert.Equal(t, "3.000", suite.Time)
+	require.Len(t, suite.Cases, 3)
+
+	assert.Equal(t, "a", suite.Cases[0].Name)
+	assert.Equal(t, "1.000", suite.Cases[0].Time)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Nil(t, suite.Cases[0].Skipped)
+
+	assert.Equal(t, "b", suite.Cases[1].Name)
+	assert.Equal(t, "2.000", suite.Cases[1].Time)
+	require.NotNil(t, suite.Cases[1].Failure)
+	assert.Contains(t, suite.Cases[1].Failure.Message, "Expected error for module b")
+
+	assert.Equal(t, "c", suite.Cases[2].Name)
+	assert.Nil(t, suite.Cases[2].Failure)
+	require.NotNil(t, suite.Cases[2].Skipped)
+	assert.Contains(t, suite.Cases[2].Skipped.Message, "Cannot process module")
+}
 
-	dRan := false
-	moduleD := &configstack.TerraformModule{
-		Stack:             &configstack.Stack{},
-		Path:              "d",
-		Dependencies:      configstack.TerraformModules{moduleC},
-		Config:            config.TerragruntConfig{},
-		TerragruntOptions: optionsWithMockTerragruntCommand(t, "d", nil, &dRan),
+func TestRunModulesPrometheusTextfile(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+
+	mockOptions := func(path string, duration time.Duration, toReturn error) *options.TerragruntOptions {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			clock.Advance(duration)
+			return toReturn
+		}
+
+		return opts
 	}
 
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: mockOptions("a", time.Second, nil)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", TerragruntOptions: mockOptions("b", 2*time.Second, errors.New("Expected error for module b"))}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: mockOptions("c", time.Second, nil)}
+
 	opts, err := options.NewTerragruntOptionsForTest("")
 	require.NoError(t, err)
 
-	modules := configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD}
-	err = modules.RunModules(context.Background(), opts, options.DefaultParallelism)
-	require.NoError(t, err, "Unexpected error: %v", err)
+	opts.Clock = clock
+	opts.PrometheusTextfile = filepath.Join(t.TempDir(), "metrics", "terragrunt.prom")
 
-	assert.True(t, aRan)
-	assert.True(t, bRan)
-	assert.False(t, cRan)
-	assert.True(t, dRan)
+	err = configstack.TerraformModules{moduleA, moduleB, moduleC}.RunModules(context.Background(), opts, 1)
+	require.Error(t, err)
+
+	content, err := os.ReadFile(opts.PrometheusTextfile)
+	require.NoError(t, err)
+
+	// every line is either a comment or a sample with a metric name, optional labels and a float value
+	sampleRegex := regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{(?:[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\]|\\.)*",?)*\})? (\S+)$`)
+	samples := map[string]float64{}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		matches := sampleRegex.FindStringSubmatch(line)
+		require.NotNil(t, matches, "invalid sample %q", line)
+
+		value, err := strconv.ParseFloat(matches[3], 64)
+		require.NoError(t, err, "invalid value in sample %q", line)
+
+		samples[matches[1]+matches[2]] = value
+	}
+
+	assert.Equal(t, map[string]float64{
+		"terragrunt_modules_total":                     3,
+		"terragrunt_modules_failed":                    1,
+		"terragrunt_run_duration_seconds":              3,
+		`terragrunt_module_duration_seconds{path="a"}`: 1,
+		`terragrunt_module_duration_seconds{path="b"}`: 2,
+	}, samples)
+	assert.Contains(t, string(content), "# TYPE terragrunt_module_duration_seconds gauge")
 }
 
 func TestRunModulesReverseOrderMultipleModulesWithDependenciesSuccess(t *testing.T) {
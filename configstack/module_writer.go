@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
 )
 
 // ModuleWriter represents a Writer with data buffering.
@@ -14,6 +16,12 @@ import (
 type ModuleWriter struct {
 	buffer *bytes.Buffer
 	out    io.Writer
+	// If set, also receives all the data written, as soon as it is written.
+	capture io.Writer
+	// If set, the data of the module with the given path is streamed to `out` as it is written while the module is
+	// focused, and discarded instead of flushed while another module is.
+	focus *options.OutputFocus
+	path  string
 }
 
 // NewModuleWriter returns a new ModuleWriter instance.
@@ -31,11 +39,28 @@ func (writer *ModuleWriter) Write(p []byte) (int, error) {
 		return n, errors.New(err)
 	}
 
+	if writer.capture != nil {
+		if _, err := writer.capture.Write(p); err != nil {
+			return n, errors.New(err)
+		}
+	}
+
+	if writer.focused() {
+		if err := writer.Flush(); err != nil {
+			return n, err
+		}
+	}
+
 	return n, nil
 }
 
-// Flush flushes buffer data to the `out` writer.
+// Flush flushes buffer data to the `out` writer. While another module is focused, the data is discarded instead.
 func (writer *ModuleWriter) Flush() error {
+	if quietOutput(writer.focus, writer.path) {
+		writer.buffer.Reset()
+		return nil
+	}
+
 	if _, err := fmt.Fprint(writer.out, writer.buffer); err != nil {
 		return errors.New(err)
 	}
@@ -44,3 +69,59 @@ func (writer *ModuleWriter) Flush() error {
 
 	return nil
 }
+
+// writeMarker appends the given marker of the CI log to the buffer, without capturing it as data of the module.
+func (writer *ModuleWriter) writeMarker(marker string) {
+	writer.buffer.WriteString(marker)
+}
+
+// focused returns true if the output is focused on the module of the writer.
+func (writer *ModuleWriter) focused() bool {
+	return writer.focus != nil && writer.focus.Focused() == writer.path
+}
+
+// quietOutput returns true if the output is focused on another module than the module with the given path.
+func quietOutput(focus *options.OutputFocus, path string) bool {
+	if focus == nil {
+		return false
+	}
+
+	focused := focus.Focused()
+
+	return focused != "" && focused != path
+}
+
+// focusedWriter writes to `out` unless the output is focused on another module than the module with the given path.
+type focusedWriter struct {
+	out   io.Writer
+	focus *options.OutputFocus
+	path  string
+}
+
+func (writer focusedWriter) Write(p []byte) (int, error) {
+	if quietOutput(writer.focus, writer.path) {
+		return len(p), nil
+	}
+
+	return writer.out.Write(p)
+}
+
+// moduleOutput accumulates the combined stdout and stderr of a module, which may be written concurrently.
+type moduleOutput struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (output *moduleOutput) Write(p []byte) (int, error) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	return output.buffer.Write(p)
+}
+
+func (output *moduleOutput) String() string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	return output.buffer.String()
+}
@@ -14,6 +14,59 @@ func WithChildTerragruntConfig(config *config.TerragruntConfig) Option {
 	}
 }
 
+// WithDiscoverer sets the Discoverer finding the configuration files of the modules of the stack, which looks for them
+// on the file system by default.
+func WithDiscoverer(discoverer Discoverer) Option {
+	return func(stack *Stack) {
+		stack.discoverer = discoverer
+	}
+}
+
+// WithErrorTransform sets a function applied to the error of every module of the stack that fails, e.g. to redact it.
+// The returned error is the one recorded for the module and seen by its dependents. Returning nil keeps the original
+// error.
+func WithErrorTransform(transform func(module *TerraformModule, err error) error) Option {
+	return func(stack *Stack) {
+		stack.errorTransform = transform
+	}
+}
+
+// WithAlreadyAppliedFunc sets a function called right before every module of the stack runs, to decide whether it has
+// already been applied, e.g. by checking a remote marker. A module reported as already applied is not run and counts
+// as a success for its dependents, like with AssumeAlreadyApplied. Returning an error fails the module.
+func WithAlreadyAppliedFunc(alreadyApplied func(module *TerraformModule) (bool, error)) Option {
+	return func(stack *Stack) {
+		stack.alreadyApplied = alreadyApplied
+	}
+}
+
+// WithOnStallPoint sets a function called with the modules left to run, sorted by path, whenever a run of the stack
+// stalls: no module is ready to run, and all the modules left wait for the dependencies that are running. It is called
+// once each time the run stalls, from the scheduler, so it must return quickly. Meant for finding the serial
+// bottlenecks of a stack.
+func WithOnStallPoint(onStallPoint func(blocked TerraformModules)) Option {
+	return func(stack *Stack) {
+		stack.onStallPoint = onStallPoint
+	}
+}
+
+// WithExitCodeFunc sets a function deriving the exit code of the runs of the stack from their summary, instead of
+// failing if any module failed: a run fails with the returned exit code if it is not 0, and succeeds otherwise, even if
+// some modules failed.
+func WithExitCodeFunc(exitCode func(summary RunSummary) int) Option {
+	return func(stack *Stack) {
+		stack.exitCode = exitCode
+	}
+}
+
+// WithGraphLabels makes the stack decode the labels of its modules even when nothing else uses them, so that they are
+// shown in the graphs of the stack.
+func WithGraphLabels() Option {
+	return func(stack *Stack) {
+		stack.graphLabels = true
+	}
+}
+
 func WithParseOptions(parserOptions []hclparse.Option) Option {
 	return func(stack *Stack) {
 		stack.parserOptions = parserOptions
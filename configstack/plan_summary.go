@@ -0,0 +1,107 @@
+package configstack
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+)
+
+var (
+	// planChangesRegex matches the summary line of the human-readable output of terraform plan.
+	planChangesRegex = regexp.MustCompile(`Plan: (\d+) to add, (\d+) to change, (\d+) to destroy`)
+	// noChangesRegex matches the human-readable output of terraform plan when there is nothing to change.
+	noChangesRegex = regexp.MustCompile(`No changes\.`)
+)
+
+// PlanChanges is the number of resources a plan would add, change and destroy.
+type PlanChanges struct {
+	Add     int
+	Change  int
+	Destroy int
+}
+
+// PlanSummary is the aggregate of the plans of the modules of a run.
+type PlanSummary struct {
+	// Total of the changes of the modules whose plan output could be parsed.
+	PlanChanges
+	// Paths of the modules whose plan output could not be parsed, sorted.
+	Unknown []string
+}
+
+// planChangeSummary is the change_summary message of the machine-readable output of terraform plan -json.
+type planChangeSummary struct {
+	Type    string `json:"type"`
+	Changes struct {
+		Add    int `json:"add"`
+		Change int `json:"change"`
+		Remove int `json:"remove"`
+	} `json:"changes"`
+}
+
+// parsePlanChanges returns the changes of the given plan output, preferring the machine-readable change_summary
+// message if present. It returns false if the output contains no plan summary.
+func parsePlanChanges(output string) (PlanChanges, bool) {
+	output = log.RemoveAllASCISeq(output)
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var summary planChangeSummary
+		if err := json.Unmarshal([]byte(line), &summary); err != nil || summary.Type != "change_summary" {
+			continue
+		}
+
+		return PlanChanges{Add: summary.Changes.Add, Change: summary.Changes.Change, Destroy: summary.Changes.Remove}, true
+	}
+
+	if matches := planChangesRegex.FindAllStringSubmatch(output, -1); len(matches) > 0 {
+		match := matches[len(matches)-1]
+
+		// the regex guarantees the groups are numbers
+		add, _ := strconv.Atoi(match[1])
+		change, _ := strconv.Atoi(match[2])
+		destroy, _ := strconv.Atoi(match[3])
+
+		return PlanChanges{Add: add, Change: change, Destroy: destroy}, true
+	}
+
+	if noChangesRegex.MatchString(output) {
+		return PlanChanges{}, true
+	}
+
+	return PlanChanges{}, false
+}
+
+// aggregatePlans parses the plan output of every module of the summary that succeeded, setting its Plan, and returns
+// the totals. The modules whose output cannot be parsed are reported as unknown rather than failing the run.
+func (summary RunSummary) aggregatePlans() *PlanSummary {
+	planSummary := &PlanSummary{}
+
+	for path, result := range summary.Modules {
+		if result.Status != ModuleSucceeded {
+			continue
+		}
+
+		changes, ok := parsePlanChanges(result.Output)
+		if !ok {
+			planSummary.Unknown = append(planSummary.Unknown, path)
+			continue
+		}
+
+		result.Plan = &changes
+		planSummary.Add += changes.Add
+		planSummary.Change += changes.Change
+		planSummary.Destroy += changes.Destroy
+	}
+
+	sort.Strings(planSummary.Unknown)
+
+	return planSummary
+}
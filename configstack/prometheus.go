@@ -0,0 +1,110 @@
+package configstack
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+)
+
+// prometheusLabelEscaper escapes label values in the Prometheus exposition format.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheusMetrics writes the metrics of the run of the modules in the Prometheus text exposition format:
+//
+//	terragrunt_modules_total                  number of modules in the run
+//	terragrunt_modules_failed                 number of modules that ran and failed
+//	terragrunt_run_duration_seconds           time from the start of the first module to the end of the last one
+//	terragrunt_module_duration_seconds{path}  run time of each module that ran
+func (modules RunningModules) writePrometheusMetrics(writer io.Writer) error {
+	summary := modules.summary()
+	timeline := modules.timeline()
+
+	failed := 0
+
+	for _, result := range summary.Modules {
+		if result.Status == ModuleFailed {
+			failed++
+		}
+	}
+
+	var metrics strings.Builder
+
+	writeMetric := func(name, help string, samples ...string) {
+		fmt.Fprintf(&metrics, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+
+		for _, sample := range samples {
+			fmt.Fprintf(&metrics, "%s%s\n", name, sample)
+		}
+	}
+
+	writeMetric("terragrunt_modules_total", "Number of modules in the run.", " "+strconv.Itoa(len(modules)))
+	writeMetric("terragrunt_modules_failed", "Number of modules that ran and failed.", " "+strconv.Itoa(failed))
+	writeMetric("terragrunt_run_duration_seconds", "Duration of the run, from the start of the first module to the end of the last one.", " "+prometheusSeconds(timeline.DurationMs))
+
+	entries := timelineEntriesByPath(timeline.Modules)
+	samples := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		samples = append(samples, fmt.Sprintf(`{path="%s"} %s`, prometheusLabelEscaper.Replace(entry.Path), prometheusSeconds(entry.DurationMs)))
+	}
+
+	writeMetric("terragrunt_module_duration_seconds", "Duration of the run of each module that ran.", samples...)
+
+	if _, err := io.WriteString(writer, metrics.String()); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// writePrometheusTextfile writes the metrics of the run of the modules to the given file for the textfile collector
+// of the node exporter. The metrics are written to a temporary file that is then renamed, so that the collector never
+// reads a partial file.
+func (modules RunningModules) writePrometheusTextfile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.New(err)
+	}
+	defer os.Remove(file.Name()) //nolint:errcheck
+
+	if err := modules.writePrometheusMetrics(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.New(err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// timelineEntriesByPath returns the given timeline entries sorted by path.
+func timelineEntriesByPath(entries []TimelineEntry) []TimelineEntry {
+	sorted := append([]TimelineEntry{}, entries...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	return sorted
+}
+
+// prometheusSeconds formats a duration in milliseconds as seconds, the base unit of Prometheus.
+func prometheusSeconds(milliseconds int64) string {
+	return strconv.FormatFloat(float64(milliseconds)/1000, 'f', -1, 64) //nolint:mnd
+}
@@ -0,0 +1,101 @@
+package configstack
+
+import (
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// checkResourceCapacities returns an error for the first module, by path, that uses more of a resource than its
+// capacity in the ResourceCapacities of the options, as that module could never run.
+func (modules RunningModules) checkResourceCapacities(opts *options.TerragruntOptions) error {
+	if len(opts.ResourceCapacities) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		module := modules[path].Module
+
+		tags := make([]string, 0, len(module.ResourceTags))
+		for tag := range module.ResourceTags {
+			tags = append(tags, tag)
+		}
+
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			capacity, limited := opts.ResourceCapacities[tag]
+			if cost := module.ResourceTags[tag]; limited && cost > capacity {
+				return errors.New(ResourceCapacityExceededError{Module: module, Tag: tag, Cost: cost, Capacity: capacity})
+			}
+		}
+	}
+
+	return nil
+}
+
+// fitsResources returns true if the given module can run alongside the modules holding resources, without using more
+// of any resource than its capacity.
+func (scheduler *scheduler) fitsResources(module *RunningModule) bool {
+	for tag, cost := range module.Module.ResourceTags {
+		capacity, limited := scheduler.opts.ResourceCapacities[tag]
+		if limited && scheduler.resourceUsage[tag]+cost > capacity {
+			return false
+		}
+	}
+
+	return true
+}
+
+// markReadyForResources queues the given module, which holds its backend fence and account slot if any, for running,
+// unless the resources it uses are not available, in which case it waits for the modules using them to finish.
+func (scheduler *scheduler) markReadyForResources(module *RunningModule) {
+	if len(scheduler.opts.ResourceCapacities) > 0 && len(module.Module.ResourceTags) > 0 {
+		if !scheduler.fitsResources(module) {
+			scheduler.trace.record(traceEventQueued, module.Module.Path, "resources %v not available", module.Module.ResourceTags)
+			scheduler.resourceWaiting = append(scheduler.resourceWaiting, module)
+
+			return
+		}
+
+		for tag, cost := range module.Module.ResourceTags {
+			scheduler.resourceUsage[tag] += cost
+		}
+
+		scheduler.resourceHeld[module.Module.Path] = true
+	}
+
+	scheduler.enqueue(module)
+}
+
+// releaseResources makes the resources held by the given finished module available again, queuing the modules waiting
+// for resources that fit, in the order in which they became ready.
+func (scheduler *scheduler) releaseResources(module *RunningModule) {
+	if !scheduler.resourceHeld[module.Module.Path] {
+		return
+	}
+
+	delete(scheduler.resourceHeld, module.Module.Path)
+
+	for tag, cost := range module.Module.ResourceTags {
+		scheduler.resourceUsage[tag] -= cost
+	}
+
+	waiting := scheduler.resourceWaiting
+	scheduler.resourceWaiting = nil
+
+	for _, next := range waiting {
+		// a waiting module is skipped when a member of its group fails
+		if next.Status != Finished {
+			scheduler.markReadyForResources(next)
+		}
+	}
+}
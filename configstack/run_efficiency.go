@@ -0,0 +1,150 @@
+package configstack
+
+import (
+	"sort"
+	"time"
+)
+
+// RunEfficiency measures how well a run used its parallelism, to judge whether raising the parallelism or breaking
+// up serial chains of modules would make it faster.
+type RunEfficiency struct {
+	// Time from the start of the first module to the end of the last one.
+	WallClock time.Duration
+	// Number of modules that could run at the same time: the parallelism of the run, capped at the number of modules.
+	Parallelism int
+	// Sum of the durations of the modules divided by WallClock × Parallelism, from 0 to 1. A low utilization with a
+	// CriticalPathDuration close to the WallClock means that the run is bound by a serial chain of modules rather than
+	// by its parallelism.
+	Utilization float64
+	// Paths of the longest chain of dependent modules by total duration, from the first module to run to the last.
+	// Its duration is the minimum time of the run whatever the parallelism.
+	CriticalPath         []string
+	CriticalPathDuration time.Duration
+}
+
+// dependencyPaths returns the paths of the dependencies of every module, by path. Running the modules consumes their
+// dependencies, so they have to be taken before the run.
+func (modules RunningModules) dependencyPaths() map[string][]string {
+	dependencies := make(map[string][]string, len(modules))
+
+	for path, module := range modules {
+		for dependencyPath := range module.Dependencies {
+			dependencies[path] = append(dependencies[path], dependencyPath)
+		}
+
+		sort.Strings(dependencies[path])
+	}
+
+	return dependencies
+}
+
+// efficiency returns the efficiency of the run of the summary with the given parallelism, in which the modules had
+// the given dependencies. Only the modules that ran are taken into account.
+func (summary RunSummary) efficiency(dependencies map[string][]string, parallelism int) RunEfficiency {
+	efficiency := RunEfficiency{Parallelism: max(min(parallelism, len(summary.Modules)), 1)}
+
+	var (
+		start, end time.Time
+		busy       time.Duration
+	)
+
+	for _, result := range summary.Modules {
+		if result.StartTime.IsZero() {
+			continue
+		}
+
+		if start.IsZero() || result.StartTime.Before(start) {
+			start = result.StartTime
+		}
+
+		if result.EndTime.After(end) {
+			end = result.EndTime
+		}
+
+		busy += result.EndTime.Sub(result.StartTime)
+	}
+
+	if start.IsZero() {
+		return efficiency
+	}
+
+	efficiency.WallClock = end.Sub(start)
+	if efficiency.WallClock > 0 {
+		efficiency.Utilization = float64(busy) / (float64(efficiency.WallClock) * float64(efficiency.Parallelism))
+	}
+
+	efficiency.CriticalPath, efficiency.CriticalPathDuration = summary.criticalPath(dependencies)
+
+	return efficiency
+}
+
+// criticalPath returns the longest chain of dependent modules that ran by total duration, with its duration. Of the
+// chains of the same duration, the one with the lowest paths is returned.
+func (summary RunSummary) criticalPath(dependencies map[string][]string) ([]string, time.Duration) {
+	type chain struct {
+		// dependency preceding the last module of the chain, empty if the chain is a single module
+		previous string
+		duration time.Duration
+	}
+
+	// longest chain ending at each module, memoized
+	chains := map[string]chain{}
+
+	var longest func(path string) chain
+
+	longest = func(path string) chain {
+		if chain, found := chains[path]; found {
+			return chain
+		}
+
+		result := summary.Modules[path]
+		current := chain{duration: result.EndTime.Sub(result.StartTime)}
+
+		var longestDependency chain
+
+		for _, dependencyPath := range dependencies[path] {
+			if dependency, found := summary.Modules[dependencyPath]; !found || dependency.StartTime.IsZero() {
+				continue
+			}
+
+			if dependencyChain := longest(dependencyPath); longestDependency.previous == "" || dependencyChain.duration > longestDependency.duration {
+				longestDependency = chain{previous: dependencyPath, duration: dependencyChain.duration}
+			}
+		}
+
+		current.previous = longestDependency.previous
+		current.duration += longestDependency.duration
+		chains[path] = current
+
+		return current
+	}
+
+	paths := make([]string, 0, len(summary.Modules))
+
+	for path, result := range summary.Modules {
+		if !result.StartTime.IsZero() {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	var (
+		last     string
+		duration time.Duration
+	)
+
+	for _, path := range paths {
+		if chain := longest(path); last == "" || chain.duration > duration {
+			last, duration = path, chain.duration
+		}
+	}
+
+	var criticalPath []string
+
+	for path := last; path != ""; path = chains[path].previous {
+		criticalPath = append([]string{path}, criticalPath...)
+	}
+
+	return criticalPath, duration
+}
@@ -0,0 +1,163 @@
+package configstack
+
+import (
+	"context"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/terraform"
+)
+
+// ModuleResultStatus is the outcome of a module in a RunSummary.
+type ModuleResultStatus string
+
+const (
+	// ModuleSucceeded is the status of a module that finished successfully.
+	ModuleSucceeded ModuleResultStatus = "succeeded"
+	// ModuleFailed is the status of a module that ran and finished with an error.
+	ModuleFailed ModuleResultStatus = "failed"
+	// ModuleBlocked is the status of a module that never ran because one of its dependencies failed, or was skipped
+	// without RunDependentsOnSkippedDependency.
+	ModuleBlocked ModuleResultStatus = "blocked"
+	// ModuleSkipped is the status of a module that never ran for another reason, e.g. because a member of its group
+	// failed.
+	ModuleSkipped ModuleResultStatus = "skipped"
+)
+
+// ModuleResult is the outcome of a single module of a run.
+type ModuleResult struct {
+	Path   string
+	Status ModuleResultStatus
+	Err    error
+	// Times at which the module started and finished running. Both are zero if the module never ran.
+	StartTime time.Time
+	EndTime   time.Time
+	// Combined stdout and stderr of the module.
+	Output string
+	// Changes of the plan of the module, nil if the run was not a plan or its output could not be parsed.
+	Plan *PlanChanges
+	// Labels of the module.
+	Labels map[string]string
+}
+
+// RunSummary is the outcome of running a set of modules, with the result of every module that was part of the run,
+// by path.
+type RunSummary struct {
+	Modules map[string]*ModuleResult
+	// Aggregate of the plans of the modules that succeeded, nil if the run was not a plan.
+	Plan *PlanSummary
+	// Diagnostics of the dependencies that could not be resolved by the modules that failed.
+	DependencyDiagnostics []DependencyDiagnostic
+	// How well the run used its parallelism.
+	Efficiency RunEfficiency
+	// Modules that took longer than usual, with a DurationHistoryFile and a DurationRegressionThreshold.
+	DurationRegressions []DurationRegression
+}
+
+// RunModulesWithSummary runs the modules like RunModules, and returns the summary of the run along with its error.
+func (modules TerraformModules) RunModulesWithSummary(ctx context.Context, opts *options.TerragruntOptions, parallelism int) (RunSummary, error) {
+	runningModules, err := modules.ToRunningModules(NormalOrder)
+	if err != nil {
+		return RunSummary{}, err
+	}
+
+	dependencies := runningModules.dependencyPaths()
+
+	err = runningModules.runModules(ctx, opts, parallelism)
+
+	summary := runningModules.summary()
+	summary.DependencyDiagnostics = summary.dependencyDiagnostics()
+	summary.Efficiency = summary.efficiency(dependencies, parallelism)
+
+	if opts.TerraformCommand == terraform.CommandNamePlan {
+		summary.Plan = summary.aggregatePlans()
+	}
+
+	return summary, err
+}
+
+// ModuleOutput returns the combined stdout and stderr captured from the module with the given path during the run, or
+// an empty string if the module was not part of the run.
+func (summary RunSummary) ModuleOutput(path string) string {
+	if result, found := summary.Modules[path]; found {
+		return result.Output
+	}
+
+	return ""
+}
+
+// summary returns the summary of the modules once they have run.
+func (modules RunningModules) summary() RunSummary {
+	summary := RunSummary{Modules: make(map[string]*ModuleResult, len(modules))}
+
+	for path, module := range modules {
+		summary.Modules[path] = &ModuleResult{
+			Path:      path,
+			Status:    module.resultStatus(),
+			Err:       module.Err,
+			StartTime: module.StartTime,
+			EndTime:   module.EndTime,
+			Output:    module.output.String(),
+			Labels:    module.Module.Labels,
+		}
+	}
+
+	summary.DurationRegressions = modules.durationRegressions()
+
+	return summary
+}
+
+// resultStatus returns the status of the module in the summary, once it has finished.
+func (module *RunningModule) resultStatus() ModuleResultStatus {
+	var (
+		dependencyErr        ProcessingModuleDependencyError
+		skippedDependencyErr SkippedDependencyError
+	)
+
+	switch {
+	case module.Err == nil:
+		return ModuleSucceeded
+	case !module.StartTime.IsZero():
+		return ModuleFailed
+	case errors.As(module.Err, &dependencyErr), errors.As(module.Err, &skippedDependencyErr):
+		return ModuleBlocked
+	default:
+		return ModuleSkipped
+	}
+}
+
+// FailureSubgraph returns the modules that failed in the run of the given summary together with the modules they
+// blocked, leaving out the modules that succeeded or were skipped for other reasons, e.g. for a post-mortem graph with
+// WriteDot. The returned modules are copies whose dependencies are restricted to the subgraph, so that only the
+// failure chains are drawn, in the order in which they appear in the given modules.
+func (modules TerraformModules) FailureSubgraph(summary RunSummary) TerraformModules {
+	failureModules := TerraformModules{}
+
+	for _, module := range modules {
+		if result, found := summary.Modules[module.Path]; found && (result.Status == ModuleFailed || result.Status == ModuleBlocked) {
+			failureModules = append(failureModules, module)
+		}
+	}
+
+	subgraph := failureModules.clone()
+
+	inSubgraph := make(map[*TerraformModule]bool, len(subgraph))
+	for _, module := range subgraph {
+		inSubgraph[module] = true
+	}
+
+	for _, module := range subgraph {
+		dependencies := TerraformModules{}
+
+		for _, dependency := range module.Dependencies {
+			if inSubgraph[dependency] {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+
+		module.Dependencies = dependencies
+	}
+
+	return subgraph
+}
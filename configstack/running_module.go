@@ -3,22 +3,32 @@ package configstack
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
-	"sync"
+	"strings"
+	"time"
 
+	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/internal/errors"
 	"github.com/gruntwork-io/terragrunt/options"
-	"github.com/gruntwork-io/terragrunt/telemetry"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
+	"github.com/gruntwork-io/terragrunt/shell"
 	"github.com/gruntwork-io/terragrunt/terraform"
+	"github.com/gruntwork-io/terragrunt/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	Waiting ModuleStatus = iota
 	Running
 	Finished
-	channelSize = 1000 // Use a huge buffer to ensure senders are never blocked
 )
 
 const (
@@ -27,6 +37,9 @@ const (
 	IgnoreOrder
 )
 
+// parallelismExcessFactor is how many times the number of modules or CPUs the parallelism must exceed to be warned about.
+const parallelismExcessFactor = 10
+
 // ModuleStatus represents the status of a module that we are
 // trying to apply as part of the apply-all or destroy-all command
 type ModuleStatus int
@@ -40,10 +53,34 @@ type RunningModule struct {
 	Module         *TerraformModule
 	Status         ModuleStatus
 	Err            error
-	DependencyDone chan *RunningModule
 	Dependencies   map[string]*RunningModule
 	NotifyWhenDone []*RunningModule
 	FlagExcluded   bool
+	// Whether the module was skipped rather than run, by returning SkipModule.
+	Skipped bool
+	// Whether the module was not run because the WithAlreadyAppliedFunc of its stack reported it as already applied.
+	AlreadyApplied bool
+	// Times at which the module started and finished running. Both are zero if the module never ran.
+	StartTime time.Time
+	EndTime   time.Time
+	// Combined stdout and stderr of the module.
+	output moduleOutput
+	// The OutputFocus of the options of the run, if any.
+	outputFocus *options.OutputFocus
+	// With DeferErrorOutput, the stderr of the module, written in the error report of the run instead of as it runs.
+	deferredErrors *moduleOutput
+	// The CIAnnotations of the options of the run, if any.
+	ciAnnotations string
+	// With a DurationHistoryFile, the regression of the duration of the module over its last successful run, if any.
+	durationRegression *DurationRegression
+	// The span recorded for the module once it has finished, linked to from the spans of its dependents.
+	spanContext trace.SpanContext
+	// With PropagateChangesToDependents, whether the outputs of the module changed during its run.
+	outputsChanged bool
+	// With DependencyOutputsAsEnv, the outputs of the module once it has run, as the env vars passed to its dependents.
+	outputEnv map[string]string
+	// The outputEnv of the dependencies of the module that have finished, by their path.
+	dependencyOutputEnv map[string]map[string]string
 }
 
 // Create a new RunningModule struct for the given module. This will initialize all fields to reasonable defaults,
@@ -53,84 +90,269 @@ func newRunningModule(module *TerraformModule) *RunningModule {
 	return &RunningModule{
 		Module:         module,
 		Status:         Waiting,
-		DependencyDone: make(chan *RunningModule, channelSize),
 		Dependencies:   map[string]*RunningModule{},
 		NotifyWhenDone: []*RunningModule{},
 		FlagExcluded:   module.FlagExcluded,
 	}
 }
 
-// Run a module once all of its dependencies have finished executing.
-func (module *RunningModule) runModuleWhenReady(ctx context.Context, opts *options.TerragruntOptions, semaphore chan struct{}) {
-	err := telemetry.Telemetry(ctx, opts, "wait_for_module_ready", map[string]interface{}{
-		"path":             module.Module.Path,
-		"terraformCommand": module.Module.TerragruntOptions.TerraformCommand,
-	}, func(childCtx context.Context) error {
-		return module.waitForDependencies()
-	})
+// ranSuccessfully returns true if the module ran and finished without an error, as opposed to being skipped or assumed
+// already applied.
+func (module *RunningModule) ranSuccessfully() bool {
+	return module.Err == nil && !module.StartTime.IsZero() && !module.Skipped && !module.AlreadyApplied &&
+		!module.Module.Barrier && !module.Module.AssumeAlreadyApplied
+}
+
+func (module *RunningModule) runTerragrunt(ctx context.Context, opts *options.TerragruntOptions) (err error) {
+	opts.Logger.Debugf("Running %s", module.Module.Path)
+
+	writer := NewModuleWriter(opts.Writer)
+	writer.capture = &module.output
+	writer.focus = module.outputFocus
+	writer.path = module.Module.Path
+	opts.Writer = writer
+
+	if module.ciAnnotations == options.CIAnnotationsBuildkite {
+		// a collapsed group, expanded below if the module fails
+		writer.writeMarker(fmt.Sprintf("--- %s\n", module.Module.Path))
+	}
+
+	// stderr is captured for this run only, so that the writers don't pile up when the module runs again
+	errWriter := opts.ErrWriter
+	if module.deferredErrors != nil {
+		opts.ErrWriter = io.MultiWriter(module.deferredErrors, &module.output)
+	} else if module.ciAnnotations != "" {
+		// buffered along with stdout, so that it ends up in the group of the module
+		opts.ErrWriter = writer
+	} else if module.outputFocus != nil {
+		opts.ErrWriter = io.MultiWriter(focusedWriter{out: errWriter, focus: module.outputFocus, path: module.Module.Path}, &module.output)
+	} else {
+		opts.ErrWriter = io.MultiWriter(errWriter, &module.output)
+	}
 
-	semaphore <- struct{}{} // Add one to the buffered channel. Will block if parallelism limit is met
 	defer func() {
-		<-semaphore // Remove one from the buffered channel
+		if err != nil && module.ciAnnotations == options.CIAnnotationsBuildkite {
+			writer.writeMarker("^^^ +++\n")
+		}
+
+		module.Module.flushOutput(writer) //nolint:errcheck
+		opts.ErrWriter = errWriter
 	}()
 
-	if err == nil {
-		err = telemetry.Telemetry(ctx, opts, "run_module", map[string]interface{}{
-			"path":             module.Module.Path,
-			"terraformCommand": module.Module.TerragruntOptions.TerraformCommand,
-		}, func(childCtx context.Context) error {
-			return module.runNow(ctx, opts)
-		})
+	return opts.RunTerragrunt(ctx, opts)
+}
+
+// moduleTimeoutGracePeriod is how long a module that timed out is given to exit once it is cancelled, before the run
+// moves on without waiting for it.
+const moduleTimeoutGracePeriod = 30 * time.Second
+
+// runTerragruntWithTimeout runs the module, failing it if it doesn't finish within the ModuleTimeout of the given
+// options, in which case it is cancelled and waited for during moduleTimeoutGracePeriod. The timeout is measured with
+// the Clock of the given options.
+func (module *RunningModule) runTerragruntWithTimeout(ctx context.Context, opts *options.TerragruntOptions) error {
+	if opts.ModuleTimeout <= 0 {
+		return module.runTerragrunt(ctx, opts)
 	}
 
-	module.moduleFinished(err)
+	clock := clockFrom(opts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		var err error
+
+		defer func() {
+			errCh <- err
+		}()
+
+		defer module.Module.recoverPanic(&err)
+
+		err = module.runTerragrunt(ctx, opts)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-clock.After(opts.ModuleTimeout):
+	}
+
+	// terraform may still hold the state lock until it exits, so the module is only done, letting its dependents run,
+	// once it has exited or the grace period is over
+	cancel()
+
+	select {
+	case <-errCh:
+	case <-clock.After(moduleTimeoutGracePeriod):
+		opts.Logger.Warnf("Module %s did not exit within %s of being cancelled after its timeout of %s, it may still be running", module.Module.Path, moduleTimeoutGracePeriod, opts.ModuleTimeout)
+	}
+
+	return errors.New(ModuleTimeoutError{Module: module.Module, Timeout: opts.ModuleTimeout})
 }
 
-// Wait for all of this modules dependencies to finish executing. Return an error if any of those dependencies complete
-// with an error. Return immediately if this module has no dependencies.
-func (module *RunningModule) waitForDependencies() error {
-	module.Module.TerragruntOptions.Logger.Debugf("Module %s must wait for %d dependencies to finish", module.Module.Path, len(module.Dependencies))
-
-	for len(module.Dependencies) > 0 {
-		doneDependency := <-module.DependencyDone
-		delete(module.Dependencies, doneDependency.Module.Path)
-
-		if doneDependency.Err != nil {
-			if module.Module.TerragruntOptions.IgnoreDependencyErrors {
-				module.Module.TerragruntOptions.Logger.Errorf("Dependency %s of module %s just finished with an error. Module %s will have to return an error too. However, because of --terragrunt-ignore-dependency-errors, module %s will run anyway.", doneDependency.Module.Path, module.Module.Path, module.Module.Path, module.Module.Path)
-			} else {
-				module.Module.TerragruntOptions.Logger.Errorf("Dependency %s of module %s just finished with an error. Module %s will have to return an error too.", doneDependency.Module.Path, module.Module.Path, module.Module.Path)
-				return ProcessingModuleDependencyError{module.Module, doneDependency.Module, doneDependency.Err}
-			}
-		} else {
-			module.Module.TerragruntOptions.Logger.Debugf("Dependency %s of module %s just finished successfully. Module %s must wait on %d more dependencies.", doneDependency.Module.Path, module.Module.Path, module.Module.Path, len(module.Dependencies))
-		}
+// optionsWithModuleOverrides returns the options to run the module with: its TerragruntOptions, with the retry
+// settings and the terraform parallelism of the module applied if it has any.
+func (module *RunningModule) optionsWithModuleOverrides() (*options.TerragruntOptions, error) {
+	if !module.Module.hasRetryOverrides() && !module.Module.hasTerraformParallelism() {
+		return module.Module.TerragruntOptions, nil
 	}
 
-	return nil
+	opts, err := module.Module.TerragruntOptions.Clone(module.Module.TerragruntOptions.TerragruntConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.AutoRetry = true
+
+	if module.Module.RetryAttempts > 0 {
+		opts.RetryMaxAttempts = module.Module.RetryAttempts
+	}
+
+	if module.Module.RetrySleep > 0 {
+		opts.RetrySleepInterval = module.Module.RetrySleep
+	}
+
+	if module.Module.RetryableErrors != nil {
+		opts.RetryableErrors = util.CloneStringList(module.Module.RetryableErrors)
+	}
+
+	if module.Module.hasTerraformParallelism() {
+		opts.TerraformCliArgs = withTerraformParallelism(opts.TerraformCliArgs, module.Module.TerraformParallelism)
+	}
+
+	return opts, nil
 }
 
-func (module *RunningModule) runTerragrunt(ctx context.Context, opts *options.TerragruntOptions) error {
-	opts.Logger.Debugf("Running %s", module.Module.Path)
-	opts.Writer = NewModuleWriter(opts.Writer)
+// withTerraformParallelism returns the given terraform arguments with a -parallelism flag of the given value right
+// after the command, replacing the -parallelism flag already there if any.
+func withTerraformParallelism(args []string, parallelism int) []string {
+	withParallelism := []string{args[0], fmt.Sprintf("%s=%d", terraformParallelismFlag, parallelism)}
 
-	defer module.Module.FlushOutput() //nolint:errcheck
+	for _, arg := range args[1:] {
+		if arg != terraformParallelismFlag && !strings.HasPrefix(arg, terraformParallelismFlag+"=") {
+			withParallelism = append(withParallelism, arg)
+		}
+	}
 
-	return opts.RunTerragrunt(ctx, opts)
+	return withParallelism
 }
 
-// Run a module right now by executing the RunTerragrunt command of its TerragruntOptions field.
+// Run a module right now by executing the RunTerragrunt command of its TerragruntOptions field. The Status of the module
+// is set to Running by the scheduler when it dispatches the module, as only the scheduler goroutine accesses it.
 func (module *RunningModule) runNow(ctx context.Context, rootOptions *options.TerragruntOptions) error {
-	module.Status = Running
+	if module.Module.Barrier {
+		module.Module.TerragruntOptions.Logger.Debugf("Module %s is a barrier, all of its dependencies are done", module.Module.Path)
+		return nil
+	}
 
 	if module.Module.AssumeAlreadyApplied {
 		module.Module.TerragruntOptions.Logger.Debugf("Assuming module %s has already been applied and skipping it", module.Module.Path)
 		return nil
 	} else {
-		if err := module.runTerragrunt(ctx, module.Module.TerragruntOptions); err != nil {
+		if module.Module.Stack != nil && module.Module.alreadyApplied != nil {
+			applied, err := module.Module.alreadyApplied(module.Module)
+			if err != nil {
+				return err
+			}
+
+			if applied {
+				module.Module.TerragruntOptions.Logger.Debugf("Module %s has already been applied, skipping it", module.Module.Path)
+				module.AlreadyApplied = true
+
+				return nil
+			}
+		}
+
+		if hook := module.Module.TerragruntOptions.BeforeModuleHook; hook != nil {
+			if err := hook(ctx, module.Module.TerragruntOptions); err != nil {
+				if errors.Is(err, SkipModule) {
+					module.Module.TerragruntOptions.Logger.Infof("Module %s was skipped by the before-module hook", module.Module.Path)
+					module.Skipped = true
+
+					return nil
+				}
+
+				return err
+			}
+		}
+
+		if delay := rootOptions.SyntheticModuleDelay; delay > 0 {
+			module.Module.TerragruntOptions.Logger.Debugf("Simulating a run of %s for module %s", delay, module.Module.Path)
+			clockFrom(rootOptions).Sleep(delay)
+
+			return nil
+		}
+
+		module.outputFocus = rootOptions.OutputFocus
+		module.ciAnnotations = rootOptions.CIAnnotations
+
+		if rootOptions.DeferErrorOutput {
+			module.deferredErrors = &moduleOutput{}
+		}
+
+		runOptions, err := module.optionsWithModuleOverrides()
+		if err != nil {
 			return err
 		}
 
+		if rootOptions.DependencyOutputsAsEnv {
+			if runOptions, err = module.optionsWithDependencyOutputs(runOptions); err != nil {
+				return err
+			}
+		}
+
+		if rootOptions.IsolateWorkdir || module.Module.featureEnabled(FeatureIsolateWorkdir) {
+			isolatedOptions, cleanup, err := module.Module.isolatedOptions(runOptions, rootOptions.IsolateWorkdirIgnore)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			runOptions = isolatedOptions
+		}
+
+		if rootOptions.PrefixIncludeLevel || module.Module.featureEnabled(FeaturePrefixIncludeLevel) {
+			levelOptions, err := runOptions.Clone(runOptions.TerragruntConfigPath)
+			if err != nil {
+				return err
+			}
+
+			levelOptions.Logger = levelOptions.Logger.WithField(format.PrefixLevelKeyName, TerraformModules{module.Module}.Levels()[module.Module.Path])
+			runOptions = levelOptions
+		}
+
+		if rootOptions.PropagateChangesToDependents {
+			module.readOutputHash(ctx)
+		}
+
+		err = module.runTerragruntWithTimeout(ctx, runOptions)
+		if !errors.Is(err, SkipModule) {
+			// even a failed run may have changed the outputs of the module, so they are read again by its dependents
+			config.InvalidateOutputCache(module.Module.TerragruntOptions.TerragruntConfigPath)
+		}
+
+		if err != nil {
+			if errors.Is(err, SkipModule) {
+				module.Module.TerragruntOptions.Logger.Infof("Module %s was skipped", module.Module.Path)
+				module.Skipped = true
+
+				return nil
+			}
+
+			exitCode, exitCodeErr := util.GetExitCode(err)
+			if exitCodeErr != nil {
+				return err
+			}
+
+			if !slices.Contains(module.Module.TerragruntOptions.SuccessExitCodes, exitCode) {
+				return ModuleExitError{Module: module.Module, Err: err, Code: exitCode}
+			}
+
+			module.Module.TerragruntOptions.Logger.Debugf("Module %s finished with an exit code configured as a success: %v", module.Module.Path, err)
+		}
+
 		// convert terragrunt output to json
 		if module.Module.outputJSONFile(module.Module.TerragruntOptions) != "" {
 			jsonOptions, err := module.Module.TerragruntOptions.Clone(module.Module.TerragruntOptions.TerragruntConfigPath)
@@ -162,24 +384,57 @@ func (module *RunningModule) runNow(ctx context.Context, rootOptions *options.Te
 			}
 		}
 
+		if rootOptions.PropagateChangesToDependents {
+			if err := module.propagateOutputChanges(ctx); err != nil {
+				return err
+			}
+		}
+
+		if rootOptions.DependencyOutputsAsEnv && len(module.NotifyWhenDone) > 0 {
+			if module.outputEnv, err = module.Module.outputEnv(ctx); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}
 }
 
-// Record that a module has finished executing and notify all of this module's dependencies
-func (module *RunningModule) moduleFinished(moduleErr error) {
-	if moduleErr == nil {
-		module.Module.TerragruntOptions.Logger.Debugf("Module %s has finished successfully!", module.Module.Path)
-	} else {
-		module.Module.TerragruntOptions.Logger.Errorf("Module %s has finished with an error", module.Module.Path)
+// readOutputHash reads the OutputHash of the module right before it runs, if it has dependents, for
+// propagateOutputChanges to compare with once it has run. The hash is left empty if the outputs can't be read, e.g.
+// because the module was never applied.
+func (module *RunningModule) readOutputHash(ctx context.Context) {
+	if len(module.NotifyWhenDone) == 0 {
+		return
+	}
+
+	outputHash, err := module.Module.outputHash(ctx)
+	if err != nil {
+		module.Module.TerragruntOptions.Logger.Debugf("Could not read the outputs of module %s before it runs, changes to its outputs won't be propagated: %v", module.Module.Path, err)
 	}
 
-	module.Status = Finished
-	module.Err = moduleErr
+	module.Module.OutputHash = outputHash
+}
+
+// propagateOutputChanges hashes the terraform outputs of the module once it has run and records whether they changed
+// since the OutputHash read before it ran. The scheduler then flags the dependents that are assumed already applied to
+// run, so that they pick up the new outputs. Nothing is propagated if the outputs couldn't be read before the run, as
+// there is nothing to compare with.
+func (module *RunningModule) propagateOutputChanges(ctx context.Context) error {
+	if len(module.NotifyWhenDone) == 0 {
+		return nil
+	}
 
-	for _, toNotify := range module.NotifyWhenDone {
-		toNotify.DependencyDone <- module
+	outputHash, err := module.Module.outputHash(ctx)
+	if err != nil {
+		return err
 	}
+
+	previousHash := module.Module.OutputHash
+	module.Module.OutputHash = outputHash
+	module.outputsChanged = previousHash != "" && previousHash != outputHash
+
+	return nil
 }
 
 type RunningModules map[string]*RunningModule
@@ -275,6 +530,80 @@ func (modules RunningModules) crossLinkDependencies(dependencyOrder DependencyOr
 	return modules, nil
 }
 
+// linkPhases makes every module depend on all the modules of the earlier phases, so that the modules of a phase only
+// start once all the modules of the previous phases are done. The modules that run last form a phase of their own
+// after all the others. The phases run from the lowest to the highest one, or the other way around when
+// dependencyOrder is ReverseOrder. Returns an error if a module depends on a module of a later phase, as that
+// dependency could never be satisfied.
+func (modules RunningModules) linkPhases(dependencyOrder DependencyOrder) (RunningModules, error) {
+	lastPhase := math.MinInt
+
+	for _, module := range modules {
+		if !module.Module.RunLast {
+			lastPhase = max(lastPhase, module.Module.Phase)
+		}
+	}
+
+	phaseOf := func(module *RunningModule) int {
+		phase := module.Module.Phase
+		if module.Module.RunLast {
+			phase = lastPhase + 1
+		}
+
+		if dependencyOrder == ReverseOrder {
+			return -phase
+		}
+
+		return phase
+	}
+
+	phases := map[int]bool{}
+
+	for _, module := range modules {
+		phases[phaseOf(module)] = true
+
+		for _, dependency := range module.Dependencies {
+			if phaseOf(dependency) <= phaseOf(module) {
+				continue
+			}
+
+			// the errors refer to the dependencies as declared, which are reversed in the running graph
+			dependent, declaredDependency := module.Module, dependency.Module
+			if dependencyOrder == ReverseOrder {
+				dependent, declaredDependency = declaredDependency, dependent
+			}
+
+			if declaredDependency.RunLast && !dependent.RunLast {
+				return modules, errors.New(RunLastDependencyError{Module: dependent, Dependency: declaredDependency})
+			}
+
+			return modules, errors.New(PhaseDependencyError{Module: dependent, Dependency: declaredDependency})
+		}
+	}
+
+	// Nothing to link if all the modules are in the same phase
+	if len(phases) <= 1 {
+		return modules, nil
+	}
+
+	for _, module := range modules {
+		for _, earlierModule := range modules {
+			if phaseOf(earlierModule) >= phaseOf(module) {
+				continue
+			}
+
+			if _, isDependency := module.Dependencies[earlierModule.Module.Path]; isDependency {
+				continue
+			}
+
+			module.Dependencies[earlierModule.Module.Path] = earlierModule
+			earlierModule.NotifyWhenDone = append(earlierModule.NotifyWhenDone, module)
+		}
+	}
+
+	return modules, nil
+}
+
 // RemoveFlagExcluded returns a cleaned-up map that only contains modules and
 // dependencies that should not be excluded
 func (modules RunningModules) RemoveFlagExcluded() map[string]*RunningModule {
@@ -286,7 +615,6 @@ func (modules RunningModules) RemoveFlagExcluded() map[string]*RunningModule {
 			finalModules[key] = &RunningModule{
 				Module:         module.Module,
 				Dependencies:   make(map[string]*RunningModule),
-				DependencyDone: module.DependencyDone,
 				Err:            module.Err,
 				NotifyWhenDone: module.NotifyWhenDone,
 				Status:         module.Status,
@@ -304,40 +632,242 @@ func (modules RunningModules) RemoveFlagExcluded() map[string]*RunningModule {
 	return finalModules
 }
 
+// stack returns the stack the modules are run as part of, or nil if none of them has one.
+func (modules RunningModules) stack() *Stack {
+	for _, module := range modules {
+		if module.Module.Stack != nil {
+			return module.Module.Stack
+		}
+	}
+
+	return nil
+}
+
 // Run the given map of module path to runningModule. To "run" a module, execute the RunTerragrunt command in its
 // TerragruntOptions object. The modules will be executed in an order determined by their inter-dependencies, using
-// as much concurrency as possible.
+// as much concurrency as possible, but never more than parallelism modules at a time.
 func (modules RunningModules) runModules(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
-	var (
-		waitGroup sync.WaitGroup
-		semaphore = make(chan struct{}, parallelism) // Make a semaphore from a buffered channel
-	)
+	if err := modules.checkParallelism(opts, parallelism); err != nil {
+		return err
+	}
 
-	for _, module := range modules {
-		waitGroup.Add(1)
+	switch opts.CIAnnotations {
+	case "", options.CIAnnotationsBuildkite:
+	default:
+		return errors.New(InvalidCIAnnotationsError(opts.CIAnnotations))
+	}
+
+	if err := modules.checkMaxModules(opts); err != nil {
+		return err
+	}
+
+	if err := modules.checkResourceCapacities(opts); err != nil {
+		return err
+	}
 
-		go func(module *RunningModule) {
-			defer waitGroup.Done()
+	var history durationHistory
+
+	if opts.DurationHistoryFile != "" {
+		var err error
+		if history, err = readDurationHistory(opts.DurationHistoryFile); err != nil {
+			opts.Logger.Errorf("Failed to read the duration history from %s, durations are not compared: %v", opts.DurationHistoryFile, err)
+		}
+
+		if err := modules.checkEstimatedDuration(opts, history, parallelism); err != nil {
+			return err
+		}
+	}
+
+	if opts.PreflightSources || opts.SkipUnreachableSources {
+		if err := modules.checkSources(ctx, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.ValidateBeforeRun {
+		if err := modules.validate(ctx, opts, parallelism); err != nil {
+			return err
+		}
+	}
 
-			module.runModuleWhenReady(ctx, opts, semaphore)
-		}(module)
+	if opts.DumpEffectiveOptions {
+		modules.logEffectiveOptions(parallelism)
 	}
 
-	waitGroup.Wait()
+	if len(opts.WarmUpCommand) > 0 {
+		opts.Logger.Infof("Running warm-up command %v in %s", opts.WarmUpCommand, opts.WorkingDir)
+
+		if err := shell.RunShellCommand(ctx, opts, opts.WarmUpCommand[0], opts.WarmUpCommand[1:]...); err != nil {
+			return errors.New(WarmUpCommandError{Command: opts.WarmUpCommand, Err: err})
+		}
+	}
+
+	ctx, runSpan := tracerFrom(opts).Start(ctx, "run_modules", trace.WithAttributes(attribute.Int("modules", len(modules))))
+	defer runSpan.End()
+
+	scheduler := newScheduler(ctx, opts, modules, parallelism)
+
+	if opts.EventSocket != "" {
+		events, err := listenEventSocket(opts.EventSocket, opts.Logger)
+		if err != nil {
+			return err
+		}
+
+		scheduler.events = events
+	}
+
+	var dependencies map[string][]string
+
+	if opts.FoldedStacksFile != "" {
+		dependencies = modules.dependencyPaths()
+	}
+
+	scheduler.run()
+
+	if scheduler.events != nil {
+		scheduler.events.close()
+	}
 
-	return modules.collectErrors()
+	if opts.DurationHistoryFile != "" {
+		modules.flagDurationRegressions(opts, history)
+		history.update(modules)
+
+		if err := history.write(opts.DurationHistoryFile); err != nil {
+			opts.Logger.Errorf("Failed to write the duration history to %s: %v", opts.DurationHistoryFile, err)
+		}
+	}
+
+	if opts.RunTimelineFile != "" {
+		if err := modules.writeTimeline(opts.RunTimelineFile); err != nil {
+			opts.Logger.Errorf("Failed to write the run timeline to %s: %v", opts.RunTimelineFile, err)
+		}
+	}
+
+	if opts.ModuleHashFile != "" {
+		if err := modules.recordModuleHashes(opts.ModuleHashFile); err != nil {
+			opts.Logger.Errorf("Failed to write the module hashes to %s: %v", opts.ModuleHashFile, err)
+		}
+	}
+
+	if opts.FoldedStacksFile != "" {
+		if err := modules.writeFoldedStacks(opts.FoldedStacksFile, dependencies); err != nil {
+			opts.Logger.Errorf("Failed to write the folded stacks to %s: %v", opts.FoldedStacksFile, err)
+		}
+	}
+
+	if opts.JUnitReportFile != "" {
+		if err := modules.writeJUnitReport(opts.JUnitReportFile); err != nil {
+			opts.Logger.Errorf("Failed to write the JUnit report to %s: %v", opts.JUnitReportFile, err)
+		}
+	}
+
+	if opts.PrometheusTextfile != "" {
+		if err := modules.writePrometheusTextfile(opts.PrometheusTextfile); err != nil {
+			opts.Logger.Errorf("Failed to write the Prometheus metrics to %s: %v", opts.PrometheusTextfile, err)
+		}
+	}
+
+	if opts.RunSummaryFile != "" {
+		if err := modules.summary().WriteFile(opts.RunSummaryFile); err != nil {
+			opts.Logger.Errorf("Failed to write the run summary to %s: %v", opts.RunSummaryFile, err)
+		}
+	}
+
+	if opts.DeferErrorOutput {
+		if err := modules.writeErrorReport(opts.ErrWriter); err != nil {
+			opts.Logger.Errorf("Failed to write the error report: %v", err)
+		}
+	}
+
+	err := modules.collectErrors(opts.MaxErrorEntries)
+
+	stack := modules.stack()
+	if stack == nil || stack.exitCode == nil {
+		return err
+	}
+
+	if code := stack.exitCode(modules.summary()); code != 0 {
+		return errors.New(RunExitCodeError{Code: code, Err: err})
+	}
+
+	if err != nil {
+		opts.Logger.Debugf("Some modules failed, but the exit code of the run is 0: %v", err)
+	}
+
+	return nil
+}
+
+// checkParallelism returns an error if the given parallelism is below 1, and warns if it vastly exceeds the number of
+// modules or CPUs, in which case the extra parallelism has no effect. The default parallelism, meaning unlimited, is
+// not warned about.
+func (modules RunningModules) checkParallelism(opts *options.TerragruntOptions, parallelism int) error {
+	if parallelism < 1 {
+		return errors.New(InvalidParallelismError(parallelism))
+	}
+
+	if parallelism == options.DefaultParallelism {
+		return nil
+	}
+
+	if len(modules) > 0 && parallelism > len(modules)*parallelismExcessFactor {
+		opts.Logger.Warnf("The parallelism of %d is far above the %d modules to run, at most %d modules will run at once", parallelism, len(modules), len(modules))
+	}
+
+	if cpus := runtime.NumCPU(); parallelism > cpus*parallelismExcessFactor {
+		opts.Logger.Warnf("The parallelism of %d is far above the %d CPUs of this machine, which may be overloaded", parallelism, cpus)
+	}
+
+	return nil
+}
+
+// checkMaxModules returns an error if there are more modules to run than the MaxModules of the options, as a safety
+// rail against running an unexpectedly large stack. The modules assumed already applied and the barriers don't count,
+// as they don't run anything.
+func (modules RunningModules) checkMaxModules(opts *options.TerragruntOptions) error {
+	if opts.MaxModules <= 0 {
+		return nil
+	}
+
+	count := 0
+
+	for _, module := range modules {
+		if !module.Module.AssumeAlreadyApplied && !module.Module.Barrier {
+			count++
+		}
+	}
+
+	if count > opts.MaxModules {
+		return errors.New(TooManyModulesError{Count: count, Max: opts.MaxModules})
+	}
+
+	return nil
 }
 
 // Collect the errors from the given modules and return a single error object to represent them, or nil if no errors
-// occurred
-func (modules RunningModules) collectErrors() error {
+// occurred. The errors are in the order of the paths of the modules. If maxEntries is positive, the message of the
+// returned error lists at most that many errors, see TruncatedErrors.
+func (modules RunningModules) collectErrors(maxEntries int) error {
 	var errs *errors.MultiError
 
-	for _, module := range modules {
-		if module.Err != nil {
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		var limitErr ApplyLimitReachedError
+
+		// the modules left for a later run by the ApplyLimit did not fail
+		if module := modules[path]; module.Err != nil && !errors.As(module.Err, &limitErr) {
 			errs = errs.Append(module.Err)
 		}
 	}
 
+	if maxEntries > 0 && errs != nil && errs.Len() > maxEntries {
+		return TruncatedErrors{Errors: errs.WrappedErrors(), MaxEntries: maxEntries}
+	}
+
 	return errs.ErrorOrNil()
 }
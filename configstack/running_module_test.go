@@ -1,6 +1,7 @@
 package configstack_test
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/gruntwork-io/terragrunt/config"
@@ -405,6 +406,34 @@ func TestToRunningModulesMultipleModulesWithAndWithoutDependenciesReverseOrder(t
 	testToRunningModules(t, modules, configstack.ReverseOrder, expected)
 }
 
+func TestToRunningModulesReverseOrderMatchesReversed(t *testing.T) {
+	t.Parallel()
+
+	modules := createGraphTestModules()
+
+	runningModules, err := modules.ToRunningModules(configstack.ReverseOrder)
+	require.NoError(t, err)
+
+	edges := map[string][]string{}
+
+	for path, runningModule := range runningModules {
+		dependencies := []string{}
+		for dependencyPath := range runningModule.Dependencies {
+			dependencies = append(dependencies, dependencyPath)
+		}
+
+		sort.Strings(dependencies)
+		edges[path] = dependencies
+	}
+
+	assert.Equal(t, dependencyEdges(modules.Reversed()), edges)
+
+	// the running graph is built over the original modules, so that the changes made during the run reach them
+	for _, module := range modules {
+		assert.Same(t, module, runningModules[module.Path].Module)
+	}
+}
+
 func TestToRunningModulesMultipleModulesWithAndWithoutDependenciesIgnoreOrder(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,784 @@
+package configstack
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/telemetry"
+)
+
+// scheduler runs a set of modules in dependency order. A single dispatcher, running in the goroutine calling run,
+// tracks the dependencies of the modules and hands out the modules whose dependencies are all done to a fixed pool of
+// workers. As the number of workers is bounded by the parallelism, the number of goroutines does not grow with the
+// number of modules, however wide the graph is.
+type scheduler struct {
+	ctx     context.Context
+	opts    *options.TerragruntOptions
+	modules RunningModules
+	workers int
+	trace   *schedulerTrace
+	clock   options.Clock
+	// Time at which the run started, from which the concurrency ramps up.
+	start time.Time
+
+	// Modules whose dependencies are all done, in the order in which they are dispatched to the workers.
+	ready []*RunningModule
+	// Number of modules at the front of the ready queue that are already reported as queued in the trace.
+	queuedReported int
+	// Number of modules at the front of the ready queue for which the QueueWarnAfter warning is already emitted.
+	queueWarned int
+	// Time at which each module in the ready queue became ready.
+	readyAt map[string]time.Time
+	// Members of each group, sorted by path.
+	groups map[string][]*RunningModule
+	// Modules dispatched to the workers, by path.
+	started map[string]bool
+	// With FenceByBackend, the module ready or running that holds each backend fence, and the modules whose
+	// dependencies are all done waiting for it, in the order in which they became ready.
+	fenceHolders map[string]*RunningModule
+	fenceWaiting map[string][]*RunningModule
+	// With ParallelismPerAccount, the number of modules ready or running for each account, the modules counted that
+	// way, and the modules whose dependencies are all done waiting for their account, in the order in which they
+	// became ready.
+	accountHolders map[string]int
+	accountHeld    map[string]bool
+	accountWaiting map[string][]*RunningModule
+	// With ResourceCapacities, the amount of each resource used by the modules ready or running, the modules counted
+	// that way, and the modules whose dependencies are all done waiting for resources, in the order in which they
+	// became ready.
+	resourceUsage   map[string]int
+	resourceHeld    map[string]bool
+	resourceWaiting []*RunningModule
+	// With PauseBetweenLevels, the level of every module, the level whose modules may run, the number of modules of
+	// each level that haven't finished yet, and the modules of the later levels whose dependencies are all done, by
+	// level.
+	levels         map[string]int
+	currentLevel   int
+	levelRemaining map[int]int
+	levelWaiting   map[int][]*RunningModule
+	// With a RunSeed, the source of the deterministic order of the modules, and the results of the modules that have
+	// finished since the last time no module was running.
+	rng     *rand.Rand
+	pending []workerResult
+	// With an EventSocket, the socket the events of the run are published to.
+	events *eventSocket
+	// Number of modules that ran and succeeded, counted towards the ApplyLimit.
+	succeeded int
+	// Number of modules dispatched to the workers that haven't finished yet.
+	running int
+	// Number of modules that haven't finished yet.
+	remaining int
+	// Whether the run is stalled, reported to the onStallPoint of the stack when it becomes so.
+	stalled bool
+	// The stack the modules are run as part of, if any.
+	stack *Stack
+	// With AdaptiveConcurrency, the number of modules that may run concurrently given the failure rate, and whether
+	// each of the last modules that ran failed, the latest last.
+	adaptiveLimit int
+	outcomes      []bool
+}
+
+// workerResult is sent by a worker to the dispatcher once it is done running a module.
+type workerResult struct {
+	module *RunningModule
+	err    error
+}
+
+func newScheduler(ctx context.Context, opts *options.TerragruntOptions, modules RunningModules, parallelism int) *scheduler {
+	groups := map[string][]*RunningModule{}
+
+	for _, module := range modules {
+		if module.Module.Group != "" {
+			groups[module.Module.Group] = append(groups[module.Module.Group], module)
+		}
+	}
+
+	for _, members := range groups {
+		sort.Slice(members, func(i, j int) bool {
+			return members[i].Module.Path < members[j].Module.Path
+		})
+	}
+
+	var rng *rand.Rand
+	if opts.RunSeed != 0 {
+		rng = rand.New(rand.NewSource(opts.RunSeed)) //nolint:gosec
+	}
+
+	var (
+		levels         map[string]int
+		levelRemaining map[int]int
+	)
+
+	if opts.PauseBetweenLevels {
+		levels = modules.runLevels()
+		levelRemaining = map[int]int{}
+
+		for _, level := range levels {
+			levelRemaining[level]++
+		}
+	}
+
+	workers := max(min(parallelism, len(modules)), 1)
+
+	return &scheduler{
+		ctx:       ctx,
+		opts:      opts,
+		modules:   modules,
+		workers:   workers,
+		trace:     newSchedulerTrace(opts),
+		clock:     clockFrom(opts),
+		remaining: len(modules),
+		readyAt:   map[string]time.Time{},
+		groups:    groups,
+		started:   map[string]bool{},
+		stack:     modules.stack(),
+
+		fenceHolders: map[string]*RunningModule{},
+		fenceWaiting: map[string][]*RunningModule{},
+		rng:          rng,
+
+		accountHolders: map[string]int{},
+		accountHeld:    map[string]bool{},
+		accountWaiting: map[string][]*RunningModule{},
+
+		resourceUsage: map[string]int{},
+		resourceHeld:  map[string]bool{},
+
+		levels:         levels,
+		levelRemaining: levelRemaining,
+		levelWaiting:   map[int][]*RunningModule{},
+
+		adaptiveLimit: workers,
+	}
+}
+
+// run runs all the modules and returns once they have all finished. The result of each module is stored in its Err
+// field.
+func (scheduler *scheduler) run() {
+	if len(scheduler.modules) == 0 {
+		return
+	}
+
+	var (
+		waitGroup sync.WaitGroup
+		jobs      = make(chan *RunningModule)
+		// Buffered, so that a worker never blocks on handing over its result and is always free to take the next job
+		// once its result is sent.
+		results = make(chan workerResult, scheduler.workers)
+	)
+
+	for range scheduler.workers {
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			for module := range jobs {
+				results <- workerResult{module: module, err: scheduler.runModule(module)}
+			}
+		}()
+	}
+
+	paths := make([]string, 0, len(scheduler.modules))
+	for path := range scheduler.modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	if scheduler.rng != nil {
+		scheduler.rng.Shuffle(len(paths), func(i, j int) {
+			paths[i], paths[j] = paths[j], paths[i]
+		})
+	}
+
+	scheduler.start = scheduler.clock.Now()
+
+	for _, path := range paths {
+		module := scheduler.modules[path]
+
+		module.Module.TerragruntOptions.Logger.Debugf("Module %s must wait for %d dependencies to finish", module.Module.Path, len(module.Dependencies))
+		scheduler.trace.recordBlocked(module)
+
+		if module.Status == Finished {
+			// blocked by an excluded dependency of a module finished earlier
+			continue
+		}
+
+		if excludedDependency := scheduler.excludedDependency(module); excludedDependency != nil {
+			module.Module.TerragruntOptions.Logger.Errorf("Dependency %s of module %s is excluded. Module %s will have to return an error too.", excludedDependency.Path, module.Module.Path, module.Module.Path)
+			scheduler.finish(module, SkippedDependencyError{Module: module.Module, Dependency: excludedDependency})
+
+			continue
+		}
+
+		if len(module.Dependencies) == 0 {
+			scheduler.markReady(module)
+		}
+	}
+
+	scheduler.advanceLevel()
+
+	for scheduler.remaining > 0 {
+		for len(scheduler.ready) > 0 && scheduler.running < scheduler.concurrencyLimit() && scheduler.resumed() == nil && scheduler.belowApplyLimit() {
+			module := scheduler.ready[0]
+			scheduler.ready = scheduler.ready[1:]
+			scheduler.queuedReported = max(scheduler.queuedReported-1, 0)
+			scheduler.queueWarned = max(scheduler.queueWarned-1, 0)
+
+			delete(scheduler.readyAt, module.Module.Path)
+
+			// a queued module is skipped when a member of its group fails
+			if module.Status == Finished {
+				continue
+			}
+
+			scheduler.running++
+			scheduler.started[module.Module.Path] = true
+			module.Status = Running
+
+			scheduler.trace.record(traceEventDispatched, module.Module.Path, "")
+			scheduler.emit(options.RunEvent{Type: options.RunEventModuleStarted, Path: module.Module.Path})
+
+			jobs <- module
+		}
+
+		for _, module := range scheduler.ready[scheduler.queuedReported:] {
+			if scheduler.resumed() != nil {
+				scheduler.trace.record(traceEventQueued, module.Module.Path, "run paused")
+			} else {
+				scheduler.trace.record(traceEventQueued, module.Module.Path, "parallelism limit of %d reached", scheduler.workers)
+			}
+		}
+
+		scheduler.queuedReported = len(scheduler.ready)
+
+		scheduler.checkStall()
+
+		result, ok := scheduler.waitForResult(results)
+		if !ok {
+			// the concurrency limit has ramped up or the run has resumed, more modules can be dispatched
+			continue
+		}
+
+		scheduler.running--
+		scheduler.adaptConcurrency(result)
+
+		if scheduler.rng != nil {
+			scheduler.finishDeterministically(result)
+		} else {
+			scheduler.finish(result.module, result.err)
+		}
+
+		scheduler.stopAtApplyLimit()
+		scheduler.advanceLevel()
+
+		// the modules it unblocked end the stall, if any
+		scheduler.checkStall()
+	}
+
+	close(jobs)
+	waitGroup.Wait()
+}
+
+// waitForResult waits for the next worker to finish running a module. While waiting, a RunEventQueueSaturated event
+// is emitted for every queued module that has been ready for longer than QueueWarnAfter. It returns false without a
+// result if the concurrency limit ramps up while modules are queued, or if the run resumes while paused, so that they can
+// be dispatched.
+func (scheduler *scheduler) waitForResult(results <-chan workerResult) (workerResult, bool) {
+	for {
+		// modules skipped while queued are not waiting anymore
+		for scheduler.queueWarned < len(scheduler.ready) && scheduler.ready[scheduler.queueWarned].Status == Finished {
+			scheduler.queueWarned++
+		}
+
+		var (
+			queueWarning <-chan time.Time
+			rampUp       <-chan time.Time
+			module       *RunningModule
+			readyAt      time.Time
+			// nil unless paused, so that waiting on it blocks
+			resumed = scheduler.resumed()
+		)
+
+		if scheduler.opts.QueueWarnAfter > 0 && scheduler.queueWarned < len(scheduler.ready) {
+			// The ready queue is in the order in which the modules became ready, so the next module to warn about is
+			// the first one that hasn't been warned about yet.
+			module = scheduler.ready[scheduler.queueWarned]
+			readyAt = scheduler.readyAt[module.Module.Path]
+			queueWarning = scheduler.clock.After(readyAt.Add(scheduler.opts.QueueWarnAfter).Sub(scheduler.clock.Now()))
+		}
+
+		if len(scheduler.ready) > 0 && resumed == nil {
+			if wait, rampingUp := scheduler.nextRampUpStep(); rampingUp {
+				rampUp = scheduler.clock.After(wait)
+			}
+		}
+
+		select {
+		case result := <-results:
+			return result, true
+		case <-rampUp:
+			return workerResult{}, false
+		case <-resumed:
+			scheduler.opts.Logger.Infof("Run resumed")
+			return workerResult{}, false
+		case <-queueWarning:
+			wait := scheduler.clock.Now().Sub(readyAt)
+
+			module.Module.TerragruntOptions.Logger.Warnf("Module %s has been waiting for %s for a free parallelism slot, the parallelism limit of %d may be too low", module.Module.Path, wait, scheduler.workers)
+			scheduler.emit(options.RunEvent{
+				Type:     options.RunEventQueueSaturated,
+				Path:     module.Module.Path,
+				Message:  fmt.Sprintf("ready for %s, waiting for one of %d parallelism slots", wait, scheduler.workers),
+				Duration: wait,
+			})
+
+			scheduler.queueWarned++
+		}
+	}
+}
+
+// finishDeterministically holds back the given result until no module is running anymore, then finishes all the held
+// back modules in an order drawn from the RunSeed, so that the order in which the modules finish, and so the order in
+// which their dependents are dispatched, doesn't depend on the order in which the goroutines happened to run.
+func (scheduler *scheduler) finishDeterministically(result workerResult) {
+	scheduler.pending = append(scheduler.pending, result)
+	if scheduler.running > 0 {
+		return
+	}
+
+	pending := scheduler.pending
+	scheduler.pending = nil
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].module.Module.Path < pending[j].module.Module.Path
+	})
+
+	scheduler.rng.Shuffle(len(pending), func(i, j int) {
+		pending[i], pending[j] = pending[j], pending[i]
+	})
+
+	for _, result := range pending {
+		scheduler.finish(result.module, result.err)
+	}
+}
+
+// concurrencyLimit returns the number of modules that may run concurrently: during the RampUp window at the start of the
+// run, it grows linearly from 1 to the number of workers, after which all the workers are used. With
+// AdaptiveConcurrency, it is further limited according to the failure rate of the modules.
+func (scheduler *scheduler) concurrencyLimit() int {
+	limit := scheduler.workers
+
+	rampUp := scheduler.opts.RampUp
+	if elapsed := scheduler.clock.Now().Sub(scheduler.start); rampUp > 0 && elapsed < rampUp {
+		limit = 1 + int(int64(scheduler.workers-1)*int64(elapsed)/int64(rampUp))
+	}
+
+	if scheduler.opts.AdaptiveConcurrency {
+		limit = min(limit, scheduler.adaptiveLimit)
+	}
+
+	return limit
+}
+
+// nextRampUpStep returns the time left until the concurrency limit allows one more module to run than are running
+// now, or false if the concurrency limit is not ramping up anymore or already allows more modules to run.
+func (scheduler *scheduler) nextRampUpStep() (time.Duration, bool) {
+	rampUp := scheduler.opts.RampUp
+	if rampUp <= 0 || scheduler.running >= scheduler.workers || scheduler.running < scheduler.concurrencyLimit() {
+		return 0, false
+	}
+
+	if scheduler.clock.Now().Sub(scheduler.start) >= rampUp {
+		return 0, false
+	}
+
+	// the limit allows running+1 modules once (workers-1)*elapsed/rampUp reaches running, rounded up so that the limit
+	// has grown by the time the step is reached
+	step := time.Duration((int64(rampUp)*int64(scheduler.running) + int64(scheduler.workers-2)) / int64(scheduler.workers-1))
+
+	return max(scheduler.start.Add(step).Sub(scheduler.clock.Now()), 0), true
+}
+
+// belowApplyLimit returns whether one more module may be dispatched without more than ApplyLimit modules possibly
+// succeeding. The modules that are running or waiting to be finished are counted as if they will succeed; if one of
+// them fails, another module is dispatched in its place.
+func (scheduler *scheduler) belowApplyLimit() bool {
+	limit := scheduler.opts.ApplyLimit
+
+	return limit <= 0 || scheduler.succeeded+scheduler.running+len(scheduler.pending) < limit
+}
+
+// stopAtApplyLimit skips the modules that haven't started yet once ApplyLimit modules have succeeded, leaving them for
+// a later run.
+func (scheduler *scheduler) stopAtApplyLimit() {
+	limit := scheduler.opts.ApplyLimit
+	if limit <= 0 || scheduler.succeeded < limit || scheduler.remaining == 0 {
+		return
+	}
+
+	var skipped []*RunningModule
+
+	for _, module := range scheduler.modules {
+		if !scheduler.started[module.Module.Path] && module.Status != Finished {
+			// marked as finished before any of them is, so that the skipped modules are not reported as blocked by
+			// each other
+			module.Status = Finished
+			skipped = append(skipped, module)
+		}
+	}
+
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Module.Path < skipped[j].Module.Path
+	})
+
+	scheduler.opts.Logger.Infof("Apply limit of %d modules reached. The %d remaining modules are left for a later run.", limit, len(skipped))
+
+	for _, module := range skipped {
+		module.Module.TerragruntOptions.Logger.Debugf("Apply limit of %d modules reached. Module %s will be skipped.", limit, module.Module.Path)
+		scheduler.finish(module, ApplyLimitReachedError{Module: module.Module, Limit: limit})
+	}
+}
+
+// checkStall calls the onStallPoint of the stack with the modules left to run when the run becomes stalled: no module
+// is ready to run, and all the modules that haven't started wait for the modules that are running.
+func (scheduler *scheduler) checkStall() {
+	if scheduler.stack == nil || scheduler.stack.onStallPoint == nil {
+		return
+	}
+
+	inFlight := scheduler.running + len(scheduler.pending)
+	stalled := len(scheduler.ready) == 0 && inFlight > 0 && scheduler.remaining > inFlight
+
+	if stalled && !scheduler.stalled {
+		var blocked TerraformModules
+
+		for path, module := range scheduler.modules {
+			if !scheduler.started[path] && module.Status != Finished {
+				blocked = append(blocked, module.Module)
+			}
+		}
+
+		sort.Slice(blocked, func(i, j int) bool {
+			return blocked[i].Path < blocked[j].Path
+		})
+
+		scheduler.stack.onStallPoint(blocked)
+	}
+
+	scheduler.stalled = stalled
+}
+
+// resumed returns the channel closed once the run is resumed if the RunControl of the options pauses the run, or nil if
+// the run is not paused.
+func (scheduler *scheduler) resumed() <-chan struct{} {
+	if scheduler.opts.RunControl == nil {
+		return nil
+	}
+
+	return scheduler.opts.RunControl.Resumed()
+}
+
+// emit sends the given event to the RunEventHandler of the options and to the subscribers of the EventSocket, if any.
+func (scheduler *scheduler) emit(event options.RunEvent) {
+	if scheduler.opts.RunEventHandler == nil && scheduler.events == nil {
+		return
+	}
+
+	event.Time = scheduler.clock.Now()
+
+	if scheduler.events != nil {
+		scheduler.events.publish(event)
+	}
+
+	if scheduler.opts.RunEventHandler != nil {
+		scheduler.opts.RunEventHandler(event)
+	}
+}
+
+// runModule runs a single module, recording when it started and finished. The error of the module goes through the
+// error transform of its stack, if any, before being recorded and propagated to its dependents. Called from the
+// workers.
+func (scheduler *scheduler) runModule(module *RunningModule) error {
+	module.StartTime = scheduler.clock.Now()
+	defer func() {
+		module.EndTime = scheduler.clock.Now()
+	}()
+
+	err := telemetry.Telemetry(scheduler.ctx, scheduler.opts, "run_module", map[string]interface{}{
+		"path":             module.Module.Path,
+		"terraformCommand": module.Module.TerragruntOptions.TerraformCommand,
+	}, func(childCtx context.Context) (err error) {
+		defer module.Module.recoverPanic(&err)
+
+		return module.runNow(scheduler.ctx, scheduler.opts)
+	})
+
+	if err != nil && module.Module.Stack != nil && module.Module.errorTransform != nil {
+		if transformedErr := module.Module.errorTransform(module.Module, err); transformedErr != nil {
+			err = transformedErr
+		}
+	}
+
+	return err
+}
+
+// markReady queues the given module, whose dependencies are all done, for running. With PauseBetweenLevels, a module
+// of a later level than the current one waits for the run to continue to its level. With FenceByBackend, a module whose
+// backend fence is held by another module waits for it to finish instead, with ParallelismPerAccount, a module whose
+// account already has as many modules ready or running waits for one of them to finish, and with ResourceCapacities, a
+// module waits for the resources it uses to be available.
+func (scheduler *scheduler) markReady(module *RunningModule) {
+	if !scheduler.waitForLevel(module) {
+		return
+	}
+
+	if fence := scheduler.fence(module); fence != "" {
+		if holder, found := scheduler.fenceHolders[fence]; found {
+			scheduler.trace.record(traceEventQueued, module.Module.Path, "backend %s in use by %s", fence, holder.Module.Path)
+			scheduler.fenceWaiting[fence] = append(scheduler.fenceWaiting[fence], module)
+
+			return
+		}
+
+		scheduler.fenceHolders[fence] = module
+	}
+
+	scheduler.markReadyForAccount(module)
+}
+
+// markReadyForAccount queues the given module, which holds its backend fence if any, for running, unless its account
+// already has ParallelismPerAccount modules ready or running, in which case it waits for one of them to finish.
+func (scheduler *scheduler) markReadyForAccount(module *RunningModule) {
+	if limit := scheduler.opts.ParallelismPerAccount; limit > 0 {
+		account := module.Module.account()
+
+		if scheduler.accountHolders[account] >= limit {
+			scheduler.trace.record(traceEventQueued, module.Module.Path, "parallelism limit of %d reached for account %q", limit, account)
+			scheduler.accountWaiting[account] = append(scheduler.accountWaiting[account], module)
+
+			return
+		}
+
+		scheduler.accountHolders[account]++
+		scheduler.accountHeld[module.Module.Path] = true
+	}
+
+	scheduler.markReadyForResources(module)
+}
+
+// enqueue appends the given module, which holds everything it needs to run, to the ready queue.
+func (scheduler *scheduler) enqueue(module *RunningModule) {
+	scheduler.trace.record(traceEventReady, module.Module.Path, "")
+	scheduler.readyAt[module.Module.Path] = scheduler.clock.Now()
+	scheduler.ready = append(scheduler.ready, module)
+}
+
+// finish records that a module has finished executing and notifies all the modules that depend on it.
+func (scheduler *scheduler) finish(module *RunningModule, moduleErr error) {
+	if moduleErr == nil {
+		module.Module.TerragruntOptions.Logger.Debugf("Module %s has finished successfully!", module.Module.Path)
+		scheduler.trace.record(traceEventFinished, module.Module.Path, "successfully")
+	} else {
+		module.Module.TerragruntOptions.Logger.Errorf("Module %s has finished with an error", module.Module.Path)
+		scheduler.trace.record(traceEventFinished, module.Module.Path, "with error: %v", moduleErr)
+	}
+
+	module.Status = Finished
+	module.Err = moduleErr
+	scheduler.remaining--
+
+	if scheduler.levels != nil {
+		scheduler.levelRemaining[scheduler.levels[module.Module.Path]]--
+	}
+
+	if module.ranSuccessfully() {
+		scheduler.succeeded++
+	}
+
+	event := options.RunEvent{Type: options.RunEventModuleFinished, Path: module.Module.Path}
+	if moduleErr != nil {
+		event.Message = moduleErr.Error()
+	}
+
+	scheduler.emit(event)
+	scheduler.recordSpan(module)
+
+	scheduler.releaseFence(module)
+	scheduler.releaseAccount(module)
+	scheduler.releaseResources(module)
+
+	if moduleErr != nil && module.Module.Group != "" {
+		scheduler.skipGroup(module)
+	}
+
+	dependents := module.NotifyWhenDone
+
+	if scheduler.rng != nil {
+		// the modules to notify are linked in the random order of a map
+		dependents = slices.Clone(dependents)
+		sort.Slice(dependents, func(i, j int) bool {
+			return dependents[i].Module.Path < dependents[j].Module.Path
+		})
+	}
+
+	for _, toNotify := range dependents {
+		// The modules to notify are looked up by path, as excluded modules are not part of the run.
+		if dependent, found := scheduler.modules[toNotify.Module.Path]; found {
+			scheduler.dependencyDone(dependent, module)
+		}
+	}
+}
+
+// fence returns the backend fence of the given module if FenceByBackend is set, or an empty string otherwise.
+func (scheduler *scheduler) fence(module *RunningModule) string {
+	if !scheduler.opts.FenceByBackend {
+		return ""
+	}
+
+	return module.Module.backendFence()
+}
+
+// releaseFence hands the backend fence held by the given finished module over to the next module waiting for it, if
+// any.
+func (scheduler *scheduler) releaseFence(module *RunningModule) {
+	fence := scheduler.fence(module)
+	if fence == "" || scheduler.fenceHolders[fence] != module {
+		return
+	}
+
+	delete(scheduler.fenceHolders, fence)
+
+	for len(scheduler.fenceWaiting[fence]) > 0 {
+		next := scheduler.fenceWaiting[fence][0]
+		scheduler.fenceWaiting[fence] = scheduler.fenceWaiting[fence][1:]
+
+		// a waiting module is skipped when a member of its group fails
+		if next.Status != Finished {
+			scheduler.markReady(next)
+			return
+		}
+	}
+}
+
+// releaseAccount hands the slot of the account held by the given finished module over to the next module waiting for
+// it, if any.
+func (scheduler *scheduler) releaseAccount(module *RunningModule) {
+	if !scheduler.accountHeld[module.Module.Path] {
+		return
+	}
+
+	account := module.Module.account()
+
+	delete(scheduler.accountHeld, module.Module.Path)
+	scheduler.accountHolders[account]--
+
+	for len(scheduler.accountWaiting[account]) > 0 {
+		next := scheduler.accountWaiting[account][0]
+		scheduler.accountWaiting[account] = scheduler.accountWaiting[account][1:]
+
+		// a waiting module is skipped when a member of its group fails
+		if next.Status != Finished {
+			scheduler.markReadyForAccount(next)
+			return
+		}
+	}
+}
+
+// skipGroup skips the members of the group of the given failed module that haven't started yet.
+func (scheduler *scheduler) skipGroup(failedMember *RunningModule) {
+	for _, member := range scheduler.groups[failedMember.Module.Group] {
+		if scheduler.started[member.Module.Path] || member.Status == Finished {
+			continue
+		}
+
+		member.Module.TerragruntOptions.Logger.Errorf("Module %s of group %s just finished with an error. Module %s will be skipped.", failedMember.Module.Path, failedMember.Module.Group, member.Module.Path)
+		scheduler.finish(member, GroupMemberFailedError{Module: member.Module, FailedMember: failedMember.Module})
+	}
+}
+
+// dependencyDone records that the given dependency of the module has finished. If the dependency failed, the module
+// fails as well, unless dependency errors are ignored. Otherwise, the module is queued for running once all of its
+// dependencies are done.
+func (scheduler *scheduler) dependencyDone(module *RunningModule, doneDependency *RunningModule) {
+	// Checked first, as a module that is already dispatched has no dependencies left and is updated by its worker.
+	if _, isDependency := module.Dependencies[doneDependency.Module.Path]; !isDependency || module.Status == Finished {
+		return
+	}
+
+	delete(module.Dependencies, doneDependency.Module.Path)
+
+	if doneDependency.outputEnv != nil {
+		if module.dependencyOutputEnv == nil {
+			module.dependencyOutputEnv = map[string]map[string]string{}
+		}
+
+		module.dependencyOutputEnv[doneDependency.Module.Path] = doneDependency.outputEnv
+	}
+
+	if doneDependency.outputsChanged && module.Module.AssumeAlreadyApplied {
+		module.Module.TerragruntOptions.Logger.Infof("The outputs of module %s changed, module %s will run even though it is assumed already applied", doneDependency.Module.Path, module.Module.Path)
+		module.Module.AssumeAlreadyApplied = false
+	}
+
+	scheduler.trace.record(traceEventDependency, module.Module.Path, "%s finished, %d dependencies remaining", doneDependency.Module.Path, len(module.Dependencies))
+
+	if doneDependency.Err == nil && doneDependency.Skipped && !scheduler.opts.RunDependentsOnSkippedDependency {
+		module.Module.TerragruntOptions.Logger.Errorf("Dependency %s of module %s was skipped. Module %s will have to return an error too.", doneDependency.Module.Path, module.Module.Path, module.Module.Path)
+		scheduler.finish(module, SkippedDependencyError{Module: module.Module, Dependency: doneDependency.Module})
+
+		return
+	}
+
+	if doneDependency.Err != nil {
+		if module.Module.TerragruntOptions.IgnoreDependencyErrors {
+			module.Module.TerragruntOptions.Logger.Errorf("Dependency %s of module %s just finished with an error. Module %s will have to return an error too. However, because of --terragrunt-ignore-dependency-errors, module %s will run anyway.", doneDependency.Module.Path, module.Module.Path, module.Module.Path, module.Module.Path)
+		} else {
+			module.Module.TerragruntOptions.Logger.Errorf("Dependency %s of module %s just finished with an error. Module %s will have to return an error too.", doneDependency.Module.Path, module.Module.Path, module.Module.Path)
+			scheduler.finish(module, ProcessingModuleDependencyError{module.Module, doneDependency.Module, doneDependency.Err})
+
+			return
+		}
+	} else {
+		module.Module.TerragruntOptions.Logger.Debugf("Dependency %s of module %s just finished successfully. Module %s must wait on %d more dependencies.", doneDependency.Module.Path, module.Module.Path, module.Module.Path, len(module.Dependencies))
+	}
+
+	if len(module.Dependencies) == 0 {
+		scheduler.markReady(module)
+	}
+}
+
+// excludedDependency returns the first dependency of the given module, by path, that is excluded from the run if
+// RunDependentsOnSkippedDependency is not set, or nil otherwise. The dependencies excluded because they succeeded in
+// the run given by RerunFailedFrom are left out, as they are already applied.
+func (scheduler *scheduler) excludedDependency(module *RunningModule) *TerraformModule {
+	if scheduler.opts.RunDependentsOnSkippedDependency {
+		return nil
+	}
+
+	var excluded *TerraformModule
+
+	for _, dependency := range module.Module.Dependencies {
+		if dependency.FlagExcluded && !dependency.succeededBefore && (excluded == nil || dependency.Path < excluded.Path) {
+			excluded = dependency
+		}
+	}
+
+	return excluded
+}
+
+// clockFrom returns the clock of the given options, falling back to the real clock if it is not set.
+func clockFrom(opts *options.TerragruntOptions) options.Clock {
+	if opts.Clock == nil {
+		return options.RealClock{}
+	}
+
+	return opts.Clock
+}
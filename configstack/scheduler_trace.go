@@ -0,0 +1,74 @@
+package configstack
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// Events recorded in the scheduler trace
+const (
+	traceEventBlocked    = "blocked"
+	traceEventDependency = "dependency-done"
+	traceEventReady      = "ready"
+	traceEventQueued     = "queued"
+	traceEventDispatched = "dispatched"
+	traceEventFinished   = "finished"
+)
+
+// schedulerTrace writes the decisions taken by the scheduler while running modules, one timestamped event per line, to
+// the SchedulerTrace writer of the options. It is meant for debugging the scheduler itself, so, unlike the logs, it
+// only contains scheduling events. A nil schedulerTrace records nothing.
+type schedulerTrace struct {
+	mu     sync.Mutex
+	writer io.Writer
+	clock  options.Clock
+}
+
+// newSchedulerTrace returns a trace writing to the SchedulerTrace writer of the given options, or nil if it is not set.
+func newSchedulerTrace(opts *options.TerragruntOptions) *schedulerTrace {
+	if opts.SchedulerTrace == nil {
+		return nil
+	}
+
+	return &schedulerTrace{writer: opts.SchedulerTrace, clock: clockFrom(opts)}
+}
+
+// record writes a single event about the module with the given path to the trace.
+func (trace *schedulerTrace) record(event, path, format string, args ...interface{}) {
+	if trace == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+
+	line := fmt.Sprintf("%s %s %s", trace.clock.Now().UTC().Format(time.RFC3339Nano), event, path)
+	if format != "" {
+		line += " " + fmt.Sprintf(format, args...)
+	}
+
+	// The trace is a debugging aid, failing to write it must not fail the run.
+	_, _ = io.WriteString(trace.writer, line+"\n")
+}
+
+// recordBlocked writes the dependencies the module is still waiting for to the trace.
+func (trace *schedulerTrace) recordBlocked(module *RunningModule) {
+	if trace == nil || len(module.Dependencies) == 0 {
+		return
+	}
+
+	dependencies := make([]string, 0, len(module.Dependencies))
+	for path := range module.Dependencies {
+		dependencies = append(dependencies, path)
+	}
+
+	sort.Strings(dependencies)
+
+	trace.record(traceEventBlocked, module.Module.Path, "waiting for dependencies: %s", strings.Join(dependencies, ", "))
+}
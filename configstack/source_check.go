@@ -0,0 +1,159 @@
+package configstack
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/terraform"
+)
+
+// sourceCheckTimeout bounds the time spent checking that a single source is reachable.
+const sourceCheckTimeout = 30 * time.Second
+
+// remoteSource returns the terraform source of the module if it is downloaded from a remote location, or an empty
+// string if the module has no source or a local one.
+func (module *TerraformModule) remoteSource() string {
+	source := module.TerragruntOptions.Source
+	if source == "" && module.Config.Terraform != nil && module.Config.Terraform.Source != nil {
+		source = *module.Config.Terraform.Source
+	}
+
+	if source == "" {
+		return ""
+	}
+
+	sourceURL, err := terraform.ToSourceURL(source, module.Path)
+	if err != nil || terraform.IsLocalSource(sourceURL) {
+		return ""
+	}
+
+	return source
+}
+
+// checkSources checks that the remote sources of the modules to run are reachable before anything runs, checking
+// every source once. With SkipUnreachableSources, the modules with an unreachable source are left out of the run along
+// with all the modules that depend on them, directly or not. Otherwise, an error listing every unreachable source is
+// returned.
+func (modules RunningModules) checkSources(ctx context.Context, opts *options.TerragruntOptions) error {
+	checker := opts.SourceChecker
+	if checker == nil {
+		checker = checkSourceReachable
+	}
+
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	var (
+		errs        *errors.MultiError
+		unreachable = []*RunningModule{}
+		checked     = map[string]error{}
+	)
+
+	for _, path := range paths {
+		module := modules[path]
+		if module.Module.Barrier || module.Module.AssumeAlreadyApplied {
+			continue
+		}
+
+		source := module.Module.remoteSource()
+		if source == "" {
+			continue
+		}
+
+		sourceErr, found := checked[source]
+		if !found {
+			sourceErr = checker(ctx, source)
+			checked[source] = sourceErr
+		}
+
+		if sourceErr != nil {
+			errs = errs.Append(UnreachableSourceError{ModulePath: path, Source: source, Err: sourceErr})
+			unreachable = append(unreachable, module)
+		}
+	}
+
+	if !opts.SkipUnreachableSources {
+		return errs.ErrorOrNil()
+	}
+
+	for _, module := range unreachable {
+		modules.removeWithDependents(opts, module, module)
+	}
+
+	return nil
+}
+
+// removeWithDependents leaves the given module out of the run along with the modules that depend on it, as the source
+// of the given unreachable module is unreachable.
+func (modules RunningModules) removeWithDependents(opts *options.TerragruntOptions, module, unreachable *RunningModule) {
+	if _, found := modules[module.Module.Path]; !found {
+		return
+	}
+
+	if module == unreachable {
+		opts.Logger.Warnf("Skipping module %s as its source %s is unreachable", module.Module.Path, module.Module.remoteSource())
+	} else {
+		opts.Logger.Warnf("Skipping module %s as it depends on module %s, whose source is unreachable", module.Module.Path, unreachable.Module.Path)
+	}
+
+	delete(modules, module.Module.Path)
+
+	for _, dependent := range module.NotifyWhenDone {
+		modules.removeWithDependents(opts, dependent, unreachable)
+	}
+}
+
+// checkSourceReachable is the default SourceChecker: git sources are checked with git ls-remote and HTTP sources with
+// a HEAD request, while the other remote sources, e.g. from S3 or a registry, are assumed reachable.
+func checkSourceReachable(ctx context.Context, source string) error {
+	ctx, cancel := context.WithTimeout(ctx, sourceCheckTimeout)
+	defer cancel()
+
+	repoURL, err := terraform.ToSourceURL(source, ".")
+	if err != nil {
+		return err
+	}
+
+	// the subdirectory and the ref don't matter for the repository to be reachable
+	repoURL.Path, _, _ = strings.Cut(repoURL.Path, "//")
+
+	query := repoURL.Query()
+	query.Del("ref")
+	repoURL.RawQuery = query.Encode()
+
+	switch {
+	case strings.HasPrefix(repoURL.Scheme, "git::"):
+		repoURL.Scheme = strings.TrimPrefix(repoURL.Scheme, "git::")
+
+		if output, err := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", repoURL.String(), "HEAD").CombinedOutput(); err != nil {
+			return errors.Errorf("git ls-remote failed: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	case repoURL.Scheme == "http" || repoURL.Scheme == "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, repoURL.String(), nil)
+		if err != nil {
+			return errors.New(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.New(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return errors.Errorf("HEAD %s returned %s", repoURL.Redacted(), resp.Status)
+		}
+	}
+
+	return nil
+}
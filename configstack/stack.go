@@ -26,15 +26,25 @@ import (
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/internal/errors"
 	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/shell"
 	"github.com/gruntwork-io/terragrunt/util"
 )
 
+// Exit code of `plan -detailed-exitcode` when the plan succeeded and there are changes to apply
+const planChangesExitCode = 2
+
 // Stack represents a stack of Terraform modules (i.e. folders with Terraform templates) that you can "spin up" or
 // "spin down" in a single command
 type Stack struct {
 	parserOptions         []hclparse.Option
 	terragruntOptions     *options.TerragruntOptions
 	childTerragruntConfig *config.TerragruntConfig
+	discoverer            Discoverer
+	errorTransform        func(module *TerraformModule, err error) error
+	alreadyApplied        func(module *TerraformModule) (bool, error)
+	onStallPoint          func(blocked TerraformModules)
+	exitCode              func(summary RunSummary) int
+	graphLabels           bool
 	Modules               TerraformModules
 	outputMu              sync.Mutex
 }
@@ -42,12 +52,14 @@ type Stack struct {
 // FindStackInSubfolders finds all the Terraform modules in the subfolders of the working directory of the given TerragruntOptions and
 // assemble them into a Stack object that can be applied or destroyed in a single command
 func FindStackInSubfolders(ctx context.Context, terragruntOptions *options.TerragruntOptions, opts ...Option) (*Stack, error) {
+	stack := NewStack(terragruntOptions, opts...)
+
 	var terragruntConfigFiles []string
 
 	err := telemetry.Telemetry(ctx, terragruntOptions, "find_files_in_path", map[string]interface{}{
 		"working_dir": terragruntOptions.WorkingDir,
 	}, func(childCtx context.Context) error {
-		result, err := config.FindConfigFilesInPath(terragruntOptions.WorkingDir, terragruntOptions)
+		result, err := stack.discoverer.FindConfigFiles(terragruntOptions.WorkingDir, terragruntOptions)
 		if err != nil {
 			return err
 		}
@@ -61,7 +73,6 @@ func FindStackInSubfolders(ctx context.Context, terragruntOptions *options.Terra
 		return nil, err
 	}
 
-	stack := NewStack(terragruntOptions, opts...)
 	if err := stack.createStackForTerragruntConfigPaths(ctx, terragruntConfigFiles); err != nil {
 		return nil, err
 	}
@@ -73,6 +84,7 @@ func NewStack(terragruntOptions *options.TerragruntOptions, opts ...Option) *Sta
 	stack := &Stack{
 		terragruntOptions: terragruntOptions,
 		parserOptions:     config.DefaultParserOptions(terragruntOptions),
+		discoverer:        fileSystemDiscoverer{},
 	}
 
 	return stack.WithOptions(opts...)
@@ -163,6 +175,10 @@ func (stack *Stack) Graph(terragruntOptions *options.TerragruntOptions) {
 func (stack *Stack) Run(ctx context.Context, terragruntOptions *options.TerragruntOptions) error {
 	stackCmd := terragruntOptions.TerraformCommand
 
+	if stackCmd == terraform.CommandNameApply && terragruntOptions.RunAllPlanThenApply {
+		return stack.RunPlanThenApply(ctx, terragruntOptions)
+	}
+
 	// prepare folder for output hierarchy if output folder is set
 	if terragruntOptions.OutputFolder != "" {
 		for _, module := range stack.Modules {
@@ -219,6 +235,158 @@ func (stack *Stack) Run(ctx context.Context, terragruntOptions *options.Terragru
 	}
 }
 
+// RunPlanThenApply runs the stack in two phases. First, `plan` is run for every module, ignoring the dependency order,
+// with the arguments given to `apply`, and the plans are saved. Then, after a single confirmation for all the modules
+// with changes, the saved plans of those modules are applied in dependency order. Modules without changes are assumed
+// to be already applied. The files written at the end of a run, such as the timeline and the JUnit report, are written
+// for each phase, with the name of the phase added before their extension.
+func (stack *Stack) RunPlanThenApply(ctx context.Context, terragruntOptions *options.TerragruntOptions) error {
+	var (
+		modulesWithChanges []string
+		mu                 sync.Mutex
+	)
+
+	planRunOptions, err := planThenApplyPhaseOptions(terragruntOptions, terraform.CommandNamePlan)
+	if err != nil {
+		return err
+	}
+
+	// the plan phase applies nothing, so it is neither recorded as applied nor counted in the durations of the applies
+	planRunOptions.ModuleHashFile = ""
+	planRunOptions.DurationHistoryFile = ""
+
+	planModules := stack.Modules.clone()
+	for _, module := range planModules {
+		planOptions, err := module.TerragruntOptions.Clone(module.TerragruntOptions.TerragruntConfigPath)
+		if err != nil {
+			return err
+		}
+
+		runTerragrunt := module.TerragruntOptions.RunTerragrunt
+
+		planOptions.TerraformCommand = terraform.CommandNamePlan
+		planOptions.TerraformCliArgs = append([]string{terraform.CommandNamePlan, "-input=false", "-detailed-exitcode", "-out=" + terraform.TerraformPlanFile}, planArgs(module.TerragruntOptions.TerraformCliArgs)...)
+		planOptions.RunTerragrunt = func(ctx context.Context, opts *options.TerragruntOptions) error {
+			err := runTerragrunt(ctx, opts)
+
+			// `plan -detailed-exitcode` returns 2 if the plan succeeded and there are changes to apply
+			if exitCode, exitCodeErr := util.GetExitCode(err); err != nil && exitCodeErr == nil && exitCode == planChangesExitCode {
+				mu.Lock()
+				defer mu.Unlock()
+
+				modulesWithChanges = append(modulesWithChanges, module.Path)
+
+				return nil
+			}
+
+			return err
+		}
+
+		module.TerragruntOptions = planOptions
+	}
+
+	if err := planModules.RunModulesIgnoreOrder(ctx, planRunOptions, terragruntOptions.Parallelism); err != nil {
+		return err
+	}
+
+	sort.Strings(modulesWithChanges)
+
+	if len(modulesWithChanges) == 0 {
+		terragruntOptions.Logger.Infof("No changes found in any of the %d modules of the stack at %s", len(stack.Modules), terragruntOptions.WorkingDir)
+		return nil
+	}
+
+	terragruntOptions.Logger.Infof("Changes found in %d of %d modules of the stack at %s:\n- %s", len(modulesWithChanges), len(stack.Modules), terragruntOptions.WorkingDir, strings.Join(modulesWithChanges, "\n- "))
+
+	shouldApply, err := stack.confirmApply(ctx, terragruntOptions, modulesWithChanges)
+	if err != nil {
+		return err
+	}
+
+	if !shouldApply {
+		return nil
+	}
+
+	applyRunOptions, err := planThenApplyPhaseOptions(terragruntOptions, terraform.CommandNameApply)
+	if err != nil {
+		return err
+	}
+
+	applyModules := stack.Modules.clone()
+	for _, module := range applyModules {
+		if !util.ListContainsElement(modulesWithChanges, module.Path) {
+			module.AssumeAlreadyApplied = true
+			continue
+		}
+
+		applyOptions, err := module.TerragruntOptions.Clone(module.TerragruntOptions.TerragruntConfigPath)
+		if err != nil {
+			return err
+		}
+
+		applyOptions.TerraformCommand = terraform.CommandNameApply
+		applyOptions.TerraformCliArgs = []string{terraform.CommandNameApply, "-input=false", terraform.TerraformPlanFile}
+		module.TerragruntOptions = applyOptions
+	}
+
+	return applyModules.RunModules(ctx, applyRunOptions, terragruntOptions.Parallelism)
+}
+
+// planArgs returns the arguments of the given `apply` command line to pass on to `plan`, leaving out the command and
+// the arguments that `plan` doesn't accept.
+func planArgs(applyArgs []string) []string {
+	var args []string
+
+	for i, arg := range applyArgs {
+		if i == 0 || arg == "-auto-approve" || strings.HasPrefix(arg, "-auto-approve=") {
+			continue
+		}
+
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+// planThenApplyPhaseOptions returns a copy of the given options for the given phase of RunPlanThenApply, with the
+// name of the phase added before the extension of the files written at the end of the run, so that the files of the
+// apply phase don't overwrite those of the plan phase.
+func planThenApplyPhaseOptions(terragruntOptions *options.TerragruntOptions, phase string) (*options.TerragruntOptions, error) {
+	phaseOptions, err := terragruntOptions.Clone(terragruntOptions.TerragruntConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	phaseOptions.TerraformCommand = phase
+
+	for _, path := range []*string{
+		&phaseOptions.RunTimelineFile,
+		&phaseOptions.FoldedStacksFile,
+		&phaseOptions.JUnitReportFile,
+		&phaseOptions.PrometheusTextfile,
+		&phaseOptions.RunSummaryFile,
+	} {
+		if *path != "" {
+			ext := filepath.Ext(*path)
+			*path = strings.TrimSuffix(*path, ext) + "." + phase + ext
+		}
+	}
+
+	return phaseOptions, nil
+}
+
+// confirmApply asks whether the plans of the given modules should be applied, either through the RunAllConfirmApply
+// callback of the given options or by prompting the user.
+func (stack *Stack) confirmApply(ctx context.Context, terragruntOptions *options.TerragruntOptions, modulesWithChanges []string) (bool, error) {
+	if terragruntOptions.RunAllConfirmApply != nil {
+		return terragruntOptions.RunAllConfirmApply(ctx, modulesWithChanges)
+	}
+
+	prompt := fmt.Sprintf("Are you sure you want to apply the changes of the %d modules listed above?", len(modulesWithChanges))
+
+	return shell.PromptUserForYesNo(ctx, prompt, terragruntOptions)
+}
+
 // We inspect the error streams to give an explicit message if the plan failed because there were references to
 // remote states. `terraform plan` will fail if it tries to access remote state from dependencies and the plan
 // has never been applied on the dependency.
@@ -318,6 +486,11 @@ func (stack *Stack) createStackForTerragruntConfigPaths(ctx context.Context, ter
 	err = telemetry.Telemetry(ctx, stack.terragruntOptions, "check_for_cycles", map[string]interface{}{
 		"working_dir": stack.terragruntOptions.WorkingDir,
 	}, func(childCtx context.Context) error {
+		// checked first, so that the cycles that go through includes are reported as such
+		if err := stack.Modules.CheckForIncludeCycles(); err != nil {
+			return errors.New(err)
+		}
+
 		if err := stack.Modules.CheckForCycles(); err != nil {
 			return errors.New(err)
 		}
@@ -336,6 +509,12 @@ func (stack *Stack) createStackForTerragruntConfigPaths(ctx context.Context, ter
 // and resolve the module that configuration file represents into a TerraformModule struct.
 // Return the list of these TerraformModule structs.
 func (stack *Stack) ResolveTerraformModules(ctx context.Context, terragruntConfigPaths []string) (TerraformModules, error) {
+	switch stack.terragruntOptions.OnMissingConfig {
+	case "", options.OnMissingConfigWarn, options.OnMissingConfigSkip, options.OnMissingConfigError:
+	default:
+		return nil, errors.New(InvalidOnMissingConfigError(stack.terragruntOptions.OnMissingConfig))
+	}
+
 	canonicalTerragruntConfigPaths, err := util.CanonicalPaths(terragruntConfigPaths, ".")
 	if err != nil {
 		return nil, err
@@ -348,7 +527,7 @@ func (stack *Stack) ResolveTerraformModules(ctx context.Context, terragruntConfi
 	}, func(childCtx context.Context) error {
 		howThesePathsWereFound := "Terragrunt config file found in a subdirectory of " + stack.terragruntOptions.WorkingDir
 
-		result, err := stack.resolveModules(ctx, canonicalTerragruntConfigPaths, howThesePathsWereFound)
+		result, err := stack.resolveModules(ctx, canonicalTerragruntConfigPaths, howThesePathsWereFound, stack.terragruntOptions.OnMissingConfig)
 		if err != nil {
 			return err
 		}
@@ -425,7 +604,7 @@ func (stack *Stack) ResolveTerraformModules(ctx context.Context, terragruntConfi
 		return nil, err
 	}
 
-	var finalModules TerraformModules
+	var modulesThatInclude TerraformModules
 
 	err = telemetry.Telemetry(ctx, stack.terragruntOptions, "flag_modules_that_dont_include", map[string]interface{}{
 		"working_dir": stack.terragruntOptions.WorkingDir,
@@ -435,6 +614,81 @@ func (stack *Stack) ResolveTerraformModules(ctx context.Context, terragruntConfi
 			return err
 		}
 
+		modulesThatInclude = result
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var selectedModules TerraformModules
+
+	err = telemetry.Telemetry(ctx, stack.terragruntOptions, "flag_modules_that_dont_match_selector", map[string]interface{}{
+		"working_dir": stack.terragruntOptions.WorkingDir,
+		"selector":    stack.terragruntOptions.ModuleSelector,
+	}, func(childCtx context.Context) error {
+		result, err := modulesThatInclude.flagModulesThatDontMatchSelector(stack.terragruntOptions)
+		if err != nil {
+			return err
+		}
+
+		selectedModules = result
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var shardModules TerraformModules
+
+	err = telemetry.Telemetry(ctx, stack.terragruntOptions, "flag_modules_outside_shard", map[string]interface{}{
+		"working_dir": stack.terragruntOptions.WorkingDir,
+		"shard_index": stack.terragruntOptions.ShardIndex,
+		"shard_total": stack.terragruntOptions.ShardTotal,
+	}, func(childCtx context.Context) error {
+		result, err := selectedModules.flagModulesOutsideShard(stack.terragruntOptions)
+		if err != nil {
+			return err
+		}
+
+		shardModules = result
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var finalModules TerraformModules
+
+	err = telemetry.Telemetry(ctx, stack.terragruntOptions, "flag_modules_that_succeeded", map[string]interface{}{
+		"working_dir":       stack.terragruntOptions.WorkingDir,
+		"rerun_failed_from": stack.terragruntOptions.RerunFailedFrom,
+	}, func(childCtx context.Context) error {
+		result, err := shardModules.flagModulesThatSucceeded(stack.terragruntOptions)
+		if err != nil {
+			return err
+		}
+
+		finalModules = result
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = telemetry.Telemetry(ctx, stack.terragruntOptions, "flag_unchanged_modules", map[string]interface{}{
+		"working_dir":      stack.terragruntOptions.WorkingDir,
+		"module_hash_file": stack.terragruntOptions.ModuleHashFile,
+	}, func(childCtx context.Context) error {
+		result, err := finalModules.flagUnchangedModules(stack.terragruntOptions)
+		if err != nil {
+			return err
+		}
+
 		finalModules = result
 
 		return nil
@@ -443,18 +697,70 @@ func (stack *Stack) ResolveTerraformModules(ctx context.Context, terragruntConfi
 		return nil, err
 	}
 
+	if stack.terragruntOptions.ErrorOnIsolatedModules {
+		err = telemetry.Telemetry(ctx, stack.terragruntOptions, "check_for_isolated_modules", map[string]interface{}{
+			"working_dir": stack.terragruntOptions.WorkingDir,
+		}, func(childCtx context.Context) error {
+			return finalModules.CheckForIsolatedModules()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stack.terragruntOptions.CheckStateKeyCollisions {
+		err = telemetry.Telemetry(ctx, stack.terragruntOptions, "check_for_state_key_collisions", map[string]interface{}{
+			"working_dir": stack.terragruntOptions.WorkingDir,
+		}, func(childCtx context.Context) error {
+			return finalModules.CheckForStateKeyCollisions()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stack.terragruntOptions.StrictConfig {
+		err = telemetry.Telemetry(ctx, stack.terragruntOptions, "check_for_empty_configs", map[string]interface{}{
+			"working_dir": stack.terragruntOptions.WorkingDir,
+		}, func(childCtx context.Context) error {
+			return finalModules.CheckForEmptyConfigs()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return finalModules, nil
 }
 
+// needsLabels returns true if the labels of the modules are used, so that they have to be decoded from their configs:
+// to match the ModuleSelector, to find the account of the modules with ParallelismPerAccount, to write them in the
+// RunSummaryFile, or to show them in the graphs of the stack.
+func (stack *Stack) needsLabels() bool {
+	opts := stack.terragruntOptions
+
+	return opts.ModuleSelector != "" || opts.ParallelismPerAccount > 0 || opts.RunSummaryFile != "" || stack.graphLabels
+}
+
 // Go through each of the given Terragrunt configuration files and resolve the module that configuration file represents
 // into a TerraformModule struct. Note that this method will NOT fill in the Dependencies field of the TerraformModule
-// struct (see the crosslinkDependencies method for that). Return a map from module path to TerraformModule struct.
-func (stack *Stack) resolveModules(ctx context.Context, canonicalTerragruntConfigPaths []string, howTheseModulesWereFound string) (TerraformModulesMap, error) {
+// struct (see the crosslinkDependencies method for that). The configuration files that don't exist are handled as set by
+// onMissingConfig. Return a map from module path to TerraformModule struct.
+func (stack *Stack) resolveModules(ctx context.Context, canonicalTerragruntConfigPaths []string, howTheseModulesWereFound, onMissingConfig string) (TerraformModulesMap, error) {
 	modulesMap := TerraformModulesMap{}
 
 	for _, terragruntConfigPath := range canonicalTerragruntConfigPaths {
-		if !util.FileExists(terragruntConfigPath) {
-			return nil, ProcessingModuleError{UnderlyingError: os.ErrNotExist, ModulePath: terragruntConfigPath, HowThisModuleWasFound: howTheseModulesWereFound}
+		if !stack.discoverer.ConfigExists(terragruntConfigPath) {
+			switch onMissingConfig {
+			case options.OnMissingConfigError:
+				return nil, ProcessingModuleError{UnderlyingError: os.ErrNotExist, ModulePath: terragruntConfigPath, HowThisModuleWasFound: howTheseModulesWereFound}
+			case options.OnMissingConfigSkip:
+				stack.terragruntOptions.Logger.Debugf("Skipping %s as it has no Terragrunt config. How it was found: %s", filepath.Dir(terragruntConfigPath), howTheseModulesWereFound)
+			default:
+				stack.terragruntOptions.Logger.Warnf("Skipping %s as it has no Terragrunt config. How it was found: %s", filepath.Dir(terragruntConfigPath), howTheseModulesWereFound)
+			}
+
+			continue
 		}
 
 		var module *TerraformModule
@@ -548,16 +854,28 @@ func (stack *Stack) resolveTerraformModule(ctx context.Context, terragruntConfig
 		return &TerraformModule{Path: modulePath, TerragruntOptions: opts, FlagExcluded: true}, nil
 	}
 
+	decodeList := []config.PartialDecodeSectionType{
+		// Need for initializing the modules
+		config.TerraformSource,
+
+		// Need for parsing out the dependencies
+		config.DependenciesBlock,
+		config.DependencyBlock,
+
+		// Need for the behaviors of the runner enabled for the module
+		config.TerragruntFeatures,
+
+		// Need for the resources the module uses while it runs
+		config.TerragruntResourceTags,
+	}
+
+	if stack.needsLabels() {
+		decodeList = append(decodeList, config.TerragruntLabels)
+	}
+
 	parseCtx := config.NewParsingContext(ctx, opts).
 		WithParseOption(stack.parserOptions).
-		WithDecodeList(
-			// Need for initializing the modules
-			config.TerraformSource,
-
-			// Need for parsing out the dependencies
-			config.DependenciesBlock,
-			config.DependencyBlock,
-		)
+		WithDecodeList(decodeList...)
 
 	// Credentials have to be acquired before the config is parsed, as the config may contain interpolation functions
 	// that require credentials to be available.
@@ -614,12 +932,25 @@ func (stack *Stack) resolveTerraformModule(ctx context.Context, terragruntConfig
 		return nil, err
 	}
 
-	if (terragruntConfig.Terraform == nil || terragruntConfig.Terraform.Source == nil || *terragruntConfig.Terraform.Source == "") && matches == nil {
+	// With StrictConfig, the module is kept so that it is reported by CheckForEmptyConfigs, unless it is excluded
+	if (terragruntConfig.Terraform == nil || terragruntConfig.Terraform.Source == nil || *terragruntConfig.Terraform.Source == "") && matches == nil && !stack.terragruntOptions.StrictConfig {
 		stack.terragruntOptions.Logger.Debugf("Module %s does not have an associated terraform configuration and will be skipped.", filepath.Dir(terragruntConfigPath))
 		return nil, nil
 	}
 
-	return &TerraformModule{Stack: stack, Path: modulePath, Config: *terragruntConfig, TerragruntOptions: opts}, nil
+	consumed, err := consumedOutputs(terragruntConfigPath, modulePath, terragruntConfig.TerragruntDependencies)
+	if err != nil {
+		return nil, errors.New(ProcessingModuleError{
+			UnderlyingError:       err,
+			HowThisModuleWasFound: howThisModuleWasFound,
+			ModulePath:            terragruntConfigPath,
+		})
+	}
+
+	module := &TerraformModule{Stack: stack, Path: modulePath, Config: *terragruntConfig, TerragruntOptions: opts, Labels: terragruntConfig.Labels, Features: terragruntConfig.Features, ResourceTags: terragruntConfig.ResourceTags, ConsumedOutputs: consumed}
+	module.warnUnknownFeatures()
+
+	return module, nil
 }
 
 // resolveDependenciesForModule looks through the dependencies of the given module and resolve the dependency paths listed in the module's config.
@@ -656,7 +987,9 @@ func (stack *Stack) resolveDependenciesForModule(ctx context.Context, module *Te
 
 	howThesePathsWereFound := fmt.Sprintf("dependency of module at '%s'", module.Path)
 
-	result, err := stack.resolveModules(ctx, externalTerragruntConfigPaths, howThesePathsWereFound)
+	// the dependencies declared in the config must have a config, whatever OnMissingConfig is, or the module would run
+	// without waiting for them
+	result, err := stack.resolveModules(ctx, externalTerragruntConfigPaths, howThesePathsWereFound, options.OnMissingConfigError)
 	if err != nil {
 		return nil, err
 	}
@@ -732,11 +1065,9 @@ func (stack *Stack) ListStackDependentModules() map[string][]string {
 	var dependentModules = make(map[string][]string)
 
 	// build initial mapping of dependent modules
-	for _, module := range stack.Modules {
-		if len(module.Dependencies) != 0 {
-			for _, dep := range module.Dependencies {
-				dependentModules[dep.Path] = util.RemoveDuplicatesFromList(append(dependentModules[dep.Path], module.Path))
-			}
+	for module, dependents := range stack.Modules.Dependents() {
+		for _, dependent := range dependents {
+			dependentModules[module.Path] = util.RemoveDuplicatesFromList(append(dependentModules[module.Path], dependent.Path))
 		}
 	}
 
@@ -1,11 +1,16 @@
 package configstack_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gruntwork-io/terragrunt/codegen"
@@ -13,8 +18,11 @@ import (
 	"github.com/gruntwork-io/terragrunt/configstack"
 	"github.com/gruntwork-io/terragrunt/internal/errors"
 	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/pkg/log"
+	"github.com/gruntwork-io/terragrunt/pkg/log/format"
 	"github.com/gruntwork-io/terragrunt/terraform"
 	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	goerrors "github.com/go-errors/errors"
@@ -60,6 +68,604 @@ func TestFindStackInSubfolders(t *testing.T) {
 	}
 }
 
+func TestRunModulesMatchingSelector(t *testing.T) {
+	t.Parallel()
+
+	configs := map[string]string{
+		"vpc":      "terraform {\n  source = \"test\"\n}\nlabels = { team = \"platform\" }\n",
+		"payments": "terraform {\n  source = \"test\"\n}\nlabels = { team = \"payments\" }\ndependencies {\n  paths = [\"../vpc\"]\n}\n",
+		"search":   "terraform {\n  source = \"test\"\n}\nlabels = { team = \"search\" }\n",
+	}
+
+	testCases := []struct {
+		name                 string
+		includeDependencies  bool
+		expectedExecutedDirs []string
+	}{
+		{"selected team only", false, []string{"payments"}},
+		{"with dependencies", true, []string{"payments", "vpc"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempFolder := t.TempDir()
+
+			for dir, contents := range configs {
+				createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+				err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+				require.NoError(t, err)
+			}
+
+			opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+			require.NoError(t, err)
+
+			opts.WorkingDir = tempFolder
+			opts.TerraformCommand = terraform.CommandNamePlan
+			opts.TerraformCliArgs = []string{terraform.CommandNamePlan}
+			opts.ModuleSelector = "team=payments"
+			opts.ModuleSelectorIncludeDeps = testCase.includeDependencies
+
+			var (
+				executedDirs []string
+				mu           sync.Mutex
+			)
+
+			opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				executedDirs = append(executedDirs, filepath.Base(opts.WorkingDir))
+
+				return nil
+			}
+
+			stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+			require.NoError(t, err)
+
+			err = stack.Run(context.Background(), opts)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, testCase.expectedExecutedDirs, executedDirs)
+		})
+	}
+}
+
+func TestRunModulesCustomCommand(t *testing.T) {
+	t.Parallel()
+
+	configs := map[string]string{
+		"vpc": "terraform {\n  source = \"test\"\n}\n",
+		"app": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../vpc\"]\n}\n",
+		"dns": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../app\"]\n}\n",
+	}
+
+	tempFolder := t.TempDir()
+
+	for dir, contents := range configs {
+		createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+		err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	opts.WorkingDir = tempFolder
+	opts.TerraformCommand = terraform.CommandNamePlan
+	opts.TerraformCliArgs = []string{terraform.CommandNamePlan}
+	opts.CustomCommand = []string{"grep", "-r", "something"}
+
+	var (
+		executed []string
+		mu       sync.Mutex
+	)
+
+	opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		executed = append(executed, filepath.Base(opts.WorkingDir)+": "+strings.Join(opts.CustomCommand, " "))
+
+		return nil
+	}
+
+	stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+	require.NoError(t, err)
+
+	err = stack.Run(context.Background(), opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"vpc: grep -r something", "app: grep -r something", "dns: grep -r something"}, executed)
+}
+
+func TestRunModulesShard(t *testing.T) {
+	t.Parallel()
+
+	configs := map[string]string{
+		"vpc":      "terraform {\n  source = \"test\"\n}\n",
+		"dns":      "terraform {\n  source = \"test\"\n}\n",
+		"database": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../vpc\"]\n}\n",
+		"cache":    "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../vpc\"]\n}\n",
+		"app":      "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../database\", \"../cache\", \"../dns\"]\n}\n",
+		"frontend": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../app\"]\n}\n",
+	}
+
+	// Each run gets its own copy of the stack, as each shard would on its own machine.
+	runShard := func(index int) []string {
+		tempFolder := t.TempDir()
+
+		for dir, contents := range configs {
+			createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+			err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+			require.NoError(t, err)
+		}
+
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.WorkingDir = tempFolder
+		opts.TerraformCommand = terraform.CommandNamePlan
+		opts.TerraformCliArgs = []string{terraform.CommandNamePlan}
+		opts.ShardIndex = index
+		opts.ShardTotal = 2
+
+		var (
+			executedDirs []string
+			mu           sync.Mutex
+		)
+
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			executedDirs = append(executedDirs, filepath.Base(opts.WorkingDir))
+
+			return nil
+		}
+
+		stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+		require.NoError(t, err)
+
+		err = stack.Run(context.Background(), opts)
+		require.NoError(t, err)
+
+		sort.Strings(executedDirs)
+
+		return executedDirs
+	}
+
+	shard0 := runShard(0)
+	shard1 := runShard(1)
+
+	assert.NotEmpty(t, shard0)
+	assert.NotEmpty(t, shard1)
+	assert.ElementsMatch(t, []string{"app", "cache", "database", "dns", "frontend", "vpc"}, append(slices.Clone(shard0), shard1...), "the shards should partition the modules")
+	assert.Equal(t, shard0, runShard(0), "the partition should be stable")
+}
+
+func TestRunModulesRerunFailedFrom(t *testing.T) {
+	t.Parallel()
+
+	configs := map[string]string{
+		"vpc":      "terraform {\n  source = \"test\"\n}\n",
+		"dns":      "terraform {\n  source = \"test\"\n}\n",
+		"database": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../vpc\"]\n}\n",
+		"app":      "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../database\", \"../dns\"]\n}\n",
+		"frontend": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../app\"]\n}\n",
+	}
+
+	tempFolder := t.TempDir()
+
+	for dir, contents := range configs {
+		createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+		err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+
+	// Shared by the runs, as the modules resolved by the first run are reused by the second one.
+	var (
+		failing      string
+		executedDirs []string
+		mu           sync.Mutex
+	)
+
+	run := func(rerunFailedFrom string, failingDir string, runDependentsOnSkippedDependency bool) ([]string, error) {
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.WorkingDir = tempFolder
+		opts.TerraformCommand = terraform.CommandNamePlan
+		opts.TerraformCliArgs = []string{terraform.CommandNamePlan}
+		opts.RunSummaryFile = summaryFile
+		opts.RerunFailedFrom = rerunFailedFrom
+		opts.RunDependentsOnSkippedDependency = runDependentsOnSkippedDependency
+
+		failing, executedDirs = failingDir, nil
+
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			executedDirs = append(executedDirs, filepath.Base(opts.WorkingDir))
+
+			if filepath.Base(opts.WorkingDir) == failing {
+				return errors.New("database is unreachable")
+			}
+
+			return nil
+		}
+
+		stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+		require.NoError(t, err)
+
+		err = stack.Run(context.Background(), opts)
+
+		sort.Strings(executedDirs)
+
+		return executedDirs, err
+	}
+
+	executedDirs, err := run("", "database", true)
+	require.Error(t, err)
+	assert.Equal(t, []string{"database", "dns", "vpc"}, executedDirs)
+
+	summary, err := configstack.ReadRunSummary(summaryFile)
+	require.NoError(t, err)
+	assert.Equal(t, configstack.ModuleFailed, summary.Modules[filepath.Join(tempFolder, "database")].Status)
+	assert.Equal(t, configstack.ModuleBlocked, summary.Modules[filepath.Join(tempFolder, "frontend")].Status)
+
+	// only the failed module and the modules it blocked run again
+	rerunFrom := filepath.Join(t.TempDir(), "previous-summary.json")
+	require.NoError(t, summary.WriteFile(rerunFrom))
+
+	executedDirs, err = run(rerunFrom, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "database", "frontend"}, executedDirs)
+
+	// the modules that succeeded are already applied, so they don't block their dependents as skipped modules would
+	executedDirs, err = run(rerunFrom, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "database", "frontend"}, executedDirs)
+}
+
+func TestFindStackModuleFeatures(t *testing.T) {
+	t.Parallel()
+
+	configs := map[string]string{
+		"vpc": "terraform {\n  source = \"test\"\n}\n",
+		"app": "terraform {\n  source = \"test\"\n}\nfeatures = {\n  isolate_workdir = true\n  new_plan_format = true\n}\n",
+	}
+
+	tempFolder := t.TempDir()
+
+	for dir, contents := range configs {
+		createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+		err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	var output bytes.Buffer
+
+	formatter := format.NewFormatter()
+	formatter.DisableColors = true
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	opts.Logger = log.New(log.WithOutput(&output), log.WithLevel(log.WarnLevel), log.WithFormatter(formatter))
+
+	stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+	require.NoError(t, err)
+
+	features := map[string]map[string]bool{}
+	for _, module := range stack.Modules {
+		features[filepath.Base(module.Path)] = module.Features
+	}
+
+	assert.Equal(t, map[string]map[string]bool{
+		"app": {configstack.FeatureIsolateWorkdir: true, "new_plan_format": true},
+		"vpc": nil,
+	}, features)
+
+	assert.Contains(t, output.String(), `enables the unknown feature "new_plan_format"`)
+	assert.NotContains(t, output.String(), `unknown feature "isolate_workdir"`)
+}
+
+func TestFindStackModuleResourceTags(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := t.TempDir()
+
+	createDirIfNotExist(t, filepath.Join(tempFolder, "app"))
+	err := os.WriteFile(filepath.Join(tempFolder, "app", config.DefaultTerragruntConfigPath), []byte("terraform {\n  source = \"test\"\n}\nresource_tags = {\n  db_connections = 2\n}\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+	require.NoError(t, err)
+
+	require.Len(t, stack.Modules, 1)
+	assert.Equal(t, map[string]int{"db_connections": 2}, stack.Modules[0].ResourceTags)
+}
+
+func TestFindStackConsumedOutputs(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := t.TempDir()
+
+	configs := map[string]string{
+		"vpc": "terraform {\n  source = \"test\"\n}\n",
+		"dns": "terraform {\n  source = \"test\"\n}\n",
+		"app": `terraform {
+  source = "test"
+}
+
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+dependency "dns" {
+  config_path = "../dns"
+}
+
+inputs = {
+  vpc_id     = dependency.vpc.outputs.id
+  subnet_ids = dependency.vpc.outputs["subnet_ids"]
+  zone       = "${dependency.vpc.outputs.id}.${dependency.dns.outputs.zone_name}"
+}
+`,
+	}
+
+	for dir, contents := range configs {
+		createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+		err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+	require.NoError(t, err)
+
+	var graph bytes.Buffer
+	require.NoError(t, stack.Modules.WriteJSON(&graph, opts))
+
+	var document struct {
+		Edges []struct {
+			From       string   `json:"from"`
+			To         string   `json:"to"`
+			OutputKeys []string `json:"output_keys"`
+		} `json:"edges"`
+	}
+
+	require.NoError(t, json.Unmarshal(graph.Bytes(), &document))
+
+	edges := map[string][]string{}
+	for _, edge := range document.Edges {
+		edges[edge.From+" -> "+edge.To] = edge.OutputKeys
+	}
+
+	assert.Equal(t, map[string][]string{
+		"app -> dns": {"zone_name"},
+		"app -> vpc": {"id", "subnet_ids"},
+	}, edges)
+}
+
+func TestFindStackIncludeCycle(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := t.TempDir()
+
+	// app includes the config of vpc, and so its dependency on app
+	configs := map[string]string{
+		"vpc": "terraform {\n  source = \"test\"\n}\ndependency \"app\" {\n  config_path = \"../app\"\n}\n",
+		"app": "include \"vpc\" {\n  path = \"../vpc/terragrunt.hcl\"\n}\n",
+	}
+
+	for dir, contents := range configs {
+		createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+		err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	_, err = configstack.FindStackInSubfolders(context.Background(), opts)
+
+	var cycleErr configstack.IncludeCycleError
+	require.ErrorAs(t, err, &cycleErr)
+
+	appPath := filepath.Join(tempFolder, "app")
+	assert.Equal(t, appPath, cycleErr.Module)
+	assert.Equal(t, filepath.Join(tempFolder, "vpc", config.DefaultTerragruntConfigPath), cycleErr.Include)
+	assert.Equal(t, configstack.DependencyCycleError{appPath, appPath}, cycleErr.Cycle)
+}
+
+func TestRunModulesRunChangedOnly(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := t.TempDir()
+
+	writeConfigs := func(configs map[string]string) {
+		for dir, contents := range configs {
+			createDirIfNotExist(t, filepath.Join(tempFolder, dir))
+			err := os.WriteFile(filepath.Join(tempFolder, dir, config.DefaultTerragruntConfigPath), []byte(contents), os.ModePerm)
+			require.NoError(t, err)
+		}
+	}
+
+	writeConfigs(map[string]string{
+		"vpc": "terraform {\n  source = \"test\"\n}\n",
+		"dns": "terraform {\n  source = \"test\"\n}\n",
+		"app": "terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../vpc\"]\n}\n",
+	})
+
+	hashFile := filepath.Join(t.TempDir(), "hashes.json")
+
+	// Shared by the runs, as the modules resolved by the first run are reused by the next ones.
+	var (
+		executedDirs []string
+		mu           sync.Mutex
+	)
+
+	run := func(runChangedOnly, runDependents bool) []string {
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.TerraformCommand = terraform.CommandNamePlan
+		opts.TerraformCliArgs = []string{terraform.CommandNamePlan}
+		opts.ModuleHashFile = hashFile
+		opts.RunChangedOnly = runChangedOnly
+		opts.RunDependentsOfChangedModules = runDependents
+
+		executedDirs = nil
+
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			executedDirs = append(executedDirs, filepath.Base(opts.WorkingDir))
+
+			return nil
+		}
+
+		stack, err := configstack.FindStackInSubfolders(context.Background(), opts)
+		require.NoError(t, err)
+		require.NoError(t, stack.Run(context.Background(), opts))
+
+		sort.Strings(executedDirs)
+
+		return executedDirs
+	}
+
+	// the first run records the hashes of all the modules
+	assert.Equal(t, []string{"app", "dns", "vpc"}, run(false, false))
+
+	writeConfigs(map[string]string{
+		"vpc": "terraform {\n  source = \"test\"\n}\ninputs = {\n  cidr = \"10.0.0.0/16\"\n}\n",
+	})
+
+	// dns is unchanged, and app only runs as a dependent of vpc
+	assert.Equal(t, []string{"app", "vpc"}, run(true, true))
+
+	// the hash of vpc was recorded by the previous run
+	assert.Empty(t, run(true, false))
+
+	// the terraform code of a module is part of its hash, unlike the files generated in its .terragrunt-cache
+	err := os.WriteFile(filepath.Join(tempFolder, "dns", "main.tf"), []byte("resource \"null_resource\" \"dns\" {}\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	createDirIfNotExist(t, filepath.Join(tempFolder, "vpc", util.TerragruntCacheDir))
+	err = os.WriteFile(filepath.Join(tempFolder, "vpc", util.TerragruntCacheDir, "main.tf"), []byte("# generated\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dns"}, run(true, false))
+	assert.Empty(t, run(true, false))
+
+	// so is the code of a local terraform source
+	createDirIfNotExist(t, filepath.Join(tempFolder, "modules", "dns"))
+	err = os.WriteFile(filepath.Join(tempFolder, "modules", "dns", "main.tf"), []byte("variable \"zone\" {}\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	writeConfigs(map[string]string{
+		"dns": "terraform {\n  source = \"../modules//dns\"\n}\n",
+	})
+
+	assert.Equal(t, []string{"dns"}, run(true, false))
+
+	err = os.WriteFile(filepath.Join(tempFolder, "modules", "dns", "main.tf"), []byte("variable \"zone\" {\n  type = string\n}\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dns"}, run(true, false))
+	assert.Empty(t, run(true, false))
+}
+
+func TestRunModulesInvalidShard(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := createTempFolder(t)
+	writeDummyTerragruntConfigs(t, tempFolder, []string{"/a/" + config.DefaultTerragruntConfigPath})
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	opts.WorkingDir = tempFolder
+	opts.ShardIndex = 2
+	opts.ShardTotal = 2
+
+	_, err = configstack.FindStackInSubfolders(context.Background(), opts)
+
+	var shardErr configstack.InvalidShardError
+	require.ErrorAs(t, err, &shardErr)
+	assert.Equal(t, configstack.InvalidShardError{Index: 2, Total: 2}, shardErr)
+}
+
+func TestFindStackInSubfoldersDecodesLabelsOnlyWhenUsed(t *testing.T) {
+	t.Parallel()
+
+	tempFolder, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	createDirIfNotExist(t, filepath.Join(tempFolder, "vpc"))
+	err = os.WriteFile(filepath.Join(tempFolder, "vpc", config.DefaultTerragruntConfigPath), []byte("terraform {\n  source = \"test\"\n}\nlabels = { team = \"platform\" }\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		selector       string
+		stackOptions   []configstack.Option
+		expectedLabels map[string]string
+	}{
+		{"unused", "", nil, nil},
+		{"selector", "team=platform", nil, map[string]string{"team": "platform"}},
+		{"graph labels", "", []configstack.Option{configstack.WithGraphLabels()}, map[string]string{"team": "platform"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+			require.NoError(t, err)
+
+			opts.WorkingDir = tempFolder
+			opts.ModuleSelector = testCase.selector
+
+			stack, err := configstack.FindStackInSubfolders(context.Background(), opts, testCase.stackOptions...)
+			require.NoError(t, err)
+
+			require.Len(t, stack.Modules, 1)
+			assert.Equal(t, testCase.expectedLabels, stack.Modules[0].Labels)
+		})
+	}
+}
+
+func TestRunModulesInvalidSelector(t *testing.T) {
+	t.Parallel()
+
+	tempFolder := createTempFolder(t)
+	writeDummyTerragruntConfigs(t, tempFolder, []string{"/a/" + config.DefaultTerragruntConfigPath})
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	opts.WorkingDir = tempFolder
+	opts.ModuleSelector = "team"
+
+	_, err = configstack.FindStackInSubfolders(context.Background(), opts)
+
+	var selectorErr configstack.InvalidModuleSelectorError
+	require.ErrorAs(t, err, &selectorErr)
+}
+
 func TestGetModuleRunGraphApplyOrder(t *testing.T) {
 	t.Parallel()
 
@@ -111,6 +717,107 @@ func TestGetModuleRunGraphDestroyOrder(t *testing.T) {
 
 }
 
+func TestRunPlanThenApply(t *testing.T) {
+	t.Parallel()
+
+	var (
+		executedCommands = map[string][][]string{}
+		mu               sync.Mutex
+	)
+
+	mockOptions := func(path string, planExitCode int) *options.TerragruntOptions {
+		opts, err := options.NewTerragruntOptionsForTest(path)
+		require.NoError(t, err)
+
+		opts.TerraformCliArgs = []string{terraform.CommandNameApply, "-var-file=prod.tfvars", "-auto-approve"}
+		opts.RunTerragrunt = func(_ context.Context, opts *options.TerragruntOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			executedCommands[path] = append(executedCommands[path], opts.TerraformCliArgs)
+
+			if opts.TerraformCommand == terraform.CommandNamePlan && planExitCode != 0 {
+				return newExitCodeError(planExitCode)
+			}
+
+			return nil
+		}
+
+		return opts
+	}
+
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: mockOptions("a", 2)}
+	moduleB := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b", Dependencies: configstack.TerraformModules{moduleA}, TerragruntOptions: mockOptions("b", 0)}
+	moduleC := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c", Dependencies: configstack.TerraformModules{moduleB}, TerragruntOptions: mockOptions("c", 2)}
+
+	var confirmedModules []string
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	reportsDir := t.TempDir()
+
+	opts.TerraformCommand = terraform.CommandNameApply
+	opts.RunAllPlanThenApply = true
+	opts.RunSummaryFile = filepath.Join(reportsDir, "summary.json")
+	opts.JUnitReportFile = filepath.Join(reportsDir, "junit.xml")
+	opts.RunAllConfirmApply = func(_ context.Context, modulesWithChanges []string) (bool, error) {
+		confirmedModules = modulesWithChanges
+		return true, nil
+	}
+
+	stack := configstack.NewStack(opts)
+	stack.Modules = configstack.TerraformModules{moduleA, moduleB, moduleC}
+
+	err = stack.Run(context.Background(), opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "c"}, confirmedModules)
+
+	// the arguments given to apply are passed on to plan, except for those plan doesn't accept
+	planArgs := []string{terraform.CommandNamePlan, "-input=false", "-detailed-exitcode", "-out=" + terraform.TerraformPlanFile, "-var-file=prod.tfvars"}
+	applyArgs := []string{terraform.CommandNameApply, "-input=false", terraform.TerraformPlanFile}
+
+	assert.Equal(t, [][]string{planArgs, applyArgs}, executedCommands["a"])
+	assert.Equal(t, [][]string{planArgs}, executedCommands["b"])
+	assert.Equal(t, [][]string{planArgs, applyArgs}, executedCommands["c"])
+
+	// each phase writes its own files
+	for _, name := range []string{"summary.plan.json", "summary.apply.json", "junit.plan.xml", "junit.apply.xml"} {
+		assert.FileExists(t, filepath.Join(reportsDir, name))
+	}
+
+	assert.NoFileExists(t, opts.RunSummaryFile)
+	assert.NoFileExists(t, opts.JUnitReportFile)
+}
+
+func TestRunPlanThenApplyNotConfirmed(t *testing.T) {
+	t.Parallel()
+
+	planRan := false
+	moduleOpts := optionsWithMockTerragruntCommand(t, "a", newExitCodeError(2), &planRan)
+	moduleA := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a", TerragruntOptions: moduleOpts}
+
+	opts, err := options.NewTerragruntOptionsForTest("")
+	require.NoError(t, err)
+
+	opts.RunAllConfirmApply = func(_ context.Context, _ []string) (bool, error) {
+		moduleOpts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+			t.Fatal("No module should be applied when the apply is not confirmed")
+			return nil
+		}
+
+		return false, nil
+	}
+
+	stack := configstack.NewStack(opts)
+	stack.Modules = configstack.TerraformModules{moduleA}
+
+	err = stack.RunPlanThenApply(context.Background(), opts)
+	require.NoError(t, err)
+	assert.True(t, planRan)
+}
+
 func createTestStack() *configstack.Stack {
 	// Create the following module stack:
 	// - account-baseline (excluded)
@@ -309,13 +1016,16 @@ func TestResolveTerraformModulesReadConfigFromParentConfig(t *testing.T) {
 		localsConfigs[name] = map[string]interface{}{
 			"dependencies":                  interface{}(nil),
 			"download_dir":                  "",
+			"features":                      interface{}(nil),
 			"generate":                      map[string]interface{}{},
 			"iam_assume_role_duration":      interface{}(nil),
 			"iam_assume_role_session_name":  "",
 			"iam_role":                      "",
 			"iam_web_identity_token":        "",
 			"inputs":                        interface{}(nil),
+			"labels":                        interface{}(nil),
 			"locals":                        cfg.Locals,
+			"resource_tags":                 interface{}(nil),
 			"retry_max_attempts":            interface{}(nil),
 			"retry_sleep_interval_sec":      interface{}(nil),
 			"retryable_errors":              interface{}(nil),
@@ -883,6 +1593,27 @@ func TestResolveTerraformModulesMultipleModulesWithDependencies(t *testing.T) {
 	assertModuleListsEqual(t, expected, actualModules)
 }
 
+func TestResolveTerraformModulesErrorOnIsolatedModules(t *testing.T) {
+	t.Parallel()
+
+	opts := cloneOptions(t, mockOptions, mockOptions.TerragruntConfigPath)
+	opts.ErrorOnIsolatedModules = true
+
+	configPaths := []string{"../test/fixtures/modules/module-a/" + config.DefaultTerragruntConfigPath, "../test/fixtures/modules/module-c/" + config.DefaultTerragruntConfigPath}
+
+	_, err := configstack.NewStack(opts).ResolveTerraformModules(context.Background(), configPaths)
+	require.NoError(t, err)
+
+	// module-e neither depends on nor is a dependency of the other modules
+	configPaths = append(configPaths, "../test/fixtures/modules/module-e/"+config.DefaultTerragruntConfigPath)
+
+	_, err = configstack.NewStack(opts).ResolveTerraformModules(context.Background(), configPaths)
+
+	var isolatedErr configstack.IsolatedModulesError
+	require.ErrorAs(t, err, &isolatedErr)
+	assert.Equal(t, configstack.IsolatedModulesError{canonical(t, "../test/fixtures/modules/module-e")}, isolatedErr)
+}
+
 func TestResolveTerraformModulesMultipleModulesWithMixedDependencies(t *testing.T) {
 	t.Parallel()
 
@@ -1112,6 +1843,104 @@ func TestResolveTerraformModulesInvalidPaths(t *testing.T) {
 	require.True(t, os.IsNotExist(unwrapped), "Expected a file not exists error but got %v", processingModuleError.UnderlyingError)
 }
 
+type fakeDiscoverer struct {
+	configFiles []string
+	missing     map[string]bool
+}
+
+func (discoverer fakeDiscoverer) FindConfigFiles(string, *options.TerragruntOptions) ([]string, error) {
+	return discoverer.configFiles, nil
+}
+
+func (discoverer fakeDiscoverer) ConfigExists(configPath string) bool {
+	return !discoverer.missing[configPath]
+}
+
+func TestFindStackInSubfoldersOnMissingConfig(t *testing.T) {
+	t.Parallel()
+
+	tempFolder, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	createDirIfNotExist(t, filepath.Join(tempFolder, "app"))
+	createDirIfNotExist(t, filepath.Join(tempFolder, "db"))
+	createDirIfNotExist(t, filepath.Join(tempFolder, "vpc"))
+
+	appConfigPath := filepath.Join(tempFolder, "app", config.DefaultTerragruntConfigPath)
+	dbConfigPath := filepath.Join(tempFolder, "db", config.DefaultTerragruntConfigPath)
+	vpcConfigPath := filepath.Join(tempFolder, "vpc", config.DefaultTerragruntConfigPath)
+
+	err = os.WriteFile(appConfigPath, []byte("terraform {\n  source = \"test\"\n}\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	err = os.WriteFile(dbConfigPath, []byte("terraform {\n  source = \"test\"\n}\ndependencies {\n  paths = [\"../vpc\"]\n}\n"), os.ModePerm)
+	require.NoError(t, err)
+
+	findStack := func(t *testing.T, discoverer fakeDiscoverer, onMissingConfig string) (*configstack.Stack, string, error) {
+		t.Helper()
+
+		var output bytes.Buffer
+
+		formatter := format.NewFormatter()
+		formatter.DisableColors = true
+
+		opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+		require.NoError(t, err)
+
+		opts.OnMissingConfig = onMissingConfig
+		opts.Logger = log.New(log.WithOutput(&output), log.WithLevel(log.WarnLevel), log.WithFormatter(formatter))
+
+		stack, err := configstack.FindStackInSubfolders(context.Background(), opts, configstack.WithDiscoverer(discoverer))
+
+		return stack, output.String(), err
+	}
+
+	// vpc looks like a module to the discoverer, but has no config
+	discoverer := fakeDiscoverer{
+		configFiles: []string{appConfigPath, vpcConfigPath},
+		missing:     map[string]bool{vpcConfigPath: true},
+	}
+
+	for _, onMissingConfig := range []string{options.OnMissingConfigWarn, options.OnMissingConfigSkip} {
+		stack, output, err := findStack(t, discoverer, onMissingConfig)
+		require.NoError(t, err, onMissingConfig)
+
+		require.Len(t, stack.Modules, 1, onMissingConfig)
+		assert.Equal(t, filepath.Join(tempFolder, "app"), stack.Modules[0].Path, onMissingConfig)
+
+		if onMissingConfig == options.OnMissingConfigWarn {
+			assert.Contains(t, output, "Skipping "+filepath.Join(tempFolder, "vpc")+" as it has no Terragrunt config")
+		} else {
+			assert.Empty(t, output)
+		}
+	}
+
+	_, _, err = findStack(t, discoverer, options.OnMissingConfigError)
+
+	var processingModuleError configstack.ProcessingModuleError
+	require.ErrorAs(t, err, &processingModuleError)
+	assert.Equal(t, vpcConfigPath, processingModuleError.ModulePath)
+	require.ErrorIs(t, processingModuleError.UnderlyingError, os.ErrNotExist)
+
+	// a declared dependency without a config is an error whatever OnMissingConfig is, so that db doesn't run without
+	// waiting for vpc
+	discoverer.configFiles = []string{dbConfigPath, vpcConfigPath}
+
+	for _, onMissingConfig := range []string{options.OnMissingConfigWarn, options.OnMissingConfigSkip, options.OnMissingConfigError} {
+		_, _, err = findStack(t, discoverer, onMissingConfig)
+
+		var processingModuleError configstack.ProcessingModuleError
+		require.ErrorAs(t, err, &processingModuleError, onMissingConfig)
+		assert.Equal(t, vpcConfigPath, processingModuleError.ModulePath, onMissingConfig)
+		assert.Contains(t, processingModuleError.HowThisModuleWasFound, "dependency of module", onMissingConfig)
+	}
+
+	_, _, err = findStack(t, discoverer, "ignore")
+
+	var invalidErr configstack.InvalidOnMissingConfigError
+	require.ErrorAs(t, err, &invalidErr)
+}
+
 func TestResolveTerraformModuleNoTerraformConfig(t *testing.T) {
 	t.Parallel()
 
@@ -1200,3 +2029,58 @@ func TestCircularDependencies(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, result)
 	}
 }
+
+func TestResolveTerraformModulesStrictConfig(t *testing.T) {
+	t.Parallel()
+
+	tempFolder, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	configs := map[string]string{
+		// terraform files in the folder of the module
+		"files": "",
+		// terraform files in a local source
+		"local": "terraform {\n  source = \"../modules//app\"\n}\n",
+		// a remote source is not checked
+		"remote": "terraform {\n  source = \"git::https://example.com/modules.git//app\"\n}\n",
+		// a local source without terraform files, even though the module has some in its folder
+		"missing": "terraform {\n  source = \"../modules//missing\"\n}\n",
+		// neither a source nor terraform files
+		"empty": "",
+	}
+
+	var configPaths []string
+
+	for name, content := range configs {
+		createDirIfNotExist(t, filepath.Join(tempFolder, name))
+
+		configPath := filepath.Join(tempFolder, name, config.DefaultTerragruntConfigPath)
+		require.NoError(t, os.WriteFile(configPath, []byte(content), os.ModePerm))
+
+		configPaths = append(configPaths, configPath)
+	}
+
+	createDirIfNotExist(t, filepath.Join(tempFolder, "modules", "app"))
+	createDirIfNotExist(t, filepath.Join(tempFolder, "modules", "missing"))
+
+	for _, path := range []string{"files/main.tf", "missing/main.tf", "modules/app/main.tf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tempFolder, path), []byte(""), os.ModePerm))
+	}
+
+	opts, err := options.NewTerragruntOptionsForTest(filepath.Join(tempFolder, config.DefaultTerragruntConfigPath))
+	require.NoError(t, err)
+
+	_, err = configstack.NewStack(opts).ResolveTerraformModules(context.Background(), configPaths)
+	require.NoError(t, err)
+
+	opts.StrictConfig = true
+
+	_, err = configstack.NewStack(opts).ResolveTerraformModules(context.Background(), configPaths)
+
+	var multiErr *errors.MultiError
+	require.ErrorAs(t, err, &multiErr)
+	assert.Equal(t, []error{
+		configstack.EmptyModuleConfigError(filepath.Join(tempFolder, "empty")),
+		configstack.EmptyModuleConfigError(filepath.Join(tempFolder, "missing")),
+	}, multiErr.WrappedErrors())
+}
@@ -0,0 +1,124 @@
+package configstack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// summaryFile is the JSON form of a RunSummary, written to the RunSummaryFile of the options after the modules have
+// run and read back with RerunFailedFrom:
+//
+//	{
+//	  "modules": [                      // one entry per module of the run, sorted by path
+//	    {
+//	      "path": "/stack/vpc",         // path of the module
+//	      "status": "failed",           // "succeeded", "failed", "blocked" or "skipped"
+//	      "error": "..."                // error of the module, left out if it succeeded
+//	    }
+//	  ]
+//	}
+type summaryFile struct {
+	Modules []summaryFileModule `json:"modules"`
+}
+
+type summaryFileModule struct {
+	Path   string             `json:"path"`
+	Status ModuleResultStatus `json:"status"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// WriteFile writes the statuses of the modules of the summary to the given file as JSON, so that the modules that did
+// not succeed can be run again with RerunFailedFrom.
+func (summary RunSummary) WriteFile(path string) error {
+	fileContents := summaryFile{Modules: make([]summaryFileModule, 0, len(summary.Modules))}
+
+	for modulePath, result := range summary.Modules {
+		module := summaryFileModule{Path: modulePath, Status: result.Status}
+		if result.Err != nil {
+			module.Error = result.Err.Error()
+		}
+
+		fileContents.Modules = append(fileContents.Modules, module)
+	}
+
+	sort.Slice(fileContents.Modules, func(i, j int) bool {
+		return fileContents.Modules[i].Path < fileContents.Modules[j].Path
+	})
+
+	contents, err := json.MarshalIndent(fileContents, "", "  ")
+	if err != nil {
+		return errors.New(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(contents, '\n')); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// ReadRunSummary reads the summary written to the given file by WriteFile. Only the paths, statuses and errors of the
+// modules are restored.
+func ReadRunSummary(path string) (RunSummary, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return RunSummary{}, errors.New(err)
+	}
+
+	var file summaryFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return RunSummary{}, errors.New(InvalidRunSummaryFileError{Path: path, Err: err})
+	}
+
+	summary := RunSummary{Modules: make(map[string]*ModuleResult, len(file.Modules))}
+
+	for _, module := range file.Modules {
+		result := &ModuleResult{Path: module.Path, Status: module.Status}
+		if module.Error != "" {
+			result.Err = errors.New(module.Error)
+		}
+
+		summary.Modules[module.Path] = result
+	}
+
+	return summary, nil
+}
+
+// flagModulesThatSucceeded excludes the modules that succeeded in the run whose summary was written to the
+// RerunFailedFrom file of the given options, so that only the modules that failed, were blocked or were skipped run
+// again. The modules that were not part of that run are left untouched. The excluded modules don't block their
+// dependents without RunDependentsOnSkippedDependency, as they are already applied.
+func (modules TerraformModules) flagModulesThatSucceeded(terragruntOptions *options.TerragruntOptions) (TerraformModules, error) {
+	if terragruntOptions.RerunFailedFrom == "" {
+		return modules, nil
+	}
+
+	summary, err := ReadRunSummary(terragruntOptions.RerunFailedFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, module := range modules {
+		if result, found := summary.Modules[module.Path]; found && result.Status == ModuleSucceeded {
+			module.FlagExcluded = true
+			module.succeededBefore = true
+		}
+	}
+
+	return modules, nil
+}
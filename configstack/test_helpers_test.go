@@ -2,8 +2,12 @@ package configstack_test
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/configstack"
@@ -222,3 +226,218 @@ func assertMultiErrorContains(t *testing.T, actualError error, expectedErrors ..
 		assert.True(t, found, "Couldn't find expected error %v", expectedErr)
 	}
 }
+
+// Create the following module graph, which is shared by the graph related tests:
+//
+//	h -> g -> e -> a
+//	|            /
+//	 --> f -> b
+//	|
+//	 --> c
+//
+//	d (no dependencies)
+func createGraphTestModules() configstack.TerraformModules {
+	a := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "a"}
+	b := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "b"}
+	c := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "c"}
+	d := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "d"}
+	e := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "e", Dependencies: configstack.TerraformModules{a}}
+	f := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "f", Dependencies: configstack.TerraformModules{a, b}}
+	g := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "g", Dependencies: configstack.TerraformModules{e}}
+	h := &configstack.TerraformModule{Stack: &configstack.Stack{}, Path: "h", Dependencies: configstack.TerraformModules{g, f, c}}
+
+	return configstack.TerraformModules{a, b, c, d, e, f, g, h}
+}
+
+// Return the edges of the given modules as a map from module path to the sorted paths of its dependencies
+func dependencyEdges(modules configstack.TerraformModules) map[string][]string {
+	edges := map[string][]string{}
+
+	for _, module := range modules {
+		dependencies := []string{}
+		for _, dependency := range module.Dependencies {
+			dependencies = append(dependencies, dependency.Path)
+		}
+
+		sort.Strings(dependencies)
+		edges[module.Path] = dependencies
+	}
+
+	return edges
+}
+
+// Return the paths of the given modules
+func modulePaths(modules configstack.TerraformModules) []string {
+	paths := []string{}
+	for _, module := range modules {
+		paths = append(paths, module.Path)
+	}
+
+	return paths
+}
+
+// Return the paths of the modules of each of the given execution levels
+func levelPaths(levels [][]*configstack.TerraformModule) [][]string {
+	paths := [][]string{}
+
+	for _, level := range levels {
+		paths = append(paths, modulePaths(level))
+	}
+
+	return paths
+}
+
+// Convert the given module groups to execution levels
+func moduleGroups(groups []configstack.TerraformModules) [][]*configstack.TerraformModule {
+	levels := [][]*configstack.TerraformModule{}
+	for _, group := range groups {
+		levels = append(levels, group)
+	}
+
+	return levels
+}
+
+// exitCodeError is returned by mock terragrunt commands to simulate a terraform process finishing with a specific exit
+// code
+type exitCodeError int
+
+func (err exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", int(err))
+}
+
+func (err exitCodeError) ExitStatus() (int, error) {
+	return int(err), nil
+}
+
+// Return an error that carries the given exit code the same way a failed terraform process does
+func newExitCodeError(exitCode int) error {
+	return errors.New(exitCodeError(exitCode))
+}
+
+// executionLog records the order in which the mock terragrunt commands of modules are executed
+type executionLog struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (log *executionLog) record(path string) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	log.paths = append(log.paths, path)
+}
+
+// Return the position at which the module with the given path was executed, or -1 if it was not executed
+func (log *executionLog) index(path string) int {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return slices.Index(log.paths, path)
+}
+
+// Create a mock TerragruntOptions object and configure its RunTerragrunt command to record its execution in the given
+// log and return the given error object.
+func optionsWithMockTerragruntCommandLog(t *testing.T, terragruntConfigPath string, toReturnFromTerragruntCommand error, log *executionLog) *options.TerragruntOptions {
+	t.Helper()
+
+	opts, err := options.NewTerragruntOptionsForTest(terragruntConfigPath)
+	if err != nil {
+		t.Fatalf("Error creating terragrunt options for test %v", err)
+	}
+	opts.RunTerragrunt = func(_ context.Context, _ *options.TerragruntOptions) error {
+		log.record(terragruntConfigPath)
+		return toReturnFromTerragruntCommand
+	}
+	return opts
+}
+
+// fakeClock is a Clock whose time only moves forward when Advance is called
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+
+	// Receives a value every time a timer is created, so tests can wait for the code under test to start waiting.
+	timerCreated chan struct{}
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		timerCreated: make(chan struct{}, 100),
+	}
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	return clock.now
+}
+
+func (clock *fakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	clock.timers = append(clock.timers, fakeTimer{deadline: clock.now.Add(d), ch: ch})
+	clock.timerCreated <- struct{}{}
+
+	return ch
+}
+
+func (clock *fakeClock) Sleep(d time.Duration) {
+	<-clock.After(d)
+}
+
+// Advance moves the time of the clock forward, firing all the timers whose deadline has passed
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	clock.now = clock.now.Add(d)
+
+	pending := []fakeTimer{}
+
+	for _, timer := range clock.timers {
+		if timer.deadline.After(clock.now) {
+			pending = append(pending, timer)
+			continue
+		}
+
+		timer.ch <- clock.now
+	}
+
+	clock.timers = pending
+}
+
+// createLargeGraphPartialFailureModules creates the modules of the large graph in which large-graph-c fails, which
+// blocks large-graph-d and, through it, large-graph-f, while large-graph-e is assumed already applied.
+func createLargeGraphPartialFailureModules(t *testing.T, errC error) configstack.TerraformModules {
+	t.Helper()
+
+	newModule := func(path string, toReturn error, dependencies ...*configstack.TerraformModule) *configstack.TerraformModule {
+		return &configstack.TerraformModule{
+			Stack:             &configstack.Stack{},
+			Path:              path,
+			Dependencies:      dependencies,
+			TerragruntOptions: optionsWithMockTerragruntCommand(t, path, toReturn, new(bool)),
+		}
+	}
+
+	moduleA := newModule("large-graph-a", nil)
+	moduleB := newModule("large-graph-b", nil, moduleA)
+	moduleC := newModule("large-graph-c", errC, moduleB)
+	moduleD := newModule("large-graph-d", nil, moduleA, moduleB, moduleC)
+	moduleE := newModule("large-graph-e", nil)
+	moduleE.AssumeAlreadyApplied = true
+	moduleF := newModule("large-graph-f", nil, moduleE, moduleD)
+	moduleG := newModule("large-graph-g", nil, moduleE)
+
+	return configstack.TerraformModules{moduleA, moduleB, moduleC, moduleD, moduleE, moduleF, moduleG}
+}
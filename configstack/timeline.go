@@ -0,0 +1,177 @@
+package configstack
+
+import (
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+)
+
+// Timeline is the Gantt-style timeline of a run, written to the RunTimelineFile of the options after the modules have
+// run. Its JSON form is stable, so that it can be rendered by external tools:
+//
+//	{
+//	  "start": "2024-01-01T00:00:00Z",        // time at which the first module started, RFC 3339
+//	  "end": "2024-01-01T00:00:06Z",          // time at which the last module finished, RFC 3339
+//	  "duration_ms": 6000,                    // end - start, in milliseconds
+//	  "modules": [                            // one entry per module that ran, sorted by start time and path
+//	    {
+//	      "path": "/stack/vpc",               // path of the module
+//	      "start": "2024-01-01T00:00:00Z",    // time at which the module started, RFC 3339
+//	      "end": "2024-01-01T00:00:01Z",      // time at which the module finished, RFC 3339
+//	      "offset_ms": 0,                     // start of the module relative to the start of the timeline
+//	      "duration_ms": 1000,                // end - start of the module, in milliseconds
+//	      "status": "succeeded"               // "succeeded" or "failed"
+//	    }
+//	  ]
+//	}
+//
+// Modules that never ran, e.g. because one of their dependencies failed, are not part of the timeline.
+type Timeline struct {
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	DurationMs int64           `json:"duration_ms"`
+	Modules    []TimelineEntry `json:"modules"`
+}
+
+// TimelineEntry is the span of a single module in a Timeline.
+type TimelineEntry struct {
+	Path       string    `json:"path"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	OffsetMs   int64     `json:"offset_ms"`
+	DurationMs int64     `json:"duration_ms"`
+	Status     string    `json:"status"`
+}
+
+// Statuses of the modules in a timeline
+const (
+	TimelineStatusSucceeded = "succeeded"
+	TimelineStatusFailed    = "failed"
+)
+
+// timeline builds the timeline of the modules from their recorded start and end times.
+func (modules RunningModules) timeline() Timeline {
+	timeline := Timeline{Modules: []TimelineEntry{}}
+
+	for _, module := range modules {
+		if module.StartTime.IsZero() {
+			continue
+		}
+
+		status := TimelineStatusSucceeded
+		if module.Err != nil {
+			status = TimelineStatusFailed
+		}
+
+		timeline.Modules = append(timeline.Modules, TimelineEntry{
+			Path:       module.Module.Path,
+			Start:      module.StartTime,
+			End:        module.EndTime,
+			DurationMs: module.EndTime.Sub(module.StartTime).Milliseconds(),
+			Status:     status,
+		})
+
+		if timeline.Start.IsZero() || module.StartTime.Before(timeline.Start) {
+			timeline.Start = module.StartTime
+		}
+
+		if module.EndTime.After(timeline.End) {
+			timeline.End = module.EndTime
+		}
+	}
+
+	sort.Slice(timeline.Modules, func(i, j int) bool {
+		if !timeline.Modules[i].Start.Equal(timeline.Modules[j].Start) {
+			return timeline.Modules[i].Start.Before(timeline.Modules[j].Start)
+		}
+
+		return timeline.Modules[i].Path < timeline.Modules[j].Path
+	})
+
+	for i := range timeline.Modules {
+		timeline.Modules[i].OffsetMs = timeline.Modules[i].Start.Sub(timeline.Start).Milliseconds()
+	}
+
+	timeline.DurationMs = timeline.End.Sub(timeline.Start).Milliseconds()
+
+	return timeline
+}
+
+// writeTimeline writes the timeline of the modules to the given file, as an HTML Gantt chart if the file has the
+// .html extension, and as JSON otherwise.
+func (modules RunningModules) writeTimeline(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.New(err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New(err)
+	}
+	defer file.Close()
+
+	timeline := modules.timeline()
+
+	if strings.EqualFold(filepath.Ext(path), ".html") {
+		if err := timelineHTMLTemplate.Execute(file, timeline); err != nil {
+			return errors.New(err)
+		}
+
+		return nil
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(timeline); err != nil {
+		return errors.New(err)
+	}
+
+	return nil
+}
+
+// percentOf returns the given part of the total as a percentage, for positioning the bars of the HTML timeline.
+func percentOf(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+
+	return float64(part) * 100 / float64(total) //nolint:mnd
+}
+
+var timelineHTMLTemplate = template.Must(template.New("timeline").Funcs(template.FuncMap{
+	"percentOf": percentOf,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Terragrunt run timeline</title>
+<style>
+body { font-family: sans-serif; }
+.row { display: flex; align-items: center; margin: 2px 0; }
+.label { width: 30%; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; font-size: 12px; }
+.track { position: relative; width: 70%; height: 16px; background: #eee; }
+.bar { position: absolute; height: 100%; min-width: 1px; }
+.succeeded { background: #4caf50; }
+.failed { background: #f44336; }
+</style>
+</head>
+<body>
+<h1>Terragrunt run timeline</h1>
+<p>Started at {{ .Start }}, took {{ .DurationMs }}ms.</p>
+{{- $total := .DurationMs }}
+{{- range .Modules }}
+<div class="row">
+<div class="label" title="{{ .Path }}">{{ .Path }}</div>
+<div class="track"><div class="bar {{ .Status }}" style="left: {{ percentOf .OffsetMs $total }}%; width: {{ percentOf .DurationMs $total }}%" title="{{ .Path }}: {{ .DurationMs }}ms, {{ .Status }}"></div></div>
+</div>
+{{- end }}
+</body>
+</html>
+`))
@@ -0,0 +1,56 @@
+package configstack
+
+import (
+	"github.com/gruntwork-io/terragrunt/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerFrom returns the tracer of the given options, falling back to a tracer recording nothing if it is not set.
+func tracerFrom(opts *options.TerragruntOptions) trace.Tracer {
+	if opts.Tracer == nil {
+		return noop.NewTracerProvider().Tracer("")
+	}
+
+	return opts.Tracer
+}
+
+// recordSpan records the span of the given finished module, as a child of the span of the run, from the time it
+// started to the time it finished running, or at the current time if it never ran. The span is linked to the spans of
+// the dependencies of the module, which have all finished before it.
+func (scheduler *scheduler) recordSpan(module *RunningModule) {
+	start, end := module.StartTime, module.EndTime
+	if start.IsZero() {
+		start = scheduler.clock.Now()
+		end = start
+	}
+
+	var links []trace.Link
+
+	for _, dependency := range module.Module.Dependencies {
+		if runningDependency, found := scheduler.modules[dependency.Path]; found && runningDependency.spanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: runningDependency.spanContext})
+		}
+	}
+
+	_, span := tracerFrom(scheduler.opts).Start(scheduler.ctx, "run_module",
+		trace.WithTimestamp(start),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("path", module.Module.Path),
+			attribute.String("status", string(module.resultStatus())),
+			attribute.Int64("duration_ms", end.Sub(start).Milliseconds()),
+		),
+	)
+
+	if module.Err != nil {
+		span.RecordError(module.Err)
+		span.SetStatus(codes.Error, module.Err.Error())
+	}
+
+	span.End(trace.WithTimestamp(end))
+
+	module.spanContext = span.SpanContext()
+}
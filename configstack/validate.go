@@ -0,0 +1,74 @@
+package configstack
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/gruntwork-io/terragrunt/internal/errors"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/terraform"
+)
+
+// validate runs terraform validate in all the modules to run, ignoring their order and using up to the given
+// parallelism, as a cheap check before running them for real with ValidateBeforeRun. It returns an error listing every
+// module that failed validation, sorted by path.
+func (modules RunningModules) validate(ctx context.Context, opts *options.TerragruntOptions, parallelism int) error {
+	paths := make([]string, 0, len(modules))
+
+	for path, module := range modules {
+		if !module.Module.Barrier && !module.Module.AssumeAlreadyApplied {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	opts.Logger.Infof("Validating %d modules before running them", len(paths))
+
+	var (
+		waitGroup sync.WaitGroup
+		slots     = make(chan struct{}, max(min(parallelism, len(paths)), 1))
+		errs      = make([]error, len(paths))
+	)
+
+	for i, path := range paths {
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if err := modules[path].Module.runValidate(ctx); err != nil {
+				errs[i] = ModuleValidationError{ModulePath: path, Err: err}
+			}
+		}()
+	}
+
+	waitGroup.Wait()
+
+	var multiErr *errors.MultiError
+
+	for _, err := range errs {
+		if err != nil {
+			multiErr = multiErr.Append(err)
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// runValidate runs terraform validate in the module.
+func (module *TerraformModule) runValidate(ctx context.Context) error {
+	validateOptions, err := module.TerragruntOptions.Clone(module.TerragruntOptions.TerragruntConfigPath)
+	if err != nil {
+		return err
+	}
+
+	validateOptions.TerraformCommand = terraform.CommandNameValidate
+	validateOptions.TerraformCliArgs = []string{terraform.CommandNameValidate}
+
+	return validateOptions.RunTerragrunt(ctx, validateOptions)
+}
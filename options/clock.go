@@ -0,0 +1,29 @@
+package options
+
+import "time"
+
+// Clock is the source of time used by Terragrunt for timing dependent behavior, such as timeouts. It can be replaced
+// with a fake implementation to drive that behavior deterministically in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// Sleep pauses the current goroutine for at least the duration d.
+	Sleep(d time.Duration)
+}
+
+// RealClock is a Clock backed by the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/gruntwork-io/terragrunt/pkg/log/format"
 	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/hashicorp/go-version"
+	"github.com/zclconf/go-cty/cty"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const ContextKey ctxKey = iota
@@ -25,6 +27,37 @@ const (
 	// no limits on parallelism by default (limited by GOPROCS)
 	DefaultParallelism = math.MaxInt32
 
+	// GraphQuoteStyleDefault wraps the node identifiers of the DOT graph output in double quotes as they are, and its
+	// labels in double quotes, escaping only the backslashes and double quotes they contain.
+	GraphQuoteStyleDefault = ""
+	// GraphQuoteStyleStrict wraps the node identifiers and labels of the DOT graph output in double quotes, escaping
+	// the backslashes, double quotes and newlines they contain.
+	GraphQuoteStyleStrict = "strict"
+	// GraphQuoteStyleHTML renders the node identifiers and labels of the DOT graph output as HTML strings, escaping
+	// the HTML special characters they contain.
+	GraphQuoteStyleHTML = "html"
+
+	// GraphEdgeDirectionDependsOn draws the edges of the DOT graph output from each module to its dependencies. It is
+	// the default.
+	GraphEdgeDirectionDependsOn = "depends-on"
+	// GraphEdgeDirectionFeedsInto draws the edges of the DOT graph output from each dependency to the modules depending
+	// on it, in the direction of the data flow.
+	GraphEdgeDirectionFeedsInto = "feeds-into"
+
+	// CIAnnotationsBuildkite wraps the output of each module of *-all commands in a Buildkite log group, expanded if the
+	// module fails.
+	CIAnnotationsBuildkite = "buildkite"
+
+	// OnMissingConfigWarn leaves out the directories found as modules that have no Terragrunt configuration file,
+	// logging a warning about each of them. It is the default.
+	OnMissingConfigWarn = "warn"
+	// OnMissingConfigSkip silently leaves out the directories found as modules that have no Terragrunt configuration
+	// file.
+	OnMissingConfigSkip = "skip"
+	// OnMissingConfigError fails the discovery of the modules on the first directory found as a module that has no
+	// Terragrunt configuration file.
+	OnMissingConfigError = "error"
+
 	// TofuDefaultPath command to run tofu
 	TofuDefaultPath = "tofu"
 
@@ -188,6 +221,12 @@ type TerragruntOptions struct {
 	// If set to true, apply all external dependencies when running *-all commands
 	IncludeExternalDependencies bool
 
+	// OnMissingConfig is what happens when a directory found as a module while discovering the modules of a stack has no
+	// Terragrunt configuration file: OnMissingConfigWarn, the default if empty, OnMissingConfigSkip or
+	// OnMissingConfigError. It doesn't apply to the dependencies declared in the configs, which are always an error if
+	// they have no configuration file, as the modules depending on them would otherwise run without waiting for them.
+	OnMissingConfig string
+
 	// If you want stdout to go somewhere other than os.stdout
 	Writer io.Writer
 
@@ -229,6 +268,278 @@ type TerragruntOptions struct {
 	// Parallelism limits the number of commands to run concurrently during *-all commands
 	Parallelism int
 
+	// If greater than 0, limits the number of modules of each cloud account that run concurrently during *-all
+	// commands, as the modules of an account share its API rate limits. The account of a module is its account_id
+	// label, or else the AWS_PROFILE of its environment. The modules without either share a single account.
+	ParallelismPerAccount int
+
+	// The amount available of shared finite resources, such as API quotas or database connections, by resource tag.
+	// During *-all commands, a module only runs while the amounts its ResourceTags use, added to those used by the
+	// modules already running, stay within the amount available of each of them. Resources missing from this map are
+	// unlimited.
+	ResourceCapacities map[string]int
+
+	// Exit codes of the terraform command that are treated as a success during *-all commands, e.g. 2 for
+	// `plan -detailed-exitcode` when changes are present.
+	SuccessExitCodes []int
+
+	// If set to true, `run-all apply` first plans every module, asks for a single confirmation and then applies the
+	// saved plans of the modules with changes in dependency order.
+	RunAllPlanThenApply bool
+
+	// Called with the paths of the modules with changes between the plan and the apply phase of RunAllPlanThenApply.
+	// The apply phase only runs if it returns true. If not set, the user is prompted for confirmation.
+	RunAllConfirmApply func(ctx context.Context, modulesWithChanges []string) (bool, error)
+
+	// If set to true, *-all commands fail when resolving modules with an empty configuration, i.e. without terraform
+	// files in their resolved terraform source, or in their folder if they have no source.
+	StrictConfig bool
+
+	// Maximum amount of time a single module is allowed to run during *-all commands. Zero means no limit. A module
+	// that times out is cancelled, and its dependents wait up to 30 seconds for it to exit.
+	ModuleTimeout time.Duration
+
+	// If set, the modules of *-all commands don't run terraform and only take this duration, measured with the Clock,
+	// e.g. to load test the scheduling of the modules.
+	SyntheticModuleDelay time.Duration
+
+	// The source of time used for timing dependent behavior, such as the module timeout.
+	Clock Clock
+
+	// If set, the decisions of the *-all scheduler (when modules get blocked, become ready and are dispatched) are
+	// written to this writer with timestamps. Meant for debugging the scheduler itself.
+	SchedulerTrace io.Writer
+
+	// If set, a Gantt-style timeline of the *-all run, with the start and end times of every module, is written to
+	// this file once the modules have run: as an HTML chart if the file has the .html extension, as JSON otherwise.
+	RunTimelineFile string
+
+	// If set, the durations of the modules of *-all runs are written to this file as folded stacks, one line per
+	// dependency chain such as "vpc;app;dns 1200", which flamegraph tools can render. The durations are in milliseconds.
+	FoldedStacksFile string
+
+	// The tracer recording the spans of *-all runs: a span for the whole run, with a child span for each module. No
+	// spans are recorded if nil.
+	Tracer trace.Tracer
+
+	// If set, called with the events of *-all runs, such as modules starting and finishing. The events of a run are
+	// emitted one at a time from the scheduler, so the handler must return quickly.
+	RunEventHandler func(event RunEvent)
+
+	// If set, the events of *-all runs are also published as newline-delimited JSON to every process connected to the
+	// Unix domain socket with this path. The events are dropped for the processes that don't keep up with the run.
+	EventSocket string
+
+	// If set, a RunEventQueueSaturated event is emitted for every module that is ready to run but waits for a free
+	// parallelism slot for longer than this duration during *-all commands.
+	QueueWarnAfter time.Duration
+
+	// If set, this command and its arguments are run in the working directory of each module instead of terraform,
+	// e.g. for maintenance tasks over a stack with run-all. The modules still run in dependency order, with their hooks.
+	CustomCommand []string
+
+	// If set, this command and its arguments are run once in the working directory before any module of *-all commands
+	// runs, e.g. to mirror the providers or refresh credentials. If it fails, no module runs.
+	WarmUpCommand []string
+
+	// If set, a snapshot of the key options of each module, with the values of sensitive environment variables redacted,
+	// is logged before running the modules of *-all commands.
+	DumpEffectiveOptions bool
+
+	// If set, a JUnit XML report of *-all runs is written to this file, with one test case per module.
+	JUnitReportFile string
+
+	// If set, the outputs of dependencies are read from this snapshot, keyed by the directory of the dependency, instead
+	// of from their state, e.g. to plan entirely offline.
+	DependencyOutputs map[string]map[string]cty.Value
+
+	// If set, the outputs of the dependencies missing from DependencyOutputs are read from their state. Otherwise, a
+	// dependency missing from DependencyOutputs is an error.
+	DependencyOutputsFallback bool
+
+	// If set, the number of modules running concurrently during *-all commands grows linearly from 1 to the parallelism
+	// over this duration at the start of the run, instead of reaching the parallelism immediately.
+	RampUp time.Duration
+
+	// If set, the number of modules running concurrently during *-all commands adapts to the failure rate of the last
+	// modules that ran: it is halved, down to 1, whenever more than half of them failed, and grows back by one, up to
+	// the parallelism, with every module that succeeds once they fail less often, e.g. to limit the wasted work of a
+	// systemic failure.
+	AdaptiveConcurrency bool
+
+	// If set, called before a module runs during *-all commands, with the options of the module. Returning an error
+	// fails the module, except for the configstack.SkipModule error that skips the module as a success.
+	BeforeModuleHook func(ctx context.Context, opts *TerragruntOptions) error
+
+	// GraphQuoteStyle is the quoting of the node identifiers and labels in the DOT graph output: GraphQuoteStyleDefault,
+	// GraphQuoteStyleStrict or GraphQuoteStyleHTML.
+	GraphQuoteStyle string
+
+	// GraphEdgeDirection is the direction of the edges in the DOT graph output: GraphEdgeDirectionDependsOn, the default
+	// if empty, or GraphEdgeDirectionFeedsInto.
+	GraphEdgeDirection string
+
+	// If set, each set of modules linked by dependencies is laid out as a separate cluster in the DOT graph output, so
+	// that independent stacks are visually separated.
+	GraphByComponent bool
+
+	// If set, each node of the DOT graph output has a batch attribute with the level of its module in the dependency
+	// graph, e.g. batch=2: the modules of a batch only depend on the modules of the earlier batches, so they can run in
+	// the same parallel wave.
+	GraphShowBatches bool
+
+	// If set, the DOT graph output only has the edges of the transitive reduction of the graph: the edges from a
+	// module to a dependency it also reaches through another of its dependencies are left out, which keeps dense graphs
+	// readable without changing which modules depend on which.
+	GraphTransitiveReduction bool
+
+	// If set, the module paths in all the graph outputs are relative to this directory, e.g. the root of the git
+	// repository, instead of the directory of the TerragruntConfigPath.
+	GraphTrimPrefix string
+
+	// If ShardTotal is set, *-all commands only run the modules of the shard with index ShardIndex, from 0 to
+	// ShardTotal-1, the modules being partitioned deterministically by a hash of their path, so that several machines
+	// can share a run. The modules of the other shards are assumed already applied.
+	ShardIndex int
+	ShardTotal int
+
+	// If set, used to pause and resume *-all commands while they run.
+	RunControl *RunControl
+
+	// If set, *-all commands run the modules one level of the dependency graph at a time, and pause once all the
+	// modules of a level have finished, to inspect their results, before starting the modules of the next level.
+	PauseBetweenLevels bool
+
+	// Called with PauseBetweenLevels once all the modules of a level have finished, with the level about to start. The
+	// modules of that level only run if it returns true, the remaining modules being skipped otherwise. If not set, the
+	// user is prompted for confirmation.
+	ContinueToLevel func(ctx context.Context, level int) (bool, error)
+
+	// If set, used to focus the output of *-all commands on a single module while they run.
+	OutputFocus *OutputFocus
+
+	// If set, what the modules of *-all commands write to stderr is not written as they run, but captured and written
+	// along with their errors in a report of the failed modules once all the modules have finished, ordered by their
+	// level in the dependency graph.
+	DeferErrorOutput bool
+
+	// If set, the output of each module of *-all commands is wrapped in markers for the log of this CI system, such as
+	// CIAnnotationsBuildkite, so that it is displayed as a group per module.
+	CIAnnotations string
+
+	// If set, the metrics of *-all runs are written to this file in the Prometheus text exposition format, e.g. for the
+	// textfile collector of the node exporter.
+	PrometheusTextfile string
+
+	// If set, the terraform outputs of the modules of *-all commands that have dependents are hashed right before and
+	// once they have run, and the dependents assumed already applied of a module whose outputs changed during its run
+	// are run as well.
+	PropagateChangesToDependents bool
+
+	// If set, the terraform outputs of the modules of *-all commands are read once they have run and passed to their
+	// dependents as TF_VAR_<module>_<output> env vars, named after the directory of the module.
+	DependencyOutputsAsEnv bool
+
+	// If set, the modules of *-all commands whose remote state is stored in the same place, e.g. the same bucket and
+	// key prefix, run one at a time, so that they don't contend on the locks of the backend.
+	FenceByBackend bool
+
+	// If non-zero, *-all commands run deterministically for reproducible benchmarks of the scheduler: the order in
+	// which the modules are queued and finish is drawn from this seed instead of depending on the scheduling of the
+	// goroutines, the modules that finish being held back until no module is running. Together with a Clock and
+	// a deterministic RunTerragrunt, two runs with the same seed produce the same events.
+	RunSeed int64
+
+	// If set, *-all commands check that the remote terraform sources of the modules are reachable before running any
+	// module, failing with the list of the unreachable ones. With SkipUnreachableSources, the modules with an
+	// unreachable source, and the modules depending on them, are skipped instead.
+	PreflightSources       bool
+	SkipUnreachableSources bool
+
+	// Checks that a remote terraform source is reachable for PreflightSources and SkipUnreachableSources. If not set,
+	// git sources are checked with git ls-remote and HTTP sources with a HEAD request.
+	SourceChecker func(ctx context.Context, source string) error
+
+	// If positive, *-all commands refuse to run more than this many modules, as a safety rail against running an
+	// unexpectedly large stack.
+	MaxModules int
+
+	// If set, *-all commands first run terraform validate in all the modules, ignoring their order, and only run the
+	// modules for real if they all pass, failing with the list of the modules that didn't otherwise.
+	ValidateBeforeRun bool
+
+	// If positive, the error of *-all commands lists at most this many of the errors of the modules, followed by the
+	// number of errors left out.
+	MaxErrorEntries int
+
+	// If set, each module of *-all commands runs in a copy of its directory in a new temporary directory, removed once
+	// the module is done, leaving out the files matching the glob patterns of IsolateWorkdirIgnore. The dependencies
+	// of the modules are still read from their original location, and the state files written by a local backend are
+	// copied back there before the copy is removed.
+	IsolateWorkdir       bool
+	IsolateWorkdirIgnore []string
+
+	// If set, the dependents of a module of *-all commands that was skipped rather than run, because it was excluded or
+	// because it returned SkipModule, run as usual and read the existing state of the module. Otherwise, they are
+	// blocked as if the module had failed. Enabled by default.
+	RunDependentsOnSkippedDependency bool
+
+	// If set, the values of the dependency outputs marked as sensitive are redacted from the logs. The outputs are
+	// still passed to the modules as is. Enabled by default.
+	RedactSensitiveOutputs bool
+
+	// If set, the dependency outputs read within this duration of each other reuse the outputs read first, rather than
+	// reading the state of the dependency again. The outputs of a module are read again once it has run. If not set,
+	// the outputs are read once per process.
+	OutputCacheTTL time.Duration
+
+	// If set, the status of every module of *-all commands is written to this file as JSON after the modules have run.
+	RunSummaryFile string
+
+	// If set to the RunSummaryFile of an earlier run, *-all commands exclude the modules that succeeded in that run, so
+	// that only the modules that failed and the modules they blocked run again.
+	RerunFailedFrom string
+
+	// If set, a hash of the contents of every module that succeeded in a *-all command, covering its Terragrunt
+	// configuration, the files of its directory and its terraform source, is written to this file as JSON after the
+	// modules have run, to be compared with by RunChangedOnly in later runs.
+	ModuleHashFile string
+
+	// If set along with a ModuleHashFile, *-all commands assume the modules whose contents have the same hash as in
+	// the ModuleHashFile to be already applied, so that only the modules that changed since their last successful
+	// run are run.
+	RunChangedOnly bool
+
+	// If set along with RunChangedOnly, the modules depending directly or indirectly on a changed module are run as
+	// well, even if their own contents did not change.
+	RunDependentsOfChangedModules bool
+
+	// If set, resolving the modules of *-all commands fails if some modules neither depend on another module nor are a
+	// dependency of one, which usually means that their dependencies were not declared.
+	ErrorOnIsolatedModules bool
+
+	// If set, resolving the modules of *-all commands fails if some modules store their state in the same place, as
+	// read from their remote_state blocks, in which case they would overwrite each other's state.
+	CheckStateKeyCollisions bool
+
+	// If set, the log prefix of the modules of *-all commands includes their level in the dependency graph, e.g.
+	// "[L2 alpha/g]": 0 for the modules without dependencies, and one more than their deepest dependency for the others.
+	PrefixIncludeLevel bool
+
+	// If set, *-all commands stop running modules once this many modules have run successfully, in dependency order,
+	// and skip the modules that haven't started, leaving them for a later run, e.g. for canary rollouts.
+	ApplyLimit int
+
+	// If set, the duration of the last successful run of every module of *-all commands is kept in this file as JSON,
+	// and compared to the duration of the current run.
+	DurationHistoryFile string
+	// If set with a DurationHistoryFile, the modules that take more than this percentage longer to run than in the
+	// history are flagged as regressions in the logs and in the RunSummary, e.g. 50 for 50% longer.
+	DurationRegressionThreshold float64
+	// If set with a DurationHistoryFile, *-all commands refuse to run if the duration of the run, estimated from the
+	// durations in the history and the parallelism, is longer than this.
+	MaxEstimatedDuration time.Duration
+
 	// Enable check mode, by default it's disabled.
 	Check bool
 
@@ -245,6 +556,13 @@ type TerragruntOptions struct {
 	// in this list.
 	ModulesThatInclude []string
 
+	// When used with `run-all`, restrict the modules in the stack to only those whose labels match this selector,
+	// written as comma separated `key=value` pairs, e.g. `team=payments,env=prod`.
+	ModuleSelector string
+
+	// If set to true, the dependencies of the modules matching ModuleSelector are included in the run as well.
+	ModuleSelectorIncludeDeps bool
+
 	// A command that can be used to run Terragrunt with the given options. This is useful for running Terragrunt
 	// multiple times (e.g. when spinning up a stack of Terraform modules). The actual command is normally defined
 	// in the cli package, which depends on almost all other packages, so we declare it here so that other
@@ -293,6 +611,9 @@ type TerragruntOptions struct {
 	// Root directory for graph command.
 	GraphRoot string
 
+	// If set to true, the graph output only declares the nodes that are not already introduced by an edge.
+	GraphCompact bool
+
 	// Disable listing of dependent modules in render json output
 	JSONDisableDependentModules bool
 
@@ -413,46 +734,51 @@ func NewTerragruntOptionsWithWriters(stdout, stderr io.Writer) *TerragruntOption
 	var logFormatter = format.NewFormatter()
 
 	return &TerragruntOptions{
-		TerraformPath:                  DefaultWrappedPath,
-		ExcludesFile:                   defaultExcludesFile,
-		OriginalTerraformCommand:       "",
-		TerraformCommand:               "",
-		AutoInit:                       true,
-		RunAllAutoApprove:              true,
-		NonInteractive:                 false,
-		TerraformCliArgs:               []string{},
-		LogLevel:                       defaultLogLevel,
-		LogFormatter:                   logFormatter,
-		Logger:                         log.New(log.WithOutput(stderr), log.WithLevel(defaultLogLevel), log.WithFormatter(logFormatter)),
-		Env:                            map[string]string{},
-		Source:                         "",
-		SourceMap:                      map[string]string{},
-		SourceUpdate:                   false,
-		IgnoreDependencyErrors:         false,
-		IgnoreDependencyOrder:          false,
-		IgnoreExternalDependencies:     false,
-		IncludeExternalDependencies:    false,
-		Writer:                         stdout,
-		ErrWriter:                      stderr,
-		MaxFoldersToCheck:              DefaultMaxFoldersToCheck,
-		AutoRetry:                      true,
-		RetryMaxAttempts:               DefaultRetryMaxAttempts,
-		RetrySleepInterval:             DefaultRetrySleepInterval,
-		RetryableErrors:                util.CloneStringList(DefaultRetryableErrors),
-		ExcludeDirs:                    []string{},
-		IncludeDirs:                    []string{},
-		ModulesThatInclude:             []string{},
-		StrictInclude:                  false,
-		Parallelism:                    DefaultParallelism,
-		Check:                          false,
-		Diff:                           false,
-		FetchDependencyOutputFromState: false,
-		UsePartialParseConfigCache:     false,
-		ForwardTFStdout:                false,
-		JSONOut:                        DefaultJSONOutName,
-		TerraformImplementation:        UnknownImpl,
-		TerraformLogsToJSON:            false,
-		JSONDisableDependentModules:    false,
+		TerraformPath:                    DefaultWrappedPath,
+		ExcludesFile:                     defaultExcludesFile,
+		OriginalTerraformCommand:         "",
+		TerraformCommand:                 "",
+		AutoInit:                         true,
+		RunAllAutoApprove:                true,
+		NonInteractive:                   false,
+		TerraformCliArgs:                 []string{},
+		LogLevel:                         defaultLogLevel,
+		LogFormatter:                     logFormatter,
+		Logger:                           log.New(log.WithOutput(stderr), log.WithLevel(defaultLogLevel), log.WithFormatter(logFormatter)),
+		Env:                              map[string]string{},
+		Source:                           "",
+		SourceMap:                        map[string]string{},
+		SourceUpdate:                     false,
+		IgnoreDependencyErrors:           false,
+		IgnoreDependencyOrder:            false,
+		IgnoreExternalDependencies:       false,
+		IncludeExternalDependencies:      false,
+		OnMissingConfig:                  OnMissingConfigWarn,
+		Writer:                           stdout,
+		ErrWriter:                        stderr,
+		MaxFoldersToCheck:                DefaultMaxFoldersToCheck,
+		AutoRetry:                        true,
+		RunDependentsOnSkippedDependency: true,
+		RedactSensitiveOutputs:           true,
+		RetryMaxAttempts:                 DefaultRetryMaxAttempts,
+		RetrySleepInterval:               DefaultRetrySleepInterval,
+		RetryableErrors:                  util.CloneStringList(DefaultRetryableErrors),
+		ExcludeDirs:                      []string{},
+		IncludeDirs:                      []string{},
+		ModulesThatInclude:               []string{},
+		StrictInclude:                    false,
+		Parallelism:                      DefaultParallelism,
+		SuccessExitCodes:                 []int{0},
+		Clock:                            RealClock{},
+		Check:                            false,
+		Diff:                             false,
+		FetchDependencyOutputFromState:   false,
+		UsePartialParseConfigCache:       false,
+		ForwardTFStdout:                  false,
+		JSONOut:                          DefaultJSONOutName,
+		TerraformImplementation:          UnknownImpl,
+		TerraformLogsToJSON:              false,
+		JSONDisableDependentModules:      false,
 		RunTerragrunt: func(ctx context.Context, opts *TerragruntOptions) error {
 			return errors.New(ErrRunTerragruntCommandNotSet)
 		},
@@ -536,82 +862,153 @@ func (opts *TerragruntOptions) Clone(terragruntConfigPath string) (*TerragruntOp
 	// during xxx-all commands (e.g., apply-all, plan-all). See https://github.com/gruntwork-io/terragrunt/issues/367
 	// for more info.
 	return &TerragruntOptions{
-		TerragruntConfigPath:           terragruntConfigPath,
-		OriginalTerragruntConfigPath:   opts.OriginalTerragruntConfigPath,
-		TerraformPath:                  opts.TerraformPath,
-		OriginalTerraformCommand:       opts.OriginalTerraformCommand,
-		TerraformCommand:               opts.TerraformCommand,
-		TerraformVersion:               opts.TerraformVersion,
-		TerragruntVersion:              opts.TerragruntVersion,
-		AutoInit:                       opts.AutoInit,
-		RunAllAutoApprove:              opts.RunAllAutoApprove,
-		NonInteractive:                 opts.NonInteractive,
-		TerraformCliArgs:               util.CloneStringList(opts.TerraformCliArgs),
-		WorkingDir:                     workingDir,
-		RootWorkingDir:                 opts.RootWorkingDir,
-		Logger:                         opts.Logger.WithField(format.PrefixKeyName, workingDir),
-		LogLevel:                       opts.LogLevel,
-		LogFormatter:                   opts.LogFormatter,
-		ValidateStrict:                 opts.ValidateStrict,
-		Env:                            util.CloneStringMap(opts.Env),
-		Source:                         opts.Source,
-		SourceMap:                      opts.SourceMap,
-		SourceUpdate:                   opts.SourceUpdate,
-		DownloadDir:                    opts.DownloadDir,
-		Debug:                          opts.Debug,
-		OriginalIAMRoleOptions:         opts.OriginalIAMRoleOptions,
-		IAMRoleOptions:                 opts.IAMRoleOptions,
-		IgnoreDependencyErrors:         opts.IgnoreDependencyErrors,
-		IgnoreDependencyOrder:          opts.IgnoreDependencyOrder,
-		IgnoreExternalDependencies:     opts.IgnoreExternalDependencies,
-		IncludeExternalDependencies:    opts.IncludeExternalDependencies,
-		Writer:                         opts.Writer,
-		ErrWriter:                      opts.ErrWriter,
-		MaxFoldersToCheck:              opts.MaxFoldersToCheck,
-		AutoRetry:                      opts.AutoRetry,
-		RetryMaxAttempts:               opts.RetryMaxAttempts,
-		RetrySleepInterval:             opts.RetrySleepInterval,
-		RetryableErrors:                util.CloneStringList(opts.RetryableErrors),
-		ExcludesFile:                   opts.ExcludesFile,
-		ExcludeDirs:                    opts.ExcludeDirs,
-		IncludeDirs:                    opts.IncludeDirs,
-		ExcludeByDefault:               opts.ExcludeByDefault,
-		ModulesThatInclude:             opts.ModulesThatInclude,
-		Parallelism:                    opts.Parallelism,
-		StrictInclude:                  opts.StrictInclude,
-		RunTerragrunt:                  opts.RunTerragrunt,
-		AwsProviderPatchOverrides:      opts.AwsProviderPatchOverrides,
-		HclFile:                        opts.HclFile,
-		JSONOut:                        opts.JSONOut,
-		JSONLogFormat:                  opts.JSONLogFormat,
-		Check:                          opts.Check,
-		CheckDependentModules:          opts.CheckDependentModules,
-		FetchDependencyOutputFromState: opts.FetchDependencyOutputFromState,
-		UsePartialParseConfigCache:     opts.UsePartialParseConfigCache,
-		ForwardTFStdout:                opts.ForwardTFStdout,
-		FailIfBucketCreationRequired:   opts.FailIfBucketCreationRequired,
-		DisableBucketUpdate:            opts.DisableBucketUpdate,
-		TerraformImplementation:        opts.TerraformImplementation,
-		TerraformLogsToJSON:            opts.TerraformLogsToJSON,
-		GraphRoot:                      opts.GraphRoot,
-		ScaffoldVars:                   opts.ScaffoldVars,
-		ScaffoldVarFiles:               opts.ScaffoldVarFiles,
-		JSONDisableDependentModules:    opts.JSONDisableDependentModules,
-		ProviderCache:                  opts.ProviderCache,
-		ProviderCacheToken:             opts.ProviderCacheToken,
-		ProviderCacheDir:               opts.ProviderCacheDir,
-		ProviderCacheRegistryNames:     opts.ProviderCacheRegistryNames,
-		DisableLogColors:               opts.DisableLogColors,
-		OutputFolder:                   opts.OutputFolder,
-		JSONOutputFolder:               opts.JSONOutputFolder,
-		AuthProviderCmd:                opts.AuthProviderCmd,
-		SkipOutput:                     opts.SkipOutput,
-		DisableLog:                     opts.DisableLog,
-		EngineEnabled:                  opts.EngineEnabled,
-		EngineCachePath:                opts.EngineCachePath,
-		EngineLogLevel:                 opts.EngineLogLevel,
-		EngineSkipChecksumCheck:        opts.EngineSkipChecksumCheck,
-		Engine:                         cloneEngineOptions(opts.Engine),
+		TerragruntConfigPath:             terragruntConfigPath,
+		OriginalTerragruntConfigPath:     opts.OriginalTerragruntConfigPath,
+		TerraformPath:                    opts.TerraformPath,
+		OriginalTerraformCommand:         opts.OriginalTerraformCommand,
+		TerraformCommand:                 opts.TerraformCommand,
+		TerraformVersion:                 opts.TerraformVersion,
+		TerragruntVersion:                opts.TerragruntVersion,
+		AutoInit:                         opts.AutoInit,
+		RunAllAutoApprove:                opts.RunAllAutoApprove,
+		NonInteractive:                   opts.NonInteractive,
+		TerraformCliArgs:                 util.CloneStringList(opts.TerraformCliArgs),
+		WorkingDir:                       workingDir,
+		RootWorkingDir:                   opts.RootWorkingDir,
+		Logger:                           opts.Logger.WithField(format.PrefixKeyName, workingDir),
+		LogLevel:                         opts.LogLevel,
+		LogFormatter:                     opts.LogFormatter,
+		ValidateStrict:                   opts.ValidateStrict,
+		Env:                              util.CloneStringMap(opts.Env),
+		Source:                           opts.Source,
+		SourceMap:                        opts.SourceMap,
+		SourceUpdate:                     opts.SourceUpdate,
+		DownloadDir:                      opts.DownloadDir,
+		Debug:                            opts.Debug,
+		OriginalIAMRoleOptions:           opts.OriginalIAMRoleOptions,
+		IAMRoleOptions:                   opts.IAMRoleOptions,
+		IgnoreDependencyErrors:           opts.IgnoreDependencyErrors,
+		IgnoreDependencyOrder:            opts.IgnoreDependencyOrder,
+		IgnoreExternalDependencies:       opts.IgnoreExternalDependencies,
+		IncludeExternalDependencies:      opts.IncludeExternalDependencies,
+		OnMissingConfig:                  opts.OnMissingConfig,
+		Writer:                           opts.Writer,
+		ErrWriter:                        opts.ErrWriter,
+		MaxFoldersToCheck:                opts.MaxFoldersToCheck,
+		AutoRetry:                        opts.AutoRetry,
+		RetryMaxAttempts:                 opts.RetryMaxAttempts,
+		RetrySleepInterval:               opts.RetrySleepInterval,
+		RetryableErrors:                  util.CloneStringList(opts.RetryableErrors),
+		ExcludesFile:                     opts.ExcludesFile,
+		ExcludeDirs:                      opts.ExcludeDirs,
+		IncludeDirs:                      opts.IncludeDirs,
+		ExcludeByDefault:                 opts.ExcludeByDefault,
+		ModulesThatInclude:               opts.ModulesThatInclude,
+		ModuleSelector:                   opts.ModuleSelector,
+		ModuleSelectorIncludeDeps:        opts.ModuleSelectorIncludeDeps,
+		Parallelism:                      opts.Parallelism,
+		ParallelismPerAccount:            opts.ParallelismPerAccount,
+		ResourceCapacities:               opts.ResourceCapacities,
+		SuccessExitCodes:                 opts.SuccessExitCodes,
+		RunAllPlanThenApply:              opts.RunAllPlanThenApply,
+		RunAllConfirmApply:               opts.RunAllConfirmApply,
+		StrictConfig:                     opts.StrictConfig,
+		ModuleTimeout:                    opts.ModuleTimeout,
+		SyntheticModuleDelay:             opts.SyntheticModuleDelay,
+		Clock:                            opts.Clock,
+		SchedulerTrace:                   opts.SchedulerTrace,
+		RunTimelineFile:                  opts.RunTimelineFile,
+		FoldedStacksFile:                 opts.FoldedStacksFile,
+		Tracer:                           opts.Tracer,
+		RunEventHandler:                  opts.RunEventHandler,
+		EventSocket:                      opts.EventSocket,
+		QueueWarnAfter:                   opts.QueueWarnAfter,
+		CustomCommand:                    util.CloneStringList(opts.CustomCommand),
+		WarmUpCommand:                    util.CloneStringList(opts.WarmUpCommand),
+		DumpEffectiveOptions:             opts.DumpEffectiveOptions,
+		JUnitReportFile:                  opts.JUnitReportFile,
+		DependencyOutputs:                opts.DependencyOutputs,
+		DependencyOutputsFallback:        opts.DependencyOutputsFallback,
+		RampUp:                           opts.RampUp,
+		AdaptiveConcurrency:              opts.AdaptiveConcurrency,
+		BeforeModuleHook:                 opts.BeforeModuleHook,
+		GraphQuoteStyle:                  opts.GraphQuoteStyle,
+		GraphEdgeDirection:               opts.GraphEdgeDirection,
+		GraphByComponent:                 opts.GraphByComponent,
+		GraphShowBatches:                 opts.GraphShowBatches,
+		GraphTransitiveReduction:         opts.GraphTransitiveReduction,
+		GraphTrimPrefix:                  opts.GraphTrimPrefix,
+		ShardIndex:                       opts.ShardIndex,
+		ShardTotal:                       opts.ShardTotal,
+		RunControl:                       opts.RunControl,
+		PauseBetweenLevels:               opts.PauseBetweenLevels,
+		ContinueToLevel:                  opts.ContinueToLevel,
+		OutputFocus:                      opts.OutputFocus,
+		DeferErrorOutput:                 opts.DeferErrorOutput,
+		CIAnnotations:                    opts.CIAnnotations,
+		PrometheusTextfile:               opts.PrometheusTextfile,
+		PropagateChangesToDependents:     opts.PropagateChangesToDependents,
+		DependencyOutputsAsEnv:           opts.DependencyOutputsAsEnv,
+		FenceByBackend:                   opts.FenceByBackend,
+		RunSeed:                          opts.RunSeed,
+		PreflightSources:                 opts.PreflightSources,
+		SkipUnreachableSources:           opts.SkipUnreachableSources,
+		SourceChecker:                    opts.SourceChecker,
+		MaxModules:                       opts.MaxModules,
+		ValidateBeforeRun:                opts.ValidateBeforeRun,
+		MaxErrorEntries:                  opts.MaxErrorEntries,
+		IsolateWorkdir:                   opts.IsolateWorkdir,
+		IsolateWorkdirIgnore:             util.CloneStringList(opts.IsolateWorkdirIgnore),
+		RunDependentsOnSkippedDependency: opts.RunDependentsOnSkippedDependency,
+		RedactSensitiveOutputs:           opts.RedactSensitiveOutputs,
+		OutputCacheTTL:                   opts.OutputCacheTTL,
+		RunSummaryFile:                   opts.RunSummaryFile,
+		RerunFailedFrom:                  opts.RerunFailedFrom,
+		ModuleHashFile:                   opts.ModuleHashFile,
+		RunChangedOnly:                   opts.RunChangedOnly,
+		RunDependentsOfChangedModules:    opts.RunDependentsOfChangedModules,
+		ErrorOnIsolatedModules:           opts.ErrorOnIsolatedModules,
+		CheckStateKeyCollisions:          opts.CheckStateKeyCollisions,
+		PrefixIncludeLevel:               opts.PrefixIncludeLevel,
+		ApplyLimit:                       opts.ApplyLimit,
+		DurationHistoryFile:              opts.DurationHistoryFile,
+		DurationRegressionThreshold:      opts.DurationRegressionThreshold,
+		MaxEstimatedDuration:             opts.MaxEstimatedDuration,
+		StrictInclude:                    opts.StrictInclude,
+		RunTerragrunt:                    opts.RunTerragrunt,
+		AwsProviderPatchOverrides:        opts.AwsProviderPatchOverrides,
+		HclFile:                          opts.HclFile,
+		JSONOut:                          opts.JSONOut,
+		JSONLogFormat:                    opts.JSONLogFormat,
+		Check:                            opts.Check,
+		CheckDependentModules:            opts.CheckDependentModules,
+		FetchDependencyOutputFromState:   opts.FetchDependencyOutputFromState,
+		UsePartialParseConfigCache:       opts.UsePartialParseConfigCache,
+		ForwardTFStdout:                  opts.ForwardTFStdout,
+		FailIfBucketCreationRequired:     opts.FailIfBucketCreationRequired,
+		DisableBucketUpdate:              opts.DisableBucketUpdate,
+		TerraformImplementation:          opts.TerraformImplementation,
+		TerraformLogsToJSON:              opts.TerraformLogsToJSON,
+		GraphRoot:                        opts.GraphRoot,
+		GraphCompact:                     opts.GraphCompact,
+		ScaffoldVars:                     opts.ScaffoldVars,
+		ScaffoldVarFiles:                 opts.ScaffoldVarFiles,
+		JSONDisableDependentModules:      opts.JSONDisableDependentModules,
+		ProviderCache:                    opts.ProviderCache,
+		ProviderCacheToken:               opts.ProviderCacheToken,
+		ProviderCacheDir:                 opts.ProviderCacheDir,
+		ProviderCacheRegistryNames:       opts.ProviderCacheRegistryNames,
+		DisableLogColors:                 opts.DisableLogColors,
+		OutputFolder:                     opts.OutputFolder,
+		JSONOutputFolder:                 opts.JSONOutputFolder,
+		AuthProviderCmd:                  opts.AuthProviderCmd,
+		SkipOutput:                       opts.SkipOutput,
+		DisableLog:                       opts.DisableLog,
+		EngineEnabled:                    opts.EngineEnabled,
+		EngineCachePath:                  opts.EngineCachePath,
+		EngineLogLevel:                   opts.EngineLogLevel,
+		EngineSkipChecksumCheck:          opts.EngineSkipChecksumCheck,
+		Engine:                           cloneEngineOptions(opts.Engine),
 		// copy array
 		StrictControls: util.CloneStringList(opts.StrictControls),
 	}, nil
@@ -0,0 +1,39 @@
+package options
+
+import "sync"
+
+// OutputFocus focuses the output of a running *-all command on a single module: while a module is focused, its output
+// is streamed to the terminal as it is written, and the output of the other modules is not written to the terminal at
+// all, though it is still captured in the summary of the run. Without a focused module, the output is written as
+// usual. The focus can be moved while the modules run. It is safe for concurrent use.
+type OutputFocus struct {
+	mu sync.Mutex
+	// Path of the focused module, empty if no module is focused.
+	path string
+}
+
+// NewOutputFocus returns an OutputFocus with no focused module.
+func NewOutputFocus() *OutputFocus {
+	return &OutputFocus{}
+}
+
+// Focus focuses the output on the module with the given path.
+func (focus *OutputFocus) Focus(path string) {
+	focus.mu.Lock()
+	defer focus.mu.Unlock()
+
+	focus.path = path
+}
+
+// Unfocus writes the output of all the modules as usual again.
+func (focus *OutputFocus) Unfocus() {
+	focus.Focus("")
+}
+
+// Focused returns the path of the focused module, or an empty string if no module is focused.
+func (focus *OutputFocus) Focused() string {
+	focus.mu.Lock()
+	defer focus.mu.Unlock()
+
+	return focus.path
+}
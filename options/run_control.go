@@ -0,0 +1,50 @@
+package options
+
+import "sync"
+
+// RunControl pauses and resumes a running *-all command. While paused, no new module is started, but the modules
+// already running are left to finish. Unlike cancellation, a paused run can be resumed. It is safe for concurrent use.
+type RunControl struct {
+	mu sync.Mutex
+	// Closed once the run is resumed, nil if the run is not paused.
+	resumed chan struct{}
+}
+
+// NewRunControl returns a RunControl for a run that is not paused.
+func NewRunControl() *RunControl {
+	return &RunControl{}
+}
+
+// Pause stops starting new modules until Resume is called. Pausing a paused run has no effect.
+func (control *RunControl) Pause() {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+
+	if control.resumed == nil {
+		control.resumed = make(chan struct{})
+	}
+}
+
+// Resume starts new modules again after Pause. Resuming a run that is not paused has no effect.
+func (control *RunControl) Resume() {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+
+	if control.resumed != nil {
+		close(control.resumed)
+		control.resumed = nil
+	}
+}
+
+// Paused returns true if the run is paused.
+func (control *RunControl) Paused() bool {
+	return control.Resumed() != nil
+}
+
+// Resumed returns a channel that is closed once the run is resumed, or nil if the run is not paused.
+func (control *RunControl) Resumed() <-chan struct{} {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+
+	return control.resumed
+}
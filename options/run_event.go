@@ -0,0 +1,31 @@
+package options
+
+import "time"
+
+// RunEventType is the type of a RunEvent.
+type RunEventType string
+
+const (
+	// RunEventModuleStarted is emitted when a module is dispatched for running.
+	RunEventModuleStarted RunEventType = "module_started"
+	// RunEventModuleFinished is emitted when a module has finished, with the error of the module in Message if it
+	// failed.
+	RunEventModuleFinished RunEventType = "module_finished"
+	// RunEventQueueSaturated is emitted when a module whose dependencies are all done has been waiting for a free
+	// parallelism slot for longer than QueueWarnAfter, with the time it has been waiting in Duration.
+	RunEventQueueSaturated RunEventType = "queue_saturated"
+	// RunEventConcurrencyChanged is emitted with AdaptiveConcurrency when the number of modules that may run
+	// concurrently changes, with the new limit in Message.
+	RunEventConcurrencyChanged RunEventType = "concurrency_changed"
+)
+
+// RunEvent is an event of a *-all run, emitted to the RunEventHandler of the options.
+type RunEvent struct {
+	Type RunEventType
+	// Time at which the event occurred, according to the Clock of the options.
+	Time time.Time
+	// Path of the module the event is about.
+	Path     string
+	Message  string
+	Duration time.Duration
+}
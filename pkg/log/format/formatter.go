@@ -21,6 +21,8 @@ const (
 
 	PrefixKeyName   = "prefix"
 	TFBinaryKeyName = "tfBinary"
+	// PrefixLevelKeyName is the level of the module in the dependency graph, shown before the prefix as "L<level>".
+	PrefixLevelKeyName = "prefixLevel"
 )
 
 // Formatter implements logrus.Formatter
@@ -149,17 +151,26 @@ func (formatter *Formatter) printFormatted(buf *bytes.Buffer, entry *logrus.Entr
 	}
 
 	var (
-		prefix    string
-		tfBinary  string
-		timestamp string
+		prefix     string
+		prefixName string
+		tfBinary   string
+		timestamp  string
 	)
 
 	if val, ok := entry.Data[PrefixKeyName]; ok && val != nil && val != "." {
-		if val, ok := val.(string); ok && val != "" {
-			prefix = fmt.Sprintf("[%s] ", val)
+		if val, ok := val.(string); ok {
+			prefixName = val
 		}
 	}
 
+	if val, ok := entry.Data[PrefixLevelKeyName]; ok && val != nil {
+		prefixName = strings.TrimSpace(fmt.Sprintf("L%v %s", val, prefixName))
+	}
+
+	if prefixName != "" {
+		prefix = fmt.Sprintf("[%s] ", prefixName)
+	}
+
 	if val, ok := entry.Data[TFBinaryKeyName]; ok && val != nil {
 		if val, ok := val.(string); ok && val != "" {
 			tfBinary = val + ": "
@@ -184,7 +195,7 @@ func (formatter *Formatter) printFormatted(buf *bytes.Buffer, entry *logrus.Entr
 		return errors.New(err)
 	}
 
-	keys := formatter.keys(entry.Data, PrefixKeyName, TFBinaryKeyName)
+	keys := formatter.keys(entry.Data, PrefixKeyName, PrefixLevelKeyName, TFBinaryKeyName)
 	for _, key := range keys {
 		value := entry.Data[key]
 		if err := formatter.appendKeyValue(buf, key, value, true); err != nil {